@@ -0,0 +1,188 @@
+package overseer
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// serveAdmin serves the health endpoints and the status on AdminAddress
+func (mp *master) serveAdmin() error {
+	if mp.Config.AdminAddress == "" {
+		return nil
+	}
+	l, err := net.Listen("tcp", mp.Config.AdminAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin address (%w)", err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", mp.serveHealth)
+	mux.HandleFunc("/readyz", mp.serveReady)
+	mux.Handle("/status", StatusHandler())
+	mux.Handle("/history", HistoryHandler())
+	if mp.Config.AdminPprof {
+		mux.HandleFunc(pprofPath, servePprof)
+	}
+	mp.serving(l)
+	go http.Serve(l, mux)
+	mp.debugf("serving admin endpoints on %s", l.Addr())
+	return nil
+}
+
+// serveHealth reports whether the program is alive, which
+// it still is while it is being replaced by a restart
+func (mp *master) serveHealth(w http.ResponseWriter, r *http.Request) {
+	if err := mp.health(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// serveReady reports whether the program is ready, see Config.WaitReady
+func (mp *master) serveReady(w http.ResponseWriter, r *http.Request) {
+	err := mp.health()
+	if err == nil && !mp.isReady(mp.activeID()) {
+		err = errors.New("program not ready")
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+func (mp *master) health() error {
+	if mp.isStopping() {
+		return errors.New("stopping")
+	}
+	if mp.activeProc() == nil {
+		return errors.New("program not started")
+	}
+	if peer := mp.peer(mp.activeID()); peer != nil {
+		peer.mux.Lock()
+		last := peer.heartbeat
+		peer.mux.Unlock()
+		if !last.IsZero() && time.Since(last) > 3*heartbeatInterval {
+			return fmt.Errorf("program missed its heartbeats for %s", time.Since(last).Round(time.Second))
+		}
+	}
+	return nil
+}
+
+// serveAdminSocket serves the admin commands on AdminSocket
+func (mp *master) serveAdminSocket() error {
+	addr := mp.Config.AdminSocket
+	if addr == "" {
+		return nil
+	}
+	var l net.Listener
+	var err error
+	if isSocketPath(addr) {
+		if err := removeStaleSocket(addr); err != nil {
+			return fmt.Errorf("failed to listen on admin socket (%w)", err)
+		}
+		if l, err = listenPrivate(addr); err == nil {
+			mp.socketPaths = append(mp.socketPaths, addr)
+		}
+	} else {
+		l, err = net.Listen("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to listen on admin socket (%w)", err)
+	}
+	command := func(run func() (string, error)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "POST required", http.StatusMethodNotAllowed)
+				return
+			}
+			out, err := run()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprintln(w, out)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/status", StatusHandler())
+	mux.Handle("/history", HistoryHandler())
+	mux.Handle("/restart", command(func() (string, error) {
+		return mp.triggerRestart().String(), nil
+	}))
+	mux.Handle("/fetch", command(func() (string, error) {
+		return "fetched", mp.triggerFetch()
+	}))
+	mux.Handle("/pause", command(func() (string, error) {
+		return "paused", mp.setPaused(true)
+	}))
+	mux.Handle("/resume", command(func() (string, error) {
+		return "resumed", mp.setPaused(false)
+	}))
+	mux.Handle("/rollback", command(func() (string, error) {
+		return "rolled back", mp.requestRollback()
+	}))
+	mp.serving(l)
+	go http.Serve(l, mp.authenticate(mux))
+	mp.debugf("serving admin commands on %s", l.Addr())
+	return nil
+}
+
+// listenPrivate listens on the unix socket at path, accessible by the
+// owner only, as the token is optional. The socket is created in a
+// private directory, and then moved to path once its mode is set, so
+// that others cannot connect to it in between.
+func listenPrivate(path string) (net.Listener, error) {
+	dir, err := ioutil.TempDir(filepath.Dir(path), ".overseer")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "s")
+	l, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	//the socket is removed from path by removeSockets
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// isSocketPath reports whether the address is a path, rather than host:port
+func isSocketPath(addr string) bool {
+	return strings.ContainsAny(addr, `/\`)
+}
+
+// authenticate requires the AdminToken as a bearer token, if set
+func (mp *master) authenticate(h http.Handler) http.Handler {
+	token := mp.Config.AdminToken
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		given := strings.TrimPrefix(auth, "Bearer ")
+		if given == auth || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			mp.warnf("unauthorized admin command %s from %s", r.URL.Path, r.RemoteAddr)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,126 @@
+package overseer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// EventType identifies a step of the upgrade lifecycle
+type EventType string
+
+const (
+	//EventStart records the first start of the program
+	EventStart EventType = "start"
+	//EventFetch records a fetched binary, or a failed fetch
+	EventFetch EventType = "fetch"
+	//EventVerify records the result of verifying a fetched binary
+	//(PreUpgrade and the sanity check)
+	EventVerify EventType = "verify"
+	//EventUpgrade records the installation of a verified binary
+	EventUpgrade EventType = "upgrade"
+	//EventRestart records the restart of the program
+	EventRestart EventType = "restart"
+	//EventRollback records the restoration of a previous binary
+	EventRollback EventType = "rollback"
+	//EventFailure records an upgraded program which failed
+	//before UpgradeProbation elapsed
+	EventFailure EventType = "failure"
+	//EventCrash records a program which exited unexpectedly,
+	//with an error or killed by a signal
+	EventCrash EventType = "crash"
+	//EventDrain records the exit of a replaced program, with
+	//how long it took to drain since it was asked to terminate
+	EventDrain EventType = "drain"
+)
+
+// Event describes a step of the upgrade lifecycle. Hashes are
+// the hex encoded SHA256 sums of the binaries involved.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Type     EventType `json:"event"`
+	SlaveID  int       `json:"slave_id,omitempty"`
+	Version  string    `json:"version,omitempty"`
+	Hash     string    `json:"hash,omitempty"`
+	PrevHash string    `json:"prev_hash,omitempty"`
+	Size     int64     `json:"size,omitempty"`
+	Source   string    `json:"source,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	//Notes describing the changes in the binary, see fetcher.Info
+	Notes string `json:"notes,omitempty"`
+	//Reason the program gave for the restart, see State.RequestRestart
+	Reason string `json:"reason,omitempty"`
+	//Duration of the step, or how long the program
+	//ran before failing, in nanoseconds
+	Duration time.Duration `json:"duration,omitempty"`
+	//Handover is how long connections were not accepted during a
+	//restart, from the release of the sockets by the previous program
+	//until the next was ready, see Config.WaitReady, or started without
+	//it, in nanoseconds. With ReusePort the programs overlap instead.
+	Handover time.Duration `json:"handover,omitempty"`
+	//Monotonic is the time since the master started, as measured by
+	//the monotonic clock, which unlike Time is unaffected by changes
+	//to the system clock, in nanoseconds. It orders the events
+	//of one master.
+	Monotonic time.Duration `json:"monotonic,omitempty"`
+}
+
+// upgrade describes the binary fetched by the event at the given path
+func (e Event) upgrade(path string) Upgrade {
+	return Upgrade{Path: path, Version: e.Version, Size: e.Size, SHA256: e.Hash, Source: e.Source, Notes: e.Notes}
+}
+
+// openAuditLog opens the audit log for appending, when configured
+func (mp *master) openAuditLog() error {
+	if mp.Config.AuditLog == "" {
+		return nil
+	}
+	f, err := os.OpenFile(mp.Config.AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log (%w)", err)
+	}
+	mp.auditLog = f
+	return nil
+}
+
+// currentHash returns the hash identifying the current binary
+func (mp *master) currentHash() []byte {
+	mp.binMux.Lock()
+	defer mp.binMux.Unlock()
+	return mp.binHash
+}
+
+// binary returns the hash and version of the current binary
+func (mp *master) binary() (hash, version string) {
+	mp.binMux.Lock()
+	defer mp.binMux.Unlock()
+	return hex.EncodeToString(mp.binSHA256), mp.binVersion
+}
+
+// emit records the event
+func (mp *master) emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Monotonic = time.Since(mp.startedAt)
+	mp.logEvent(e)
+	mp.metricCounts.event(e)
+	mp.statsd.event(e)
+	mp.notifyWebhooks(e)
+	mp.mail(e)
+	mp.statusChanged()
+	mp.eventMux.Lock()
+	defer mp.eventMux.Unlock()
+	if mp.auditLog != nil {
+		b, _ := json.Marshal(e)
+		//one write per line, so each record is appended whole
+		if _, err := mp.auditLog.Write(append(b, '\n')); err != nil {
+			mp.warnf("failed to write audit log: %s", err)
+		} else if err := mp.auditLog.Sync(); err != nil {
+			mp.warnf("failed to sync audit log: %s", err)
+		}
+	}
+	mp.record(e)
+}
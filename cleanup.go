@@ -0,0 +1,83 @@
+package overseer
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tmpBinPrefix prefixes the names of all fetched binaries
+const tmpBinPrefix = "overseer-"
+
+// cleanupMinAge protects binaries which may still be
+// in use by another instance of this program
+const cleanupMinAge = 1 * time.Minute
+
+// Retention controls the removal of binaries left behind in the
+// staging directory by previous runs (e.g. after a crash part way
+// through an upgrade). Only binaries fetched by this program are
+// considered, those named overseer-<token> by previous versions
+// cannot be told apart from those of other programs, and binaries
+// modified in the last minute are kept.
+// A zero Retention removes leftovers older than an hour.
+type Retention struct {
+	//Keep at most this many leftover binaries, 0 disables
+	Keep int
+	//MaxAge removes leftover binaries older than this, 0 disables
+	MaxAge time.Duration
+	//MaxSize removes the oldest leftover binaries until the total
+	//size is below this many bytes, 0 disables
+	MaxSize int64
+}
+
+// cleanup applies the retention policy to the staging directory
+func (mp *master) cleanup() {
+	r := mp.Config.Retention
+	if r == (Retention{}) {
+		r.MaxAge = 1 * time.Hour
+	}
+	pattern := filepath.Join(filepath.Dir(mp.tmpBinPath), tmpBinPrefix+mp.binName+"-*")
+	paths, _ := filepath.Glob(pattern)
+	//windows leaves the replaced binary beside the current one
+	if extension() != "" {
+		paths = append(paths, strings.TrimSuffix(mp.binPath, ".exe")+"-old.exe")
+	}
+	type leftover struct {
+		path string
+		info os.FileInfo
+	}
+	leftovers := []leftover{}
+	for _, p := range paths {
+		if p == mp.tmpBinPath || p == mp.previousPath() {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		leftovers = append(leftovers, leftover{p, info})
+	}
+	//newest first
+	sort.Slice(leftovers, func(i, j int) bool {
+		return leftovers[i].info.ModTime().After(leftovers[j].info.ModTime())
+	})
+	total := int64(0)
+	for i, l := range leftovers {
+		age := time.Since(l.info.ModTime())
+		total += l.info.Size()
+		remove := (r.Keep > 0 && i >= r.Keep) ||
+			(r.MaxAge > 0 && age > r.MaxAge) ||
+			(r.MaxSize > 0 && total > r.MaxSize)
+		if !remove || age < cleanupMinAge {
+			continue
+		}
+		if err := os.Remove(l.path); err != nil {
+			mp.debugf("failed to remove old binary: %s", err)
+			continue
+		}
+		total -= l.info.Size()
+		mp.debugf("removed old binary %s", l.path)
+	}
+}
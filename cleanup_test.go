@@ -0,0 +1,43 @@
+package overseer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCleanupOwnBinaries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overseer-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mp := &master{Config: &Config{}, binName: "app", binPath: filepath.Join(dir, "app"), tmpBinPath: filepath.Join(dir, "overseer-app-current")}
+	old := time.Now().Add(-2 * time.Hour)
+	leftover := func(name string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("bin"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	own := leftover("overseer-app-0123456789abcdef")
+	others := []string{
+		leftover("overseer-other-0123456789abcdef"),
+		leftover("overseer-0123456789abcdef"),
+	}
+	mp.cleanup()
+	if _, err := os.Stat(own); !os.IsNotExist(err) {
+		t.Error("leftover of this program not removed")
+	}
+	for _, p := range others {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("removed %s, not fetched by this program", filepath.Base(p))
+		}
+	}
+}
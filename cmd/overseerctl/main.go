@@ -0,0 +1,218 @@
+// overseerctl controls an overseer master over its admin socket,
+// see overseer.Config.AdminSocket.
+//
+//	overseerctl -socket /run/app.sock status
+//	overseerctl -socket /run/app.sock restart
+//	overseerctl -socket /run/app.sock history -f
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/menglh/overseer"
+)
+
+const usage = `usage: overseerctl [options] <command>
+
+commands:
+  status     print the status of the master and its program
+  restart    gracefully restart the program
+  fetch      check for an upgrade now
+  pause      pause upgrades
+  resume     resume upgrades
+  rollback   restore the binary replaced by the last upgrade
+  history    print the upgrade history, -f to follow it
+
+options:
+`
+
+func main() {
+	flags := flag.NewFlagSet("overseerctl", flag.ExitOnError)
+	socket := flags.String("socket", os.Getenv("OVERSEER_SOCKET"), "admin socket path or TCP address (env OVERSEER_SOCKET)")
+	token := flags.String("token", os.Getenv("OVERSEER_TOKEN"), "admin token (env OVERSEER_TOKEN)")
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(os.Args[1:])
+	if *socket == "" || flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	c := newClient(*socket, *token)
+	var err error
+	switch cmd, args := flags.Arg(0), flags.Args()[1:]; cmd {
+	case "status":
+		err = c.status()
+	case "restart", "fetch", "pause", "resume", "rollback":
+		err = c.command(cmd)
+	case "history":
+		h := flag.NewFlagSet("history", flag.ExitOnError)
+		follow := h.Bool("f", false, "follow the history")
+		h.Parse(args)
+		err = c.history(*follow)
+	default:
+		err = fmt.Errorf("unknown command %q", cmd)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "overseerctl: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+type client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+func newClient(socket, token string) *client {
+	c := &client{base: "http://" + socket, token: token, http: &http.Client{Timeout: time.Minute}}
+	if strings.ContainsAny(socket, `/\`) {
+		c.base = "http://overseer"
+		c.http.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		}
+	}
+	return c
+}
+
+// do sends the request, returning the body of a successful response
+func (c *client) do(method, path string) ([]byte, error) {
+	req, err := http.NewRequest(method, c.base+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(strings.TrimSpace(string(b)))
+	}
+	return b, nil
+}
+
+func (c *client) command(cmd string) error {
+	b, err := c.do(http.MethodPost, "/"+cmd)
+	if err != nil {
+		return err
+	}
+	fmt.Print(string(b))
+	return nil
+}
+
+func (c *client) status() error {
+	b, err := c.do(http.MethodGet, "/status")
+	if err != nil {
+		return err
+	}
+	s := overseer.Status{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("invalid status (%w)", err)
+	}
+	line := func(name string, value interface{}) {
+		fmt.Printf("%-18s %v\n", name+":", value)
+	}
+	line("master pid", s.MasterPid)
+	line("program pid", s.SlavePid)
+	line("program id", s.SlaveID)
+	if s.Version != "" {
+		line("version", s.Version)
+	}
+	line("hash", s.Hash)
+	line("uptime", seconds(s.Uptime))
+	line("program uptime", seconds(s.ProgramUptime))
+	if !s.LastFetch.IsZero() {
+		line("last fetch", s.LastFetch.Local().Format(time.RFC3339))
+	}
+	if s.LastFetchError != "" {
+		line("last fetch error", s.LastFetchError)
+	}
+	if !s.LastUpgrade.IsZero() {
+		line("last upgrade", s.LastUpgrade.Local().Format(time.RFC3339))
+	}
+	if s.LastError != "" {
+		line("last error", s.LastError)
+	}
+	line("restarting", s.Restarting)
+	line("restart queued", s.RestartQueued)
+	line("restart deferred", s.RestartDeferred)
+	line("paused", s.Paused)
+	return nil
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Second)
+}
+
+// history prints the history, then polls for
+// newer events until interrupted when following
+func (c *client) history(follow bool) error {
+	var last time.Time
+	for {
+		b, err := c.do(http.MethodGet, "/history")
+		if err != nil {
+			return err
+		}
+		h := overseer.History{}
+		if err := json.Unmarshal(b, &h); err != nil {
+			return fmt.Errorf("invalid history (%w)", err)
+		}
+		for _, e := range h {
+			if !e.Time.After(last) {
+				continue
+			}
+			printEvent(e)
+			last = e.Time
+		}
+		if !follow {
+			return nil
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func printEvent(e overseer.Event) {
+	hash := e.Hash
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	fields := []string{e.Time.Local().Format(time.RFC3339), fmt.Sprintf("%-8s", e.Type), hash}
+	if e.SlaveID != 0 {
+		fields = append(fields, fmt.Sprintf("program=%d", e.SlaveID))
+	}
+	if e.Version != "" {
+		fields = append(fields, "version="+e.Version)
+	}
+	if e.Reason != "" {
+		fields = append(fields, "reason="+e.Reason)
+	}
+	if e.Duration > 0 {
+		fields = append(fields, "duration="+e.Duration.Round(time.Millisecond).String())
+	}
+	if e.Error != "" {
+		fields = append(fields, "error="+e.Error)
+	}
+	fmt.Println(strings.Join(fields, " "))
+}
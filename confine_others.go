@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package overseer
+
+import "os/exec"
+
+// confine is not supported on this platform
+func (mp *master) confine(cmd *exec.Cmd) {}
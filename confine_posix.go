@@ -0,0 +1,34 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package overseer
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// confine runs the slave as Config.User, within Config.Chroot
+// and Config.MountNamespace
+func (mp *master) confine(cmd *exec.Cmd) {
+	attr := &syscall.SysProcAttr{}
+	if c := mp.credential; c != nil {
+		attr.Credential = &syscall.Credential{Uid: c.uid, Gid: c.gid, Groups: c.groups}
+	}
+	if mp.Config.Chroot != "" {
+		attr.Chroot = mp.Config.Chroot
+		//paths are resolved within the new root
+		if rel, err := filepath.Rel(mp.Config.Chroot, cmd.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			cmd.Path = filepath.Join("/", rel)
+		}
+		if cmd.Dir == "" {
+			cmd.Dir = "/"
+		}
+	}
+	if mp.Config.MountNamespace {
+		unshareMounts(attr)
+	}
+	cmd.SysProcAttr = attr
+}
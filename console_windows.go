@@ -0,0 +1,76 @@
+//go:build windows
+// +build windows
+
+package overseer
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+var setConsoleCtrlHandler = kernel32.NewProc("SetConsoleCtrlHandler")
+
+// console control events, delivered to each process of the console
+const (
+	ctrlCEvent        = 0
+	ctrlBreakEvent    = 1
+	ctrlCloseEvent    = 2
+	ctrlLogoffEvent   = 5
+	ctrlShutdownEvent = 6
+)
+
+// consoleCloseTimeout is how long the handler of a close, logoff or
+// shutdown event waits, as the system terminates the process once
+// it returns, or 5 seconds after a close
+const consoleCloseTimeout = 5 * time.Second
+
+// setupConsole stops the master gracefully on CTRL+c and CTRL+break,
+// and when its console is closed, the user logs off or the system
+// shuts down, as it would on SIGINT or SIGTERM elsewhere
+func (mp *master) setupConsole() {
+	handler := syscall.NewCallback(func(event uint32) uintptr {
+		switch event {
+		case ctrlCEvent, ctrlBreakEvent:
+			mp.debugf("console interrupt")
+			go mp.handleSignal(os.Interrupt)
+			return 1
+		case ctrlLogoffEvent:
+			if service != nil {
+				//services outlive the session of the user
+				return 1
+			}
+			fallthrough
+		case ctrlCloseEvent, ctrlShutdownEvent:
+			mp.debugf("console closed")
+			go mp.handleSignal(SIGTERM)
+			//the master exits the process, unless run with a context
+			select {
+			case <-mp.stopped:
+			case <-time.After(consoleCloseTimeout):
+			}
+			return 1
+		}
+		return 0
+	})
+	if r, _, err := setConsoleCtrlHandler.Call(handler, 1); r == 0 {
+		mp.warnf("failed to set console control handler (%s)", err)
+	}
+}
+
+// ignoreConsole leaves the console control events to the master,
+// which shares the console with the program and stops it over the
+// signal pipe, instead of the program exiting on CTRL+c
+func (sp *slave) ignoreConsole() {
+	handler := syscall.NewCallback(func(event uint32) uintptr {
+		switch event {
+		case ctrlCloseEvent, ctrlShutdownEvent:
+			//awaiting the master, see setupConsole
+			time.Sleep(consoleCloseTimeout)
+		}
+		return 1
+	})
+	if r, _, err := setConsoleCtrlHandler.Call(handler, 1); r == 0 {
+		sp.warnf("failed to set console control handler (%s)", err)
+	}
+}
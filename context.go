@@ -0,0 +1,139 @@
+package overseer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/signal"
+
+	"github.com/menglh/overseer/fetcher"
+)
+
+// errExited stops the fork loop once the master exited, see RunContext
+var errExited = errors.New("master exited")
+
+// RunContext executes overseer until the context is done, for embedding
+// applications which handle signals themselves, and in tests. Once it is
+// done, the master stops the program as on SIGTERM, while the fetches
+// are cancelled, see fetcher.ContextFetcher, and returns the context's error
+// rather than exiting the process. It returns an error when the program
+// exits unsuccessfully, and nil when it succeeds. In the program itself,
+// the context being done shuts the program down gracefully, as with a
+// restart. As with RunErr, errors are returned rather than falling back
+// to running the program directly.
+func RunContext(ctx context.Context, c Config) error {
+	return runErr(ctx, &c)
+}
+
+// RunContext executes overseer until the context is done, see RunContext
+func (o *Overseer) RunContext(ctx context.Context) error {
+	return RunContext(ctx, o.config)
+}
+
+// done is closed once the context of RunContext is done,
+// it is nil, and so never closed, for Run
+func (mp *master) done() <-chan struct{} {
+	if mp.ctx == nil {
+		return nil
+	}
+	return mp.ctx.Done()
+}
+
+// fetchNext calls the Fetcher, with the context of RunContext
+// when the Fetcher may be cancelled
+func (mp *master) fetchNext() (io.Reader, error) {
+	if f, ok := mp.Config.Fetcher.(fetcher.ContextFetcher); ok && mp.ctx != nil {
+		return f.FetchContext(mp.ctx)
+	}
+	return mp.Config.Fetcher.Fetch()
+}
+
+// cancelled reports the error of the context once it is done
+func (mp *master) cancelled() error {
+	if mp.ctx == nil {
+		return nil
+	}
+	return mp.ctx.Err()
+}
+
+// watchContext stops the program once the context is done, the
+// fetches are cancelled by the context itself, see fetch
+func (mp *master) watchContext() {
+	done := mp.done()
+	if done == nil {
+		return
+	}
+	go func() {
+		select {
+		case <-done:
+		case <-mp.stopped:
+			return
+		}
+		mp.debugf("context done (%s), stopping", mp.ctx.Err())
+		select {
+		case mp.signals <- SIGTERM:
+		case <-mp.stopped:
+		}
+	}()
+}
+
+// forkLoopContext runs the fork loop until the master exits,
+// returning rather than exiting the process, see RunContext
+func (mp *master) forkLoopContext() error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- mp.forkLoop()
+	}()
+	select {
+	case err := <-errs:
+		if err != errExited {
+			return err
+		}
+	case <-mp.stopped:
+	}
+	<-mp.stopped
+	if err := mp.cancelled(); err != nil {
+		return err
+	}
+	if mp.exitCode != 0 {
+		return fmt.Errorf("program exited with %d", mp.exitCode)
+	}
+	return nil
+}
+
+// hasExited reports whether the master exited, though it was
+// run by RunContext and so the process continues
+func (mp *master) hasExited() bool {
+	if mp.stopped == nil {
+		return false
+	}
+	select {
+	case <-mp.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// returnExit releases what the master holds, as the process
+// continues once RunContext returns the exit code
+func (mp *master) returnExit(code int) {
+	mp.exitOnce.Do(func() {
+		signal.Stop(mp.signals)
+		for _, c := range mp.servers {
+			c.Close()
+		}
+		for _, f := range mp.socketFiles() {
+			f.Close()
+		}
+		mp.exitCode = code
+		close(mp.stopped)
+	})
+}
+
+// serving records a server of the master, closed
+// once it exits when run by RunContext
+func (mp *master) serving(c io.Closer) {
+	mp.servers = append(mp.servers, c)
+}
@@ -0,0 +1,105 @@
+package overseer
+
+import (
+	"context"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/menglh/overseer/coordinator"
+	"github.com/menglh/overseer/fetcher"
+)
+
+func TestWatchContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mp := &master{Config: &Config{}, ctx: ctx, signals: make(chan os.Signal, 1), stopped: make(chan bool)}
+	mp.watchContext()
+	cancel()
+	//the program is stopped as on SIGTERM
+	select {
+	case s := <-mp.signals:
+		if s != SIGTERM {
+			t.Errorf("got %s, expected SIGTERM", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("not stopped once the context is done")
+	}
+	if mp.hasExited() {
+		t.Fatal("exited before the program")
+	}
+	mp.returnExit(2)
+	if !mp.hasExited() || mp.exitCode != 2 {
+		t.Errorf("exited %v with %d", mp.hasExited(), mp.exitCode)
+	}
+	if err := mp.cancelled(); err != context.Canceled {
+		t.Errorf("got %v, expected the error of the context", err)
+	}
+}
+
+func TestTriggerFetchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	mp := &master{Config: &Config{Fetcher: &fetcher.File{Path: "app"}}, ctx: ctx}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- mp.triggerFetch()
+	}()
+	cancel()
+	select {
+	case err := <-errs:
+		if err != context.Canceled {
+			t.Errorf("got %v, expected the error of the context", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("still waiting for the fetch once cancelled")
+	}
+}
+
+func TestSignalsStopped(t *testing.T) {
+	mp := &master{Config: &Config{}, ctx: context.Background()}
+	mp.setupSignalling()
+	mp.returnExit(0)
+	time.Sleep(10 * time.Millisecond)
+	//handled no longer, and not closed either
+	select {
+	case mp.signals <- os.Interrupt:
+	default:
+		t.Fatal("the signals are not buffered")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if len(mp.signals) != 1 {
+		t.Error("signal handled once the master exited")
+	}
+}
+
+func TestPublishVarsReplaced(t *testing.T) {
+	publishVars(func() interface{} { return "first" })
+	publishVars(func() interface{} { return "second" })
+	if got := expvar.Get(expvarName).String(); got != `"second"` {
+		t.Errorf("got %s, expected the variables of the latest master", got)
+	}
+}
+
+func TestPermitCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"permit":false}`))
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	mp := &master{Config: &Config{Coordinator: &coordinator.HTTP{URL: server.URL, ID: "test"}}, ctx: ctx}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- mp.permitUpgrade(make([]byte, 20))
+	}()
+	select {
+	case err := <-errs:
+		if err != context.DeadlineExceeded {
+			t.Errorf("got %v, expected the error of the context", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("still polling the coordinator once cancelled")
+	}
+}
@@ -0,0 +1,105 @@
+package overseer
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/menglh/overseer/coordinator"
+)
+
+// registerInstance announces the current version to the coordinator
+func (mp *master) registerInstance() {
+	if mp.Config.Coordinator == nil {
+		return
+	}
+	if err := mp.Config.Coordinator.Register(hex.EncodeToString(mp.currentHash())); err != nil {
+		mp.warnf("failed to register with coordinator: %s", err)
+	}
+}
+
+// permitUpgrade blocks until the coordinator permits the upgrade,
+// once its rollout is halted the binary is not fetched again
+func (mp *master) permitUpgrade(hash []byte) error {
+	if mp.Config.Coordinator == nil {
+		return nil
+	}
+	mp.debugf("awaiting permission to upgrade")
+	version := hex.EncodeToString(hash)
+	var err error
+	if c, ok := mp.Config.Coordinator.(coordinator.ContextPermitter); ok && mp.ctx != nil {
+		err = c.PermitContext(mp.ctx, version)
+	} else {
+		err = mp.Config.Coordinator.Permit(version)
+	}
+	if errors.Is(err, coordinator.ErrHalted) {
+		mp.reject(hash)
+	}
+	return err
+}
+
+// startProbation marks the given slave as running a freshly upgraded
+// binary, once it has run for UpgradeProbation since it became ready
+// the upgrade succeeds
+func (mp *master) startProbation(slaveID int) {
+	mp.probationMux.Lock()
+	if mp.probationHash == nil {
+		mp.probationMux.Unlock()
+		return
+	}
+	mp.probationSlaveID = slaveID
+	mp.probationStart = time.Now()
+	mp.probationMux.Unlock()
+	go func() {
+		//probation starts once the program is ready
+		if !mp.awaitReady(slaveID) {
+			mp.endProbation(slaveID, errors.New("program not ready"))
+			return
+		}
+		mp.probationMux.Lock()
+		if mp.probationSlaveID == slaveID {
+			mp.probationStart = time.Now()
+		}
+		mp.probationMux.Unlock()
+		time.Sleep(mp.Config.UpgradeProbation)
+		mp.endProbation(slaveID, nil)
+	}()
+}
+
+// endProbation reports the outcome of the upgrade being run by the
+// given slave, the first outcome reported wins
+func (mp *master) endProbation(slaveID int, result error) {
+	mp.probationMux.Lock()
+	hash := mp.probationHash
+	if hash == nil || mp.probationSlaveID != slaveID {
+		mp.probationMux.Unlock()
+		return
+	}
+	mp.probationHash = nil
+	ran := time.Since(mp.probationStart)
+	mp.probationMux.Unlock()
+	if result == nil {
+		mp.debugf("upgrade succeeded (%x)", hash[:12])
+	} else {
+		mp.warnf("upgrade failed (%x): %s", hash[:12], result)
+		event := Event{Type: EventFailure, SlaveID: slaveID, Error: result.Error(), Duration: ran}
+		event.Hash, event.Version = mp.binary()
+		mp.emit(event)
+	}
+	if mp.Config.Coordinator == nil {
+		return
+	}
+	version := hex.EncodeToString(hash)
+	if err := mp.Config.Coordinator.Report(version, result); err != nil {
+		mp.warnf("failed to report upgrade to coordinator: %s", err)
+	}
+	if result == nil {
+		mp.registerInstance()
+	}
+}
+
+// slaveCrashed ends probation of the given slave as a failure
+func (mp *master) slaveCrashed(slaveID, code int) {
+	mp.endProbation(slaveID, fmt.Errorf("program exited with %d", code))
+}
@@ -0,0 +1,52 @@
+// Package coordinator provides fleet wide coordination of
+// overseer upgrades, so new versions are rolled out in waves
+// and a failing version halts the rollout.
+package coordinator
+
+import (
+	"context"
+	"errors"
+)
+
+// Interface defines the required coordinator functions.
+// Versions are the hex encoded SHA-1 hash of the binary,
+// as found in overseer.State.ID.
+type Interface interface {
+	//Register announces this instance and the version it
+	//is currently running. It is called on start and after
+	//each upgrade.
+	Register(version string) error
+	//Permit should block until this instance may upgrade to
+	//the given version. It should return ErrHalted if the
+	//rollout of this version has been halted.
+	Permit(version string) error
+	//Report records the outcome of an upgrade to the given
+	//version. A nil result marks the upgrade as successful.
+	Report(version string, result error) error
+}
+
+// ContextPermitter is implemented by coordinators whose Permit may
+// be cancelled, it returns the error of the context once it is done.
+// It is used by overseer.RunContext, with its context.
+type ContextPermitter interface {
+	PermitContext(ctx context.Context, version string) error
+}
+
+// ErrHalted is returned by Permit when a rollout has been halted
+var ErrHalted = errors.New("rollout halted")
+
+// report is the JSON payload common to all coordinator requests
+type report struct {
+	ID      string `json:"id"`
+	Group   string `json:"group"`
+	Version string `json:"version"`
+	//report only
+	Success bool   `json:"success,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// permit is the JSON response to permit requests
+type permit struct {
+	Permit bool `json:"permit"`
+	Halted bool `json:"halted"`
+}
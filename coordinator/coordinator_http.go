@@ -0,0 +1,151 @@
+package coordinator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP coordinates with a coordinator Server over HTTP.
+// Requests are JSON encoded POSTs to URL + "/register",
+// URL + "/permit" and URL + "/report".
+type HTTP struct {
+	//URL of the coordinator Server
+	URL string
+	//ID uniquely identifies this instance, defaults to the hostname
+	ID string
+	//Group identifies the set of instances being rolled out
+	//together, defaults to the name of the binary
+	Group string
+	//Interval between permit requests, defaults to 10 seconds
+	Interval time.Duration
+	//Client defaults to an http.Client with a 10 second timeout
+	Client *http.Client
+	//internal state
+	init    sync.Once
+	initErr error
+}
+
+// setup is called from both the fetch and probation goroutines
+func (h *HTTP) setup() error {
+	h.init.Do(func() {
+		h.initErr = h.defaults()
+	})
+	return h.initErr
+}
+
+func (h *HTTP) defaults() error {
+	if h.URL == "" {
+		return errors.New("URL required")
+	}
+	h.URL = strings.TrimSuffix(h.URL, "/")
+	if h.ID == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("ID required (%w)", err)
+		}
+		h.ID = host
+	}
+	if h.Group == "" {
+		if p, err := os.Executable(); err == nil {
+			h.Group = strings.TrimSuffix(filepath.Base(p), ".exe")
+		}
+	}
+	if h.Interval <= 0 {
+		h.Interval = 10 * time.Second
+	}
+	if h.Client == nil {
+		h.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return nil
+}
+
+// Register this instance with the coordinator
+func (h *HTTP) Register(version string) error {
+	if err := h.setup(); err != nil {
+		return err
+	}
+	return h.post(context.Background(), "/register", h.report(version), nil)
+}
+
+// Permit polls the coordinator until this instance may upgrade
+func (h *HTTP) Permit(version string) error {
+	return h.PermitContext(context.Background(), version)
+}
+
+// PermitContext polls the coordinator until this instance may
+// upgrade, or ctx is done, see ContextPermitter
+func (h *HTTP) PermitContext(ctx context.Context, version string) error {
+	if err := h.setup(); err != nil {
+		return err
+	}
+	for {
+		p := permit{}
+		if err := h.post(ctx, "/permit", h.report(version), &p); err != nil {
+			return err
+		}
+		if p.Halted {
+			return ErrHalted
+		}
+		if p.Permit {
+			return nil
+		}
+		select {
+		case <-time.After(h.Interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Report the outcome of an upgrade to the coordinator
+func (h *HTTP) Report(version string, result error) error {
+	if err := h.setup(); err != nil {
+		return err
+	}
+	r := h.report(version)
+	if result == nil {
+		r.Success = true
+	} else {
+		r.Error = result.Error()
+	}
+	return h.post(context.Background(), "/report", r, nil)
+}
+
+func (h *HTTP) report(version string) report {
+	return report{ID: h.ID, Group: h.Group, Version: version}
+}
+
+func (h *HTTP) post(ctx context.Context, path string, body interface{}, out interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", h.URL+path, bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("%s request failed (%w)", path, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s request failed (%w)", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s request failed (status code %d)", path, resp.StatusCode)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("%s invalid response (%w)", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package coordinator
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an http.Handler which rolls out new versions in waves.
+// Each wave permits a percentage of the registered instances in a
+// group to upgrade. Once every permitted instance has reported
+// success the next wave begins, and a single failure halts the
+// rollout of that version for the whole group.
+type Server struct {
+	//Waves are the cumulative fractions of each group permitted to
+	//upgrade in each wave. Defaults to 0.1, 0.5, 1.
+	Waves []float64
+	//Expiry removes instances which have not registered within
+	//this duration. Disabled by default.
+	Expiry time.Duration
+	//internal state
+	mut    sync.Mutex
+	groups map[string]*group
+}
+
+type group struct {
+	instances map[string]*instance
+	rollouts  map[string]*rollout
+}
+
+type instance struct {
+	version string
+	seen    time.Time
+}
+
+type rollout struct {
+	wave      int
+	permitted map[string]bool
+	succeeded map[string]bool
+	halted    bool
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	req := report{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.ID == "" || req.Version == "" {
+		http.Error(w, "id and version required", http.StatusBadRequest)
+		return
+	}
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	g := s.group(req.Group)
+	inst, ok := g.instances[req.ID]
+	if !ok {
+		inst = &instance{}
+		g.instances[req.ID] = inst
+	}
+	inst.seen = time.Now()
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/register"):
+		inst.version = req.Version
+	case strings.HasSuffix(r.URL.Path, "/permit"):
+		json.NewEncoder(w).Encode(s.permit(g, req))
+		return
+	case strings.HasSuffix(r.URL.Path, "/report"):
+		if req.Success {
+			inst.version = req.Version
+		}
+		s.report(g, req)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte("{}"))
+}
+
+func (s *Server) group(name string) *group {
+	if s.groups == nil {
+		s.groups = map[string]*group{}
+	}
+	g, ok := s.groups[name]
+	if !ok {
+		g = &group{
+			instances: map[string]*instance{},
+			rollouts:  map[string]*rollout{},
+		}
+		s.groups[name] = g
+	}
+	if s.Expiry > 0 {
+		for id, i := range g.instances {
+			if time.Since(i.seen) > s.Expiry {
+				delete(g.instances, id)
+			}
+		}
+	}
+	return g
+}
+
+func (s *Server) waves() []float64 {
+	if len(s.Waves) == 0 {
+		return []float64{0.1, 0.5, 1}
+	}
+	return s.Waves
+}
+
+func (s *Server) rollout(g *group, version string) *rollout {
+	r, ok := g.rollouts[version]
+	if !ok {
+		r = &rollout{
+			permitted: map[string]bool{},
+			succeeded: map[string]bool{},
+		}
+		g.rollouts[version] = r
+	}
+	return r
+}
+
+func (s *Server) permit(g *group, req report) permit {
+	r := s.rollout(g, req.Version)
+	if r.halted {
+		return permit{Halted: true}
+	}
+	if r.permitted[req.ID] {
+		return permit{Permit: true}
+	}
+	//advance to the next wave once the current has succeeded
+	waves := s.waves()
+	for r.wave < len(waves)-1 && len(r.permitted) > 0 && len(r.succeeded) == len(r.permitted) &&
+		len(r.permitted) >= s.allowed(g, waves[r.wave]) {
+		r.wave++
+	}
+	if len(r.permitted) < s.allowed(g, waves[r.wave]) {
+		r.permitted[req.ID] = true
+		return permit{Permit: true}
+	}
+	return permit{}
+}
+
+// allowed is the number of instances permitted by a wave
+func (s *Server) allowed(g *group, wave float64) int {
+	n := int(math.Ceil(wave * float64(len(g.instances))))
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+func (s *Server) report(g *group, req report) {
+	r := s.rollout(g, req.Version)
+	if req.Success {
+		r.succeeded[req.ID] = true
+	} else {
+		r.halted = true
+	}
+}
@@ -0,0 +1,26 @@
+package overseer
+
+import (
+	"testing"
+
+	"github.com/menglh/overseer/coordinator"
+)
+
+// halted is a coordinator which halted every rollout
+type halted struct{}
+
+func (halted) Register(version string) error             { return nil }
+func (halted) Permit(version string) error               { return coordinator.ErrHalted }
+func (halted) Report(version string, result error) error { return nil }
+
+func TestPermitHalted(t *testing.T) {
+	mp := &master{Config: &Config{Coordinator: halted{}}}
+	hash := []byte("0123456789abcdefghij")
+	if err := mp.permitUpgrade(hash); err != coordinator.ErrHalted {
+		t.Fatalf("got %v, expected ErrHalted", err)
+	}
+	//not fetched again by the next poll
+	if !mp.isRejected(hash) {
+		t.Error("the halted version was not rejected")
+	}
+}
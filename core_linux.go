@@ -0,0 +1,84 @@
+package overseer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// corePath finds the core dump of the process, as named by
+// core_pattern(5), it is unknown when piped to a program.
+// Relative paths are within the program's working directory.
+func corePath(pid int, root, dir string) string {
+	b, err := ioutil.ReadFile("/proc/sys/kernel/core_pattern")
+	if err != nil {
+		return ""
+	}
+	pattern := strings.TrimSpace(string(b))
+	if pattern == "" || strings.HasPrefix(pattern, "|") {
+		return ""
+	}
+	exe, _ := os.Executable()
+	host, _ := os.Hostname()
+	path := ""
+	glob := false
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '%' || i+1 == len(pattern) {
+			path += string(pattern[i])
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case '%':
+			path += "%"
+		case 'p', 'P':
+			path += strconv.Itoa(pid)
+		case 'e':
+			//the kernel truncates the name
+			name := filepath.Base(exe)
+			if len(name) > 15 {
+				name = name[:15]
+			}
+			path += name
+		case 'h':
+			path += host
+		default:
+			path += "*"
+			glob = true
+		}
+	}
+	if !strings.Contains(pattern, "%p") {
+		if b, _ := ioutil.ReadFile("/proc/sys/kernel/core_uses_pid"); strings.TrimSpace(string(b)) == "1" {
+			path += "." + strconv.Itoa(pid)
+		}
+	}
+	if !filepath.IsAbs(path) {
+		if dir == "" && root != "" {
+			dir = "/"
+		} else if dir == "" {
+			dir, _ = os.Getwd()
+		}
+		path = filepath.Join(dir, path)
+	}
+	if root != "" {
+		path = filepath.Join(root, path)
+	}
+	if !glob {
+		if _, err := os.Stat(path); err != nil {
+			return ""
+		}
+		return path
+	}
+	//the most recent match
+	matches, _ := filepath.Glob(path)
+	newest := ""
+	var newestTime int64
+	for _, m := range matches {
+		if info, err := os.Stat(m); err == nil && info.ModTime().UnixNano() > newestTime {
+			newest, newestTime = m, info.ModTime().UnixNano()
+		}
+	}
+	return newest
+}
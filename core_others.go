@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package overseer
+
+// corePath is only known on linux
+func corePath(pid int, root, dir string) string {
+	return ""
+}
@@ -0,0 +1,148 @@
+package overseer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// crashStderrSize is how much of the end of
+// the program's stderr is kept for a CrashReport
+const crashStderrSize = 16 << 10
+
+// CrashReport describes a program which exited unexpectedly, for crash
+// reporting services such as Sentry, see Config.CrashReporter
+type CrashReport struct {
+	ProgramExit
+	Time    time.Time
+	Host    string
+	Binary  string
+	Version string
+	//Hash is the hex encoded SHA256 sum of the binary
+	Hash string
+	//Uptime is how long the program ran before it exited
+	Uptime time.Duration
+	//Stderr is the end of the program's stderr, up to 16KB
+	Stderr []byte
+	//Panic is the line of Stderr reporting a Go panic or
+	//fatal error, such as "panic: runtime error: ...", if any
+	Panic string
+}
+
+// crashCopyTimeout is how long the crash report awaits the rest of
+// stderr after the program exited, processes it started may hold it
+const crashCopyTimeout = time.Second
+
+// stderrTail keeps the end of the program's stderr
+type stderrTail struct {
+	mux     sync.Mutex
+	buf     []byte
+	started time.Time
+	//copied is closed once the program's stderr is closed
+	copied chan bool
+}
+
+func (t *stderrTail) Write(p []byte) (int, error) {
+	t.mux.Lock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > 2*crashStderrSize {
+		t.buf = append([]byte{}, t.buf[len(t.buf)-crashStderrSize:]...)
+	}
+	t.mux.Unlock()
+	return len(p), nil
+}
+
+func (t *stderrTail) bytes() []byte {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	b := t.buf
+	if len(b) > crashStderrSize {
+		b = b[len(b)-crashStderrSize:]
+	}
+	return append([]byte{}, b...)
+}
+
+// captureStderr returns the stderr of the program, which is also
+// captured for its CrashReport when there is a CrashReporter
+func (mp *master) captureStderr(slaveID int) (io.Writer, *stderrTail) {
+	if mp.Config.CrashReporter == nil || mp.Config.Terminal {
+		return mp.stderr, nil
+	}
+	t := &stderrTail{started: time.Now()}
+	mp.crashMux.Lock()
+	if mp.stderrTails == nil {
+		mp.stderrTails = map[int]*stderrTail{}
+	}
+	mp.stderrTails[slaveID] = t
+	mp.crashMux.Unlock()
+	return &teeWriter{w: mp.stderr, tee: t}, t
+}
+
+// teeWriter writes to w and the tee, ignoring the errors of the
+// tee, and of w so that a failing output still captures stderr
+type teeWriter struct {
+	w, tee io.Writer
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	t.tee.Write(p)
+	t.w.Write(p)
+	return len(p), nil
+}
+
+// takeStderr returns the captured stderr of the program, once it exited
+func (mp *master) takeStderr(slaveID int) *stderrTail {
+	mp.crashMux.Lock()
+	defer mp.crashMux.Unlock()
+	t := mp.stderrTails[slaveID]
+	delete(mp.stderrTails, slaveID)
+	return t
+}
+
+// exitedUnexpectedly reports whether the program failed,
+// rather than exited as it was replaced or stopped
+func (mp *master) exitedUnexpectedly(e ProgramExit) bool {
+	return !e.Replaced && !mp.isStopping() && e.Code != 0
+}
+
+// reportCrash passes the unexpected exit to Config.CrashReporter,
+// before the master exits with it
+func (mp *master) reportCrash(e ProgramExit) {
+	t := mp.takeStderr(e.SlaveID)
+	if t == nil || !mp.exitedUnexpectedly(e) {
+		return
+	}
+	if t.copied != nil {
+		select {
+		case <-t.copied:
+		case <-time.After(crashCopyTimeout):
+		}
+	}
+	r := CrashReport{ProgramExit: e, Time: time.Now(), Binary: mp.binName, Uptime: time.Since(t.started), Stderr: t.bytes()}
+	r.Host, _ = os.Hostname()
+	r.Hash, r.Version = mp.binary()
+	r.Panic = panicLine(r.Stderr)
+	mp.Config.CrashReporter(r)
+}
+
+// panicLine finds the last line reporting a panic or fatal error
+func panicLine(stderr []byte) string {
+	for _, prefix := range []string{"panic: ", "fatal error: "} {
+		i := bytes.LastIndex(stderr, []byte("\n"+prefix))
+		if i >= 0 {
+			i++
+		} else if bytes.HasPrefix(stderr, []byte(prefix)) {
+			i = 0
+		} else {
+			continue
+		}
+		line := stderr[i:]
+		if j := bytes.IndexByte(line, '\n'); j >= 0 {
+			line = line[:j]
+		}
+		return string(bytes.TrimSpace(line))
+	}
+	return ""
+}
@@ -0,0 +1,83 @@
+package overseer
+
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+)
+
+// credential is the user and groups the program runs as, see Config.User
+type credential struct {
+	uid, gid uint32
+	groups   []uint32
+}
+
+// lookupCredential resolves the user and groups of the program
+func (mp *master) lookupCredential() error {
+	c := mp.Config
+	if c.User == "" && c.Group == "" && len(c.Groups) == 0 {
+		return nil
+	}
+	cred := &credential{}
+	groups := c.Groups
+	if c.User != "" {
+		u, err := lookupUser(c.User)
+		if err != nil {
+			return fmt.Errorf("overseer.Config.User %s not found (%w)", c.User, err)
+		}
+		uid, err1 := strconv.ParseUint(u.Uid, 10, 32)
+		gid, err2 := strconv.ParseUint(u.Gid, 10, 32)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("overseer.Config.User %s has no numeric uid", c.User)
+		}
+		cred.uid, cred.gid = uint32(uid), uint32(gid)
+		if groups == nil {
+			if groups, err = u.GroupIds(); err != nil {
+				return fmt.Errorf("failed to list groups of %s (%w)", c.User, err)
+			}
+		}
+	} else {
+		cred.uid, cred.gid = uint32(uid), uint32(gid)
+	}
+	if c.Group != "" {
+		gid, err := lookupGroup(c.Group)
+		if err != nil {
+			return fmt.Errorf("overseer.Config.Group %s not found (%w)", c.Group, err)
+		}
+		cred.gid = gid
+	}
+	for _, g := range groups {
+		gid, err := lookupGroup(g)
+		if err != nil {
+			return fmt.Errorf("overseer.Config.Groups %s not found (%w)", g, err)
+		}
+		cred.groups = append(cred.groups, gid)
+	}
+	mp.credential = cred
+	mp.debugf("program runs as uid %d, gid %d", cred.uid, cred.gid)
+	return nil
+}
+
+// lookupUser finds the user by name or uid
+func lookupUser(name string) (*user.User, error) {
+	if _, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return user.LookupId(name)
+	}
+	return user.Lookup(name)
+}
+
+// lookupGroup finds the gid of the group, by name or gid
+func lookupGroup(name string) (uint32, error) {
+	if gid, err := strconv.ParseUint(name, 10, 32); err == nil {
+		return uint32(gid), nil
+	}
+	g, err := user.LookupGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(gid), nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd && !windows
+// +build !linux,!darwin,!freebsd,!windows
+
+package overseer
+
+// daemonize is not supported on this platform
+func (mp *master) daemonize() (bool, error) {
+	return false, nil
+}
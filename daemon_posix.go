@@ -0,0 +1,60 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package overseer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// envDaemon is the stage of daemonizing reached by the process
+const envDaemon = "OVERSEER_DAEMON"
+
+// daemonize detaches the master from the terminal, see Config.Daemonize.
+// The process starts itself in a new session, which starts the master,
+// so that it may never acquire a controlling terminal. Reports whether
+// this process is done and should exit.
+func (mp *master) daemonize() (bool, error) {
+	if !mp.Config.Daemonize {
+		return false, nil
+	}
+	stage := os.Getenv(envDaemon)
+	if stage == "2" {
+		os.Unsetenv(envDaemon)
+		return false, nil
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return false, fmt.Errorf("failed to find executable (%w)", err)
+	}
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer null.Close()
+	out := null
+	if mp.Config.DaemonLog != "" {
+		if out, err = os.OpenFile(mp.Config.DaemonLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644); err != nil {
+			return false, fmt.Errorf("failed to open daemon log (%w)", err)
+		}
+		defer out.Close()
+	}
+	cmd := exec.Command(exe)
+	cmd.Args = os.Args
+	cmd.Stdin = null
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if stage == "" {
+		cmd.Env = append(os.Environ(), envDaemon+"=1")
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	} else {
+		cmd.Env = append(os.Environ(), envDaemon+"=2")
+	}
+	if err := cmd.Start(); err != nil {
+		return false, fmt.Errorf("failed to daemonize (%w)", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,6 @@
+package overseer
+
+// daemonize is not supported on windows, see Config.WindowsService
+func (mp *master) daemonize() (bool, error) {
+	return false, nil
+}
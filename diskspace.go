@@ -0,0 +1,46 @@
+package overseer
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/menglh/overseer/fetcher"
+)
+
+// DiskSpaceError is returned when there is insufficient disk
+// space to stage or install a fetched binary, its cause is
+// ErrFetchFailed. See FetchError.
+type DiskSpaceError struct {
+	Dir       string
+	Required  uint64
+	Available uint64
+}
+
+func (e *DiskSpaceError) Error() string {
+	return fmt.Sprintf("insufficient disk space in %s (%d bytes required, %d available)", e.Dir, e.Required, e.Available)
+}
+
+// checkDiskSpace ensures the staging and binary directories can
+// hold the fetched binary, when its size is known in advance. With
+// InMemory neither is written, the binary is held in memory instead.
+func (mp *master) checkDiskSpace(r interface{}) error {
+	if mp.Config.InMemory {
+		return nil
+	}
+	d, ok := r.(fetcher.Describer)
+	if !ok || d.Info().Size <= 0 {
+		return nil //unknown size
+	}
+	required := uint64(d.Info().Size)
+	for _, dir := range []string{filepath.Dir(mp.tmpBinPath), filepath.Dir(mp.binPath)} {
+		available, err := freeSpace(dir)
+		if err != nil {
+			mp.debugf("failed to check disk space: %s", err)
+			continue
+		}
+		if available < required {
+			return failed(ErrFetchFailed, "%w", &DiskSpaceError{Dir: dir, Required: required, Available: available})
+		}
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package overseer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/menglh/overseer/fetcher"
+)
+
+func TestDiskSpaceInMemory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overseer-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	//neither directory is written, whatever their free space
+	mp := &master{Config: &Config{InMemory: true}, binPath: filepath.Join(dir, "app"), tmpBinPath: filepath.Join(dir, "app.tmp")}
+	r := fetcher.Describe(strings.NewReader(""), fetcher.Info{Size: 1 << 62})
+	if err := mp.checkDiskSpace(r); err != nil {
+		t.Errorf("checked the disk space of an InMemory binary (%s)", err)
+	}
+}
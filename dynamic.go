@@ -0,0 +1,295 @@
+package overseer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	envDynamicAddrs  = "OVERSEER_DYNAMIC_ADDRESSES"
+	envControlFDs    = "OVERSEER_CONTROL_FDS"
+	envMasterDynamic = "OVERSEER_MASTER_DYNAMIC"
+)
+
+// listenerRequest is sent by the program to the master
+type listenerRequest struct {
+	Op      string `json:"op"`
+	Address string `json:"address"`
+	Reason  string `json:"reason,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Pid     int    `json:"pid,omitempty"`
+	State   []byte `json:"state,omitempty"`
+}
+
+// listenerResponse is the master's reply to a listenerRequest
+type listenerResponse struct {
+	Error   string        `json:"error,omitempty"`
+	Status  RestartStatus `json:"status,omitempty"`
+	Version int           `json:"version,omitempty"`
+	Pid     int           `json:"pid,omitempty"`
+	//Metadata and State are passed in reply to "hello"
+	Metadata map[string]string `json:"metadata,omitempty"`
+	State    []byte            `json:"state,omitempty"`
+	Usage    *Usage            `json:"usage,omitempty"`
+	Metrics  []byte            `json:"metrics,omitempty"`
+	Vars     *masterVars       `json:"vars,omitempty"`
+	Report   *Status           `json:"report,omitempty"`
+	History  History           `json:"history,omitempty"`
+}
+
+// AddListener binds an additional address in the master, for example
+// after the program has reloaded its configuration. Like the sockets
+// of Config.Addresses, it is passed to the program from the next
+// restart onwards, listed in State.Addresses after Config.Addresses.
+// The address is retained by the master until RemoveListener, and
+// across SelfUpgrade re-executions, but not once the master exits.
+func AddListener(addr string) error {
+	if currentProcess != nil {
+		return currentProcess.changeListener("add", addr)
+	}
+	return ErrNotRunning
+}
+
+// RemoveListener closes an address added by AddListener, it is no
+// longer passed to the program from the next restart onwards.
+func RemoveListener(addr string) error {
+	if currentProcess != nil {
+		return currentProcess.changeListener("remove", addr)
+	}
+	return ErrNotRunning
+}
+
+// addresses returns the configured and dynamic addresses, in the
+// order their sockets are passed to the program
+func (mp *master) addresses() []string {
+	mp.listenerMux.Lock()
+	defer mp.listenerMux.Unlock()
+	return append(append([]string{}, mp.Config.Addresses...), mp.dynamicAddrs...)
+}
+
+// socketFiles returns the sockets passed to the program
+func (mp *master) socketFiles() []*os.File {
+	mp.listenerMux.Lock()
+	defer mp.listenerMux.Unlock()
+	return append(append([]*os.File{}, mp.slaveExtraFiles...), mp.dynamicFiles...)
+}
+
+// sockets returns the addresses and their sockets, as passed to the
+// program. The sockets are duplicated, as a listener removed meanwhile
+// is closed, and are closed by the caller with closeFiles.
+func (mp *master) sockets() ([]string, []*os.File, error) {
+	mp.listenerMux.Lock()
+	defer mp.listenerMux.Unlock()
+	addrs := append(append([]string{}, mp.Config.Addresses...), mp.dynamicAddrs...)
+	files := []*os.File{}
+	for _, f := range append(append([]*os.File{}, mp.slaveExtraFiles...), mp.dynamicFiles...) {
+		d, err := dupFile(f)
+		if err != nil {
+			closeFiles(files)
+			return nil, nil, err
+		}
+		files = append(files, d)
+	}
+	return addrs, files, nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+func (mp *master) changeListener(op, addr string) error {
+	if mp.Config.ReusePort {
+		return errors.New("dynamic listeners are not supported with ReusePort")
+	}
+	network, address := parseAddress(addr)
+	if op == "remove" {
+		if containsAddress(mp.Config.Addresses, addr) {
+			return fmt.Errorf("%s is configured in Addresses", addr)
+		}
+		mp.listenerMux.Lock()
+		defer mp.listenerMux.Unlock()
+		for i, a := range mp.dynamicAddrs {
+			if a == addr {
+				mp.dynamicFiles[i].Close()
+				mp.dynamicAddrs = append(mp.dynamicAddrs[:i], mp.dynamicAddrs[i+1:]...)
+				mp.dynamicFiles = append(mp.dynamicFiles[:i], mp.dynamicFiles[i+1:]...)
+				mp.debugf("removed listener %s", addr)
+				return nil
+			}
+		}
+		return fmt.Errorf("%s is not listening", addr)
+	}
+	if network == "unix" && isAbstract(address) && runtime.GOOS != "linux" {
+		return errors.New("abstract unix sockets are only supported on linux")
+	}
+	mp.listenerMux.Lock()
+	defer mp.listenerMux.Unlock()
+	if containsAddress(mp.Config.Addresses, addr) || containsAddress(mp.dynamicAddrs, addr) {
+		return fmt.Errorf("%s is already listening", addr)
+	}
+	f, err := mp.listen(addr)
+	if err != nil {
+		return failed(ErrBindFailed, "failed to listen on %s (%w)", addr, err)
+	}
+	mp.dynamicAddrs = append(mp.dynamicAddrs, addr)
+	mp.dynamicFiles = append(mp.dynamicFiles, f)
+	mp.debugf("added listener %s", addr)
+	return nil
+}
+
+// inheritDynamic adopts the dynamic listeners of the previous master
+func (mp *master) inheritDynamic(inherited map[string]*os.File) {
+	v := os.Getenv(envMasterDynamic)
+	os.Unsetenv(envMasterDynamic)
+	if v == "" {
+		return
+	}
+	addrs := []string{}
+	if err := json.Unmarshal([]byte(v), &addrs); err != nil {
+		mp.warnf("invalid %s: %s", envMasterDynamic, err)
+		return
+	}
+	for _, addr := range addrs {
+		if f, ok := inherited[addr]; ok && !containsAddress(mp.Config.Addresses, addr) {
+			mp.dynamicAddrs = append(mp.dynamicAddrs, addr)
+			mp.dynamicFiles = append(mp.dynamicFiles, f)
+			delete(inherited, addr)
+		}
+	}
+}
+
+// controlPipes creates the pipes over which the program requests
+// listener changes, returning the files for the slave
+func (mp *master) controlPipes(peer *controlPeer) ([]*os.File, error) {
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	respR, respW, err := os.Pipe()
+	if err != nil {
+		reqR.Close()
+		reqW.Close()
+		return nil, err
+	}
+	go mp.serveControl(reqR, respW, peer)
+	return []*os.File{reqW, respR}, nil
+}
+
+// serveControl handles the program's requests until it exits
+func (mp *master) serveControl(r, w *os.File, peer *controlPeer) {
+	defer r.Close()
+	defer w.Close()
+	defer func() {
+		peer.mux.Lock()
+		peer.heartbeat = time.Time{}
+		peer.mux.Unlock()
+		mp.removePeer(peer)
+	}()
+	s := bufio.NewScanner(r)
+	enc := json.NewEncoder(w)
+	for s.Scan() {
+		req := listenerRequest{}
+		resp := listenerResponse{}
+		handled := false
+		if err := json.Unmarshal(s.Bytes(), &req); err != nil {
+			resp.Error = "invalid request"
+		} else if resp, handled = mp.handleControl(peer, req); handled {
+			//see the control protocol
+		} else if req.Op != "add" && req.Op != "remove" {
+			resp.Error = "unknown op " + req.Op
+		} else if err := mp.changeListener(req.Op, req.Address); err != nil {
+			resp.Error = err.Error()
+		}
+		mp.tracef("program %d %s: %s", peer.pid, req.Op, outcome(resp.Error))
+		if enc.Encode(resp) != nil {
+			return
+		}
+	}
+}
+
+// dynamicAddresses returns the addresses added
+// by AddListener, passed on by the master
+func dynamicAddresses() []string {
+	addrs := []string{}
+	json.Unmarshal([]byte(os.Getenv(envDynamicAddrs)), &addrs)
+	return addrs
+}
+
+// openControl opens the pipes to the master
+func (sp *slave) openControl() {
+	fds := strings.Split(os.Getenv(envControlFDs), ",")
+	if len(fds) != 2 {
+		return
+	}
+	w, err1 := strconv.Atoi(fds[0])
+	r, err2 := strconv.Atoi(fds[1])
+	if err1 != nil || err2 != nil {
+		return
+	}
+	//not inherited by processes the program starts
+	sp.controlW = os.NewFile(uintptr(w), "control")
+	closeOnExec(sp.controlW)
+	cr := os.NewFile(uintptr(r), "control")
+	closeOnExec(cr)
+	sp.controlR = bufio.NewReader(cr)
+	sp.helloMaster()
+}
+
+func (sp *slave) changeListener(op, addr string) error {
+	if sp.controlW == nil {
+		return errors.New("dynamic listeners are not available")
+	}
+	return sp.control(op, addr)
+}
+
+// control sends the request to the master over the control pipes
+func (sp *slave) control(op, addr string) error {
+	resp, err := sp.request(listenerRequest{Op: op, Address: addr})
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+// request sends the request over the control pipe, returning the reply
+func (sp *slave) request(req listenerRequest) (listenerResponse, error) {
+	sp.controlMux.Lock()
+	defer sp.controlMux.Unlock()
+	resp := listenerResponse{}
+	if sp.controlW == nil {
+		return resp, errors.New("no pipe to master")
+	}
+	b, _ := json.Marshal(req)
+	if _, err := sp.controlW.Write(append(b, '\n')); err != nil {
+		return resp, fmt.Errorf("failed to contact master (%w)", err)
+	}
+	line, err := sp.controlR.ReadBytes('\n')
+	if err != nil {
+		return resp, fmt.Errorf("failed to contact master (%w)", err)
+	}
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return resp, fmt.Errorf("invalid response from master (%w)", err)
+	}
+	sp.tracef("master %s: %s", req.Op, outcome(resp.Error))
+	return resp, nil
+}
+
+// outcome describes the result of a control request, for the traces
+func outcome(err string) string {
+	if err != "" {
+		return err
+	}
+	return "ok"
+}
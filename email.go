@@ -0,0 +1,202 @@
+package overseer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// defaultEmailSubject and defaultEmailBody are the templates of Email
+const (
+	defaultEmailSubject = `[overseer] {{.Summary}}`
+	defaultEmailBody    = `{{range .Events}}{{.Time.Format "2006-01-02 15:04:05 MST"}}  {{.Summary}}
+{{if .Notes}}{{.Notes}}
+
+{{end}}{{end}}{{if .Dropped}}... and {{.Dropped}} more
+{{end}}`
+)
+
+// emailBatchLimit is the most events listed in an email
+const emailBatchLimit = 50
+
+// Email notifies of failed upgrades and crashing programs by email,
+// see Config.Email. The events which occur within BatchInterval of
+// the first are sent in one email, so that a program stuck in a
+// crash loop sends an email listing its crashes, rather than many.
+type Email struct {
+	//Addr is the host:port of the SMTP server
+	Addr string
+	//Auth optionally authenticates with the SMTP server,
+	//for example smtp.PlainAuth
+	Auth smtp.Auth
+	From string
+	To   []string
+	//Events to notify of. Defaults to EventFailure, EventRollback
+	//and EventCrash.
+	Events []EventType
+	//Subject and Body optionally format the email with text/template,
+	//which is given the EmailBatch. Subject defaults to
+	//"[overseer] {{.Summary}}" and Body lists the Summary of each event.
+	Subject string
+	Body    string
+	//BatchInterval is how long to wait for more events after the first,
+	//before the email is sent. Defaults to 1 minute.
+	BatchInterval time.Duration
+}
+
+// EmailBatch is the events sent in one email
+type EmailBatch struct {
+	Host   string
+	Binary string
+	Events []Notification
+	//Dropped is the number of events beyond those listed
+	Dropped int
+}
+
+// Summary describes the first event, and how many followed it
+func (b EmailBatch) Summary() string {
+	if len(b.Events) == 0 {
+		return ""
+	}
+	s := b.Events[0].Summary()
+	if more := len(b.Events) - 1 + b.Dropped; more > 0 {
+		s += fmt.Sprintf(" (and %d more)", more)
+	}
+	return s
+}
+
+// mailer batches the notifications of Config.Email
+type mailer struct {
+	config  *Email
+	subject *template.Template
+	body    *template.Template
+	host    string
+	binary  string
+	mux     sync.Mutex
+	batch   []Notification
+	dropped int
+	timer   *time.Timer
+}
+
+func (e *Email) validate() error {
+	if e.Addr == "" || e.From == "" || len(e.To) == 0 {
+		return errors.New("overseer.Config.Email requires Addr, From and To")
+	}
+	if _, _, err := e.templates(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (e *Email) templates() (subject, body *template.Template, err error) {
+	s, b := e.Subject, e.Body
+	if s == "" {
+		s = defaultEmailSubject
+	}
+	if b == "" {
+		b = defaultEmailBody
+	}
+	if subject, err = template.New("subject").Parse(s); err != nil {
+		return nil, nil, fmt.Errorf("overseer.Config.Email invalid Subject (%w)", err)
+	}
+	if body, err = template.New("body").Parse(b); err != nil {
+		return nil, nil, fmt.Errorf("overseer.Config.Email invalid Body (%w)", err)
+	}
+	return subject, body, nil
+}
+
+func (e *Email) wants(t EventType) bool {
+	if len(e.Events) == 0 {
+		return t == EventFailure || t == EventRollback || t == EventCrash
+	}
+	for _, w := range e.Events {
+		if w == t {
+			return true
+		}
+	}
+	return false
+}
+
+// setupEmail starts batching the notifications of Config.Email
+func (mp *master) setupEmail() {
+	if mp.Config.Email == nil {
+		return
+	}
+	m := &mailer{config: mp.Config.Email, binary: mp.binName}
+	//validated
+	m.subject, m.body, _ = m.config.templates()
+	m.host, _ = os.Hostname()
+	mp.mailer = m
+}
+
+// mail adds the event to the batch, which is
+// sent BatchInterval after its first event
+func (mp *master) mail(e Event) {
+	m := mp.mailer
+	if m == nil || !m.config.wants(e.Type) {
+		return
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if len(m.batch) < emailBatchLimit {
+		m.batch = append(m.batch, Notification{Event: e, Host: m.host, Binary: m.binary})
+	} else {
+		m.dropped++
+	}
+	if m.timer == nil {
+		interval := m.config.BatchInterval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		m.timer = time.AfterFunc(interval, mp.flushEmail)
+	}
+}
+
+// flushEmail sends the batched events, it is called
+// before the master exits so that none are lost
+func (mp *master) flushEmail() {
+	m := mp.mailer
+	if m == nil {
+		return
+	}
+	m.mux.Lock()
+	batch := EmailBatch{Host: m.host, Binary: m.binary, Events: m.batch, Dropped: m.dropped}
+	m.batch, m.dropped = nil, 0
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	m.mux.Unlock()
+	if len(batch.Events) == 0 {
+		return
+	}
+	if err := m.send(batch); err != nil {
+		mp.warnf("failed to send email (%s)", err)
+	}
+}
+
+func (m *mailer) send(batch EmailBatch) error {
+	subject, body := &bytes.Buffer{}, &bytes.Buffer{}
+	if err := m.subject.Execute(subject, batch); err != nil {
+		return err
+	}
+	if err := m.body.Execute(body, batch); err != nil {
+		return err
+	}
+	msg := &bytes.Buffer{}
+	fmt.Fprintf(msg, "From: %s\r\n", m.config.From)
+	fmt.Fprintf(msg, "To: %s\r\n", strings.Join(m.config.To, ", "))
+	//headers may not span lines
+	fmt.Fprintf(msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.Join(strings.Fields(subject.String()), " ")))
+	fmt.Fprintf(msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(msg, "MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.Replace(body.String(), "\n", "\r\n", -1))
+	return smtp.SendMail(m.config.Addr, m.config.Auth, m.config.From, m.config.To, msg.Bytes())
+}
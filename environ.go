@@ -0,0 +1,86 @@
+package overseer
+
+import (
+	"errors"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Environment controls the environment of the program, see Config.Environment
+type Environment struct {
+	//Allow lists the variables of the master passed to the program,
+	//by name or pattern (e.g. "AWS_*", see path.Match). All are passed
+	//when empty.
+	Allow []string
+	//Deny lists the variables of the master never passed to the program,
+	//by name or pattern, even when allowed.
+	Deny []string
+	//Set adds variables to the program's environment,
+	//replacing those of the master.
+	Set map[string]string
+}
+
+func (e *Environment) validate() error {
+	for _, p := range append(append([]string{}, e.Allow...), e.Deny...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.New("overseer.Config.Environment has an invalid pattern " + p)
+		}
+	}
+	return nil
+}
+
+// environ is the environment of the program,
+// before the variables set by overseer
+func (mp *master) environ() []string {
+	env := mp.Config.Environment
+	if env == nil {
+		return os.Environ()
+	}
+	e := []string{}
+	for _, kv := range os.Environ() {
+		name := kv
+		if i := strings.Index(kv, "="); i >= 0 {
+			name = kv[:i]
+		}
+		if _, ok := env.Set[name]; ok {
+			continue
+		}
+		if len(env.Allow) > 0 && !matchName(env.Allow, name) {
+			continue
+		}
+		if matchName(env.Deny, name) {
+			continue
+		}
+		e = append(e, kv)
+	}
+	names := []string{}
+	for name := range env.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		e = append(e, name+"="+env.Set[name])
+	}
+	return e
+}
+
+// hasEnv reports whether the environment sets the variable
+func hasEnv(env []string, name string) bool {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, name+"=") {
+			return true
+		}
+	}
+	return false
+}
+
+func matchName(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package overseer
+
+import (
+	"errors"
+	"fmt"
+)
+
+// The causes of overseer's failures, which may be told apart
+// with errors.Is, while errors.As still finds the underlying
+// error, such as a *SanityCheckError or a *net.OpError
+var (
+	//ErrUnsupportedOS is returned by RunErr when overseer
+	//does not support the operating system
+	ErrUnsupportedOS = errors.New("os not supported")
+	//ErrFetchFailed is the cause of a failure to check
+	//for, or download, a binary, see FetchError
+	ErrFetchFailed = errors.New("fetch failed")
+	//ErrSanityCheckFailed is the cause of a fetched binary
+	//failing the sanity check, see SanityCheckError
+	ErrSanityCheckFailed = errors.New("sanity check failed")
+	//ErrBindFailed is the cause of a failure to bind the socket
+	//of an address, such as an address already in use
+	ErrBindFailed = errors.New("bind failed")
+	//ErrUpgradeRejected is the cause of an upgrade cancelled by
+	//PreUpgrade or PreUpgradeInfo, or not permitted by the Coordinator
+	ErrUpgradeRejected = errors.New("upgrade rejected")
+	//ErrNotRunning is returned by the functions which
+	//need overseer, when called outside of it
+	ErrNotRunning = errors.New("overseer not running")
+	//ErrFetcherDisabled is returned by FetchNow when
+	//there is no Fetcher, or it failed to initialize
+	ErrFetcherDisabled = errors.New("fetcher disabled")
+	//ErrUpgradesPaused is returned by FetchNow between
+	//Pause and Resume
+	ErrUpgradesPaused = errors.New("upgrades paused")
+	//ErrFetchSkipped is returned by FetchNow when the check
+	//was skipped, as a restart was in progress
+	ErrFetchSkipped = errors.New("fetch skipped")
+)
+
+// failure is an error caused by one of the above, it
+// wraps the formatted error, and so whatever it wraps
+type failure struct {
+	cause error
+	err   error
+}
+
+// failed formats the error, whose cause is one of the above
+func failed(cause error, f string, args ...interface{}) error {
+	return &failure{cause: cause, err: fmt.Errorf(f, args...)}
+}
+
+func (f *failure) Error() string {
+	return f.err.Error()
+}
+
+func (f *failure) Unwrap() error {
+	return f.err
+}
+
+func (f *failure) Is(target error) bool {
+	return target == f.cause
+}
+
+// warnFailed logs the formatted error as a warning and returns it, see failed
+func (mp *master) warnFailed(cause error, f string, args ...interface{}) error {
+	err := failed(cause, f, args...)
+	mp.warnf("%s", err)
+	return err
+}
@@ -0,0 +1,105 @@
+package overseer
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/menglh/overseer/fetcher"
+)
+
+func TestFailed(t *testing.T) {
+	_, cause := os.Open("testdata/missing")
+	err := failed(ErrFetchFailed, "failed to open binary: %w", cause)
+	if want := "failed to open binary: " + cause.Error(); err.Error() != want {
+		t.Errorf("got %q, expected %q", err, want)
+	}
+	if !errors.Is(err, ErrFetchFailed) {
+		t.Error("not ErrFetchFailed")
+	}
+	if errors.Is(err, ErrBindFailed) {
+		t.Error("ErrBindFailed although caused by ErrFetchFailed")
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error("does not wrap the formatted error")
+	}
+	var pathErr *os.PathError
+	if !errors.As(err, &pathErr) || pathErr != cause {
+		t.Error("the formatted error is not found by errors.As")
+	}
+	//wrapped again, by the program
+	wrapped := fmt.Errorf("upgrade: %w", err)
+	if !errors.Is(wrapped, ErrFetchFailed) || !errors.Is(wrapped, os.ErrNotExist) {
+		t.Error("the causes are lost once wrapped")
+	}
+}
+
+func TestFailedWithoutWrap(t *testing.T) {
+	cause := &net.OpError{Op: "listen", Net: "tcp", Err: errors.New("address already in use")}
+	err := failed(ErrBindFailed, "failed to bind (%s)", cause)
+	if !errors.Is(err, ErrBindFailed) {
+		t.Error("not ErrBindFailed")
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		t.Error("found an error formatted without %w")
+	}
+}
+
+func TestSanityCheckErrorIs(t *testing.T) {
+	cause := errors.New("exec format error")
+	var err error = &SanityCheckError{Path: "bin", Err: cause}
+	err = fmt.Errorf("upgrade failed (%w)", err)
+	if !errors.Is(err, ErrSanityCheckFailed) || !errors.Is(err, cause) {
+		t.Error("not ErrSanityCheckFailed and its cause")
+	}
+	var sanity *SanityCheckError
+	if !errors.As(err, &sanity) || sanity.Path != "bin" {
+		t.Error("the SanityCheckError is not found by errors.As")
+	}
+}
+
+func TestFailedWrapsAll(t *testing.T) {
+	//the chain is kept whole, however the error was formatted
+	inner := fmt.Errorf("failed to stage (%w)", os.ErrNotExist)
+	err := failed(ErrFetchFailed, "upgrade failed: %w", inner)
+	if errors.Unwrap(err) == nil || !errors.Is(err, os.ErrNotExist) {
+		t.Error("the wrapped chain is lost")
+	}
+}
+
+func TestDiskSpaceErrorIs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overseer-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	mp := &master{Config: &Config{}, binPath: filepath.Join(dir, "app"), tmpBinPath: filepath.Join(dir, "app.tmp")}
+	r := fetcher.Describe(strings.NewReader(""), fetcher.Info{Size: 1 << 62})
+	err = mp.checkDiskSpace(r)
+	if !errors.Is(err, ErrFetchFailed) {
+		t.Errorf("got %v, expected ErrFetchFailed", err)
+	}
+	var diskErr *DiskSpaceError
+	if !errors.As(err, &diskErr) || diskErr.Dir != dir {
+		t.Errorf("the DiskSpaceError is not found by errors.As")
+	}
+}
+
+func TestNotRunning(t *testing.T) {
+	for name, err := range map[string]error{
+		"FetchNow":    FetchNow(),
+		"Pause":       Pause(),
+		"AddListener": AddListener(":3000"),
+		"Reload":      Reload(),
+	} {
+		if err != ErrNotRunning {
+			t.Errorf("%s: got %v, expected ErrNotRunning", name, err)
+		}
+	}
+}
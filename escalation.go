@@ -0,0 +1,76 @@
+package overseer
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Escalation is a signal sent to a program which has not exited,
+// After it was asked to, see Config.Escalation
+type Escalation struct {
+	Signal os.Signal
+	After  time.Duration
+}
+
+// escalation returns the steps of Config.Escalation,
+// SIGKILL after TerminateTimeout by default
+func (c *Config) escalation() []Escalation {
+	if len(c.Escalation) == 0 {
+		return []Escalation{{Signal: os.Kill, After: c.TerminateTimeout}}
+	}
+	return c.Escalation
+}
+
+// killAfter is how long the program may take to exit once asked to
+func (c *Config) killAfter() time.Duration {
+	steps := c.escalation()
+	return steps[len(steps)-1].After
+}
+
+// escalate sends the steps of the escalation until done, or until
+// signal fails as the program has exited, returning the last signal
+// sent, if any. When done is set, it is awaited before returning.
+func (mp *master) escalate(signal func(os.Signal) error, done <-chan bool) os.Signal {
+	start := time.Now()
+	var sent os.Signal
+	for _, step := range mp.escalation() {
+		select {
+		case <-done:
+			return sent
+		case <-time.After(time.Until(start.Add(step.After))):
+		}
+		if err := signal(step.Signal); err != nil {
+			//exited meanwhile
+			break
+		}
+		mp.debugf("graceful timeout, program sent %s", step.Signal)
+		sent = step.Signal
+	}
+	if done != nil {
+		<-done
+	}
+	return sent
+}
+
+// escalated describes the escalation for events
+func escalated(s os.Signal) string {
+	if s == os.Kill {
+		return "graceful timeout, program killed"
+	}
+	return fmt.Sprintf("graceful timeout, program sent %s", s)
+}
+
+func validateEscalation(steps []Escalation) error {
+	var after time.Duration
+	for _, step := range steps {
+		if step.Signal == nil || step.After <= after {
+			return fmt.Errorf("overseer.Config.Escalation must list signals after increasing durations")
+		}
+		after = step.After
+	}
+	if len(steps) > 0 && steps[len(steps)-1].Signal != os.Kill {
+		return fmt.Errorf("overseer.Config.Escalation must end with os.Kill")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package overseer
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEscalate(t *testing.T) {
+	mp := &master{Config: &Config{Escalation: []Escalation{{os.Interrupt, 20 * time.Millisecond}, {os.Kill, 40 * time.Millisecond}}}}
+	sent := []os.Signal{}
+	started := time.Now()
+	last := mp.escalate(func(s os.Signal) error {
+		sent = append(sent, s)
+		return nil
+	}, nil)
+	if last != os.Kill || len(sent) != 2 || sent[0] != os.Interrupt {
+		t.Errorf("sent %v, the last being %v", sent, last)
+	}
+	if elapsed := time.Since(started); elapsed < 40*time.Millisecond {
+		t.Errorf("escalated after %s, before the last step", elapsed)
+	}
+	//once exited, the program is sent no more signals
+	sent = sent[:0]
+	last = mp.escalate(func(s os.Signal) error {
+		sent = append(sent, s)
+		return errors.New("process already finished")
+	}, nil)
+	if last != nil || len(sent) != 1 {
+		t.Errorf("sent %v once exited, the last being %v", sent, last)
+	}
+	//nor when it exits first
+	done := make(chan bool)
+	close(done)
+	if last := mp.escalate(func(os.Signal) error { return nil }, done); last != nil {
+		t.Errorf("sent %v once exited", last)
+	}
+}
+
+func TestValidateEscalation(t *testing.T) {
+	for name, steps := range map[string][]Escalation{
+		"order":   {{os.Interrupt, 10 * time.Second}, {os.Kill, 5 * time.Second}},
+		"signal":  {{nil, time.Second}, {os.Kill, 5 * time.Second}},
+		"no kill": {{os.Interrupt, time.Second}},
+	} {
+		if err := validateEscalation(steps); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if err := validateEscalation([]Escalation{{os.Interrupt, time.Second}, {os.Kill, 5 * time.Second}}); err != nil {
+		t.Error(err)
+	}
+	c := &Config{TerminateTimeout: time.Minute}
+	if c.killAfter() != time.Minute {
+		t.Errorf("got kill after %s, expected the TerminateTimeout", c.killAfter())
+	}
+}
@@ -0,0 +1,100 @@
+package overseer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// ProgramExit describes an exit of the program, see Config.ProgramExited
+type ProgramExit struct {
+	SlaveID int
+	//Replica is the index of the program among Config.Replicas,
+	//zero for the first program
+	Replica int
+	//Program is the Name of the program among Config.Programs,
+	//empty for Config.Program and its replicas
+	Program string
+	//Code is the exit code of the program, or 128 plus the number
+	//of the signal which killed it, as reported by shells
+	Code int
+	//Signal is the signal which killed the program, if any
+	Signal os.Signal
+	//CoreDumped is set when the program dumped core, see Config.CoreDumps
+	CoreDumped bool
+	//CorePath is where the core dump was written, when known
+	CorePath string
+	//Replaced is set when the program exited once replaced by a restart
+	Replaced bool
+	//Exiting is set when the master exits with Code after this exit,
+	//as it is stopping, or as the program was not being restarted
+	Exiting bool
+}
+
+// exitStatus describes the exit of the program
+// with the given pid, from the error of its Wait
+func (mp *master) exitStatus(slaveID, pid int, err error) ProgramExit {
+	e := ProgramExit{SlaveID: slaveID}
+	if err == nil {
+		return e
+	}
+	e.Code = 1
+	if exiterr, ok := err.(*exec.ExitError); ok {
+		if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
+			e.Code = status.ExitStatus()
+			if status.Signaled() {
+				e.Code = 128 + int(status.Signal())
+				e.Signal = status.Signal()
+			}
+			if status.CoreDump() {
+				e.CoreDumped = true
+				e.CorePath = corePath(pid, mp.Config.Chroot, mp.Config.Dir)
+			}
+		}
+	}
+	return e
+}
+
+// describe explains how the program exited
+func (e ProgramExit) describe() string {
+	if e.Signal != nil {
+		return fmt.Sprintf("program killed by %s", e.Signal)
+	}
+	return fmt.Sprintf("program exited with %d", e.Code)
+}
+
+// crashed reports whether the program was killed by a signal
+// of a fault, or an abort, such as a Go panic with GOTRACEBACK=crash
+func (e ProgramExit) crashed() bool {
+	for _, s := range crashSignals {
+		if e.Signal == s {
+			return true
+		}
+	}
+	return e.CoreDumped
+}
+
+// programExited passes the exit to Config.ProgramExited,
+// and crashes to Config.ProgramCrashed
+func (mp *master) programExited(e ProgramExit) {
+	mp.endDrain(e)
+	if mp.Config.ProgramExited != nil {
+		mp.Config.ProgramExited(e)
+	}
+	if mp.exitedUnexpectedly(e) {
+		event := Event{Type: EventCrash, SlaveID: e.SlaveID, Error: e.describe()}
+		event.Hash, event.Version = mp.binary()
+		mp.emit(event)
+	}
+	mp.reportCrash(e)
+	if e.crashed() && !mp.isStopping() {
+		mp.warnf("program crashed (%s)", e.Signal)
+		if e.CorePath != "" {
+			mp.warnf("core dumped to %s", e.CorePath)
+		}
+		if mp.Config.ProgramCrashed != nil {
+			mp.Config.ProgramCrashed(e)
+		}
+	}
+}
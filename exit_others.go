@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package overseer
+
+import "os"
+
+// crashSignals is empty, programs are not killed by signals on this platform
+var crashSignals []os.Signal
@@ -0,0 +1,12 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package overseer
+
+import (
+	"os"
+	"syscall"
+)
+
+// crashSignals are the signals of faults and aborts, see ProgramExit.crashed
+var crashSignals = []os.Signal{syscall.SIGABRT, syscall.SIGBUS, syscall.SIGFPE, syscall.SIGILL, syscall.SIGSEGV, syscall.SIGTRAP}
@@ -0,0 +1,75 @@
+package overseer
+
+import (
+	"expvar"
+	"sync/atomic"
+	"time"
+)
+
+// expvarName is the name the overseer variable is published as
+const expvarName = "overseer"
+
+// masterVars are published with expvar, listed on /debug/vars
+type masterVars struct {
+	Version         string    `json:"version,omitempty"`
+	Hash            string    `json:"hash"`
+	SlavePid        int       `json:"slave_pid,omitempty"`
+	Generation      int       `json:"generation"`
+	LastFetch       time.Time `json:"last_fetch"`
+	LastUpgrade     time.Time `json:"last_upgrade"`
+	FetchAttempts   int64     `json:"fetch_attempts"`
+	FetchFailures   int64     `json:"fetch_failures"`
+	FetchBytes      int64     `json:"fetch_bytes"`
+	Upgrades        int64     `json:"upgrades"`
+	UpgradeFailures int64     `json:"upgrade_failures"`
+	Restarts        int64     `json:"restarts"`
+	Rollbacks       int64     `json:"rollbacks"`
+}
+
+// publishedVars are those of the latest master or program, as
+// expvar cannot replace a variable once it is published
+var publishedVars atomic.Value //func() interface{}
+
+// publishVars publishes the counters and state of the master with
+// expvar, in the master and in the program, which asks the master
+func publishVars(vars func() interface{}) {
+	publishedVars.Store(vars)
+	if expvar.Get(expvarName) == nil {
+		expvar.Publish(expvarName, expvar.Func(func() interface{} {
+			return publishedVars.Load().(func() interface{})()
+		}))
+	}
+}
+
+func (mp *master) vars() masterVars {
+	v := masterVars{Generation: mp.activeID()}
+	v.Hash, v.Version = mp.binary()
+	if p := mp.activeProc(); p != nil {
+		v.SlavePid = p.Pid
+	}
+	m := &mp.metricCounts
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	v.LastFetch = m.lastFetch
+	v.LastUpgrade = m.lastUpgrade
+	v.FetchAttempts = m.fetchAttempts
+	v.FetchFailures = m.fetchFailures
+	v.FetchBytes = m.fetchBytes
+	v.Upgrades = m.upgrades
+	v.UpgradeFailures = m.upgradeFailures
+	v.Restarts = m.restarts
+	v.Rollbacks = m.rollbacks
+	return v
+}
+
+func (sp *slave) vars() interface{} {
+	resp, err := sp.request(listenerRequest{Op: "vars"})
+	if err != nil {
+		return map[string]string{"error": err.Error()}
+	}
+	if resp.Error != "" || resp.Vars == nil {
+		//the master predates the variables
+		return map[string]string{"error": "unavailable"}
+	}
+	return resp.Vars
+}
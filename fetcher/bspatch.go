@@ -0,0 +1,117 @@
+package fetcher
+
+import (
+	"compress/bzip2"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+var errCorruptPatch = errors.New("corrupt patch")
+
+// bspatch applies BSDIFF40 patches, as produced by bsdiff(1). The
+// patched binary is written sequentially, and the patch is read
+// in place, so neither is held in memory.
+type bspatch struct {
+	newSize           int64
+	ctrl, diff, extra io.Reader
+}
+
+func parseBSPatch(patch io.ReaderAt, size int64) (*bspatch, error) {
+	header := make([]byte, 32)
+	if _, err := patch.ReadAt(header, 0); err != nil || string(header[:8]) != "BSDIFF40" {
+		return nil, errors.New("not a bsdiff patch")
+	}
+	ctrlLen := offtin(header[8:])
+	diffLen := offtin(header[16:])
+	newSize := offtin(header[24:])
+	if ctrlLen < 0 || diffLen < 0 || newSize < 0 || 32+ctrlLen+diffLen > size {
+		return nil, errCorruptPatch
+	}
+	section := func(off, n int64) io.Reader {
+		return bzip2.NewReader(io.NewSectionReader(patch, off, n))
+	}
+	return &bspatch{
+		newSize: newSize,
+		ctrl:    section(32, ctrlLen),
+		diff:    section(32+ctrlLen, diffLen),
+		extra:   section(32+ctrlLen+diffLen, size-32-ctrlLen-diffLen),
+	}, nil
+}
+
+// apply writes the patched old binary to w
+func (p *bspatch) apply(old io.ReaderAt, oldSize int64, w io.Writer) error {
+	buf := make([]byte, 32*1024)
+	oldBuf := make([]byte, len(buf))
+	ctrl := make([]byte, 24)
+	oldPos, newPos := int64(0), int64(0)
+	for newPos < p.newSize {
+		if _, err := io.ReadFull(p.ctrl, ctrl); err != nil {
+			return fmt.Errorf("%s (%w)", errCorruptPatch, err)
+		}
+		add, extra, seek := offtin(ctrl), offtin(ctrl[8:]), offtin(ctrl[16:])
+		if add < 0 || extra < 0 || add > p.newSize-newPos || extra > p.newSize-newPos-add {
+			return errCorruptPatch
+		}
+		//add the diff block to the old binary
+		for add > 0 {
+			n := int64(len(buf))
+			if add < n {
+				n = add
+			}
+			if _, err := io.ReadFull(p.diff, buf[:n]); err != nil {
+				return fmt.Errorf("%s (%w)", errCorruptPatch, err)
+			}
+			//only the part of the range within the old binary is added
+			from, to := oldPos, oldPos+n
+			if from < 0 {
+				from = 0
+			}
+			if to > oldSize {
+				to = oldSize
+			}
+			if from < to {
+				o := oldBuf[:to-from]
+				if _, err := old.ReadAt(o, from); err != nil && err != io.EOF {
+					return err
+				}
+				for i, b := range o {
+					buf[from-oldPos+int64(i)] += b
+				}
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			add -= n
+			oldPos += n
+			newPos += n
+		}
+		//copy the extra block as is
+		if _, err := io.CopyN(w, p.extra, extra); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("%s (%w)", errCorruptPatch, err)
+		}
+		newPos += extra
+		oldPos += seek
+	}
+	//bzip2 checks the CRCs of the streams at their end
+	for _, r := range []io.Reader{p.ctrl, p.diff, p.extra} {
+		if n, err := io.Copy(ioutil.Discard, r); err != nil || n != 0 {
+			return errCorruptPatch
+		}
+	}
+	return nil
+}
+
+// offtin decodes the sign-magnitude integers used by bsdiff
+func offtin(b []byte) int64 {
+	y := int64(binary.LittleEndian.Uint64(b[:8]) &^ (1 << 63))
+	if b[7]&0x80 != 0 {
+		y = -y
+	}
+	return y
+}
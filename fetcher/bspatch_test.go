@@ -0,0 +1,82 @@
+package fetcher
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"testing"
+)
+
+// testdata/bspatch.patch patches bspatch-old.bin into bspatch-new.bin,
+// with seeks backwards and an add past the end of the old binary
+
+func readTestdata(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func applyPatch(patch, old []byte) ([]byte, error) {
+	p, err := parseBSPatch(bytes.NewReader(patch), int64(len(patch)))
+	if err != nil {
+		return nil, err
+	}
+	b := &bytes.Buffer{}
+	err = p.apply(bytes.NewReader(old), int64(len(old)), b)
+	return b.Bytes(), err
+}
+
+func TestBSPatch(t *testing.T) {
+	old, want := readTestdata(t, "bspatch-old.bin"), readTestdata(t, "bspatch-new.bin")
+	got, err := applyPatch(readTestdata(t, "bspatch.patch"), old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, which differ from the %d expected", len(got), len(want))
+	}
+}
+
+func TestBSPatchInvalid(t *testing.T) {
+	patch := readTestdata(t, "bspatch.patch")
+	for name, p := range map[string][]byte{
+		"empty":  nil,
+		"magic":  append([]byte("BSDIFF41"), patch[8:]...),
+		"header": patch[:20],
+	} {
+		if _, err := parseBSPatch(bytes.NewReader(p), int64(len(p))); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestBSPatchTruncated(t *testing.T) {
+	old, patch := readTestdata(t, "bspatch-old.bin"), readTestdata(t, "bspatch.patch")
+	for n := 0; n < len(patch); n++ {
+		if _, err := applyPatch(patch[:n], old); err == nil {
+			t.Fatalf("truncated to %d bytes: expected an error", n)
+		}
+	}
+}
+
+// TestBSPatchCorrupt flips bytes of the patch, which must either fail
+// to apply or, when only unused bits were flipped, apply as before,
+// and must never panic
+func TestBSPatchCorrupt(t *testing.T) {
+	old, want := readTestdata(t, "bspatch-old.bin"), readTestdata(t, "bspatch-new.bin")
+	patch := readTestdata(t, "bspatch.patch")
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		corrupt := append([]byte(nil), patch...)
+		for j := r.Intn(3); j >= 0; j-- {
+			corrupt[r.Intn(len(corrupt))] ^= byte(1 + r.Intn(255))
+		}
+		got, err := applyPatch(corrupt, old)
+		if err == nil && !bytes.Equal(got, want) {
+			t.Fatalf("corruption went unnoticed")
+		}
+	}
+}
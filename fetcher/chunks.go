@@ -0,0 +1,170 @@
+package fetcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// content defined chunking parameters, shared
+// by the publisher and all hosts
+const (
+	chunkMin  = 16 << 10
+	chunkMax  = 256 << 10
+	chunkBits = 16 //average of 64KB
+)
+
+var gear [256]uint64
+
+func init() {
+	//splitmix64, so the table is stable across releases
+	x := uint64(0x6f7665727365657)
+	for i := range gear {
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+}
+
+// ChunkIndex describes the content defined chunks of a binary.
+// Publish the JSON encoded index alongside the binary (see
+// Release) to allow hosts to fetch only the chunks they lack.
+type ChunkIndex struct {
+	Size   int64   `json:"size"`
+	SHA256 string  `json:"sha256"`
+	Chunks []Chunk `json:"chunks"`
+}
+
+// Chunk is a section of a binary
+type Chunk struct {
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// NewChunkIndex reads the binary and returns its ChunkIndex
+func NewChunkIndex(r io.Reader) (*ChunkIndex, error) {
+	index := &ChunkIndex{}
+	h := sha256.New()
+	err := chunk(io.TeeReader(r, h), func(c Chunk, data []byte) error {
+		index.Chunks = append(index.Chunks, c)
+		index.Size += c.Size
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	index.SHA256 = hex.EncodeToString(h.Sum(nil))
+	return index, nil
+}
+
+// chunk splits r into content defined chunks, the data
+// passed to fn is only valid until fn returns
+func chunk(r io.Reader, fn func(c Chunk, data []byte) error) error {
+	buf := make([]byte, 2*chunkMax)
+	n, offset := 0, int64(0)
+	eof := false
+	for {
+		if !eof && n < chunkMax {
+			m, err := io.ReadFull(r, buf[n:])
+			n += m
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				eof = true
+			} else if err != nil {
+				return err
+			}
+		}
+		if n == 0 {
+			return nil
+		}
+		size := cut(buf[:n])
+		if size == 0 {
+			size = n //final chunk
+		}
+		sum := sha256.Sum256(buf[:size])
+		c := Chunk{Offset: offset, Size: int64(size), SHA256: hex.EncodeToString(sum[:])}
+		if err := fn(c, buf[:size]); err != nil {
+			return err
+		}
+		offset += int64(size)
+		n = copy(buf, buf[size:n])
+	}
+}
+
+// cut returns the length of the next chunk in b, or 0 if
+// b is shorter than chunkMax and contains no boundary
+func cut(b []byte) int {
+	if len(b) > chunkMax {
+		b = b[:chunkMax]
+	}
+	h := uint64(0)
+	for i, c := range b {
+		h = (h << 1) + gear[c]
+		if i+1 >= chunkMin && h>>(64-chunkBits) == 0 {
+			return i + 1
+		}
+	}
+	if len(b) == chunkMax {
+		return chunkMax
+	}
+	return 0
+}
+
+// localChunks indexes the chunks of the local binary
+func localChunks(f *os.File) (map[string]Chunk, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	local := map[string]Chunk{}
+	err := chunk(f, func(c Chunk, data []byte) error {
+		local[c.SHA256] = c
+		return nil
+	})
+	return local, err
+}
+
+// syncChunks writes the indexed binary to w, copying the chunks
+// found in the local binary and fetching the rest from get
+func syncChunks(index *ChunkIndex, f *os.File, local map[string]Chunk, get func(offset, size int64) (io.ReadCloser, error), w io.Writer) error {
+	chunks := index.Chunks
+	for len(chunks) > 0 {
+		if c, ok := local[chunks[0].SHA256]; ok && c.Size == chunks[0].Size {
+			if _, err := io.Copy(w, io.NewSectionReader(f, c.Offset, c.Size)); err != nil {
+				return err
+			}
+			chunks = chunks[1:]
+			continue
+		}
+		//fetch the run of missing chunks at once
+		n := 1
+		for n < len(chunks) {
+			if _, ok := local[chunks[n].SHA256]; ok {
+				break
+			}
+			n++
+		}
+		last := chunks[n-1]
+		rc, err := get(chunks[0].Offset, last.Offset+last.Size-chunks[0].Offset)
+		if err != nil {
+			return err
+		}
+		for _, c := range chunks[:n] {
+			h := sha256.New()
+			if _, err := io.CopyN(io.MultiWriter(w, h), rc, c.Size); err != nil {
+				rc.Close()
+				return fmt.Errorf("chunk request failed (%w)", err)
+			}
+			if hex.EncodeToString(h.Sum(nil)) != c.SHA256 {
+				rc.Close()
+				return fmt.Errorf("chunk checksum mismatch (offset %d)", c.Offset)
+			}
+		}
+		rc.Close()
+		chunks = chunks[n:]
+	}
+	return nil
+}
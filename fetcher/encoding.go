@@ -0,0 +1,61 @@
+package fetcher
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/menglh/overseer/fetcher/internal/zstd"
+)
+
+// acceptEncoding is requested when fetching binaries
+const acceptEncoding = "zstd, gzip"
+
+// compressed reports whether the file is served compressed
+func compressed(name string) bool {
+	return strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".zst")
+}
+
+// getBinary requests the binary, negotiating its compression
+func getBinary(c *http.Client, req *http.Request) (*http.Response, error) {
+	//when set explicitly, the transport leaves the body compressed
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	return c.Do(req)
+}
+
+// decode decompresses the binary according to the Content-Encoding
+// of the response, or the extension (.gz or .zst) of the file when
+// it is served as is. Decompression is streamed and closing the
+// returned reader closes the response body.
+func decode(resp *http.Response, name string) (io.ReadCloser, Info, error) {
+	enc := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	if enc == "" || enc == "identity" {
+		switch {
+		case strings.HasSuffix(name, ".gz"):
+			enc = "gzip"
+		case strings.HasSuffix(name, ".zst"):
+			enc = "zstd"
+		default:
+			return resp.Body, Info{Size: resp.ContentLength}, nil
+		}
+	}
+	var r io.Reader
+	switch enc {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, Info{}, err
+		}
+		r = gz
+	case "zstd":
+		r = zstd.NewReader(resp.Body)
+	default:
+		resp.Body.Close()
+		return nil, Info{}, fmt.Errorf("unsupported content encoding (%s)", enc)
+	}
+	//size is unknown once decompressed
+	return readCloser{Reader: r, Closer: resp.Body}, Info{}, nil
+}
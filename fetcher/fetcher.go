@@ -1,6 +1,12 @@
 package fetcher
 
-import "io"
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
 
 // Interface defines the required fetcher functions
 type Interface interface {
@@ -18,6 +24,19 @@ type Interface interface {
 	Fetch() (io.Reader, error)
 }
 
+// Logf logs the warnings of the fetchers, unless they
+// are given a logger of their own, see Logger
+var Logf = func(format string, args ...interface{}) {
+	log.Printf("[overseer fetcher] "+format, args...)
+}
+
+// Logger is implemented by fetchers which log warnings. The overseer
+// master sets their logger before Init, to log them as its "fetcher",
+// see overseer.Config.LogLevels.
+type Logger interface {
+	SetLogf(logf func(format string, args ...interface{}))
+}
+
 // Func converts a fetch function into the fetcher interface
 func Func(fn func() (io.Reader, error)) Interface {
 	return &fetcher{fn}
@@ -34,3 +53,118 @@ func (f fetcher) Init() error {
 func (f fetcher) Fetch() (io.Reader, error) {
 	return f.fn()
 }
+
+// Waker is implemented by fetchers which can interrupt their
+// polling delay, so that the pending Fetch checks for updates
+// immediately. It is used by overseer.FetchNow.
+type Waker interface {
+	Wake()
+}
+
+// ContextFetcher is implemented by fetchers whose Fetch may be
+// cancelled. The delay between fetches, and the requests made,
+// are interrupted once the context is done, returning its error.
+// It is used by overseer.RunContext, with its context.
+type ContextFetcher interface {
+	FetchContext(ctx context.Context) (io.Reader, error)
+}
+
+// poller provides the delay between fetches
+type poller struct {
+	init   sync.Once
+	wake   chan bool
+	delay  bool
+	logger func(format string, args ...interface{})
+}
+
+// SetLogf sets the logger of the fetcher, see Logger
+func (p *poller) SetLogf(logf func(format string, args ...interface{})) {
+	p.logger = logf
+}
+
+// logf logs the warning with the fetcher's logger, or Logf
+func (p *poller) logf(format string, args ...interface{}) {
+	if p.logger != nil {
+		p.logger(format, args...)
+		return
+	}
+	Logf(format, args...)
+}
+
+func (p *poller) wakes() chan bool {
+	p.init.Do(func() {
+		p.wake = make(chan bool, 1)
+	})
+	return p.wake
+}
+
+// wait sleeps for the provided interval, except on the
+// first fetch or when woken, failing once ctx is done
+func (p *poller) wait(ctx context.Context, interval time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if !p.delay {
+		p.delay = true
+		return nil
+	}
+	select {
+	case <-time.After(interval):
+	case <-p.wakes():
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
+
+// Wake interrupts the current (or next) delay between fetches
+func (p *poller) Wake() {
+	select {
+	case p.wakes() <- true:
+	default:
+	}
+}
+
+// Info describes a fetched binary
+type Info struct {
+	//Size of the binary in bytes, 0 if unknown
+	Size int64
+	//Version of the binary, empty if unknown
+	Version string
+	//Source of the binary, such as its URL or path
+	Source string
+	//Notes describing the changes in the binary, such as
+	//the release notes of a Manifest, empty if unknown
+	Notes string
+}
+
+// Describer is optionally implemented by the io.Reader returned
+// from Fetch, providing information about the binary before it
+// has been read. See Describe.
+type Describer interface {
+	Info() Info
+}
+
+// Describe attaches the Info to the provided binary stream
+func Describe(r io.Reader, info Info) io.Reader {
+	if info.Size < 0 {
+		info.Size = 0
+	}
+	return &described{Reader: r, info: info}
+}
+
+type described struct {
+	io.Reader
+	info Info
+}
+
+func (d *described) Info() Info {
+	return d.info
+}
+
+func (d *described) Close() error {
+	if c, ok := d.Reader.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package fetcher
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -16,8 +17,8 @@ type File struct {
 	Path     string
 	Interval time.Duration
 	// hash is the file modify time and its size
-	hash  string
-	delay bool
+	hash string
+	poller
 }
 
 // Init sets the Path and Interval options
@@ -36,11 +37,15 @@ func (f *File) Init() error {
 
 // Fetch file from the specified Path
 func (f *File) Fetch() (io.Reader, error) {
+	return f.FetchContext(context.Background())
+}
+
+// FetchContext fetches the file, until ctx is done, see ContextFetcher
+func (f *File) FetchContext(ctx context.Context) (io.Reader, error) {
 	//only delay after first fetch
-	if f.delay {
-		time.Sleep(f.Interval)
+	if err := f.wait(ctx, f.Interval); err != nil {
+		return nil, err
 	}
-	f.delay = true
 	lastHash := f.hash
 	if err := f.updateHash(); err != nil {
 		return nil, err
@@ -66,7 +71,12 @@ func (f *File) Fetch() (io.Reader, error) {
 		}
 		attempt++
 		//sleep
-		time.Sleep(rate)
+		select {
+		case <-time.After(rate):
+		case <-ctx.Done():
+			file.Close()
+			return nil, ctx.Err()
+		}
 		//check hash!
 		if err := f.updateHash(); err != nil {
 			file.Close()
@@ -78,7 +88,11 @@ func (f *File) Fetch() (io.Reader, error) {
 		}
 		lastHash = f.hash
 	}
-	return file, nil
+	info := Info{Source: f.Path}
+	if s, err := file.Stat(); err == nil {
+		info.Size = s.Size()
+	}
+	return Describe(file, info), nil
 }
 
 func (f *File) updateHash() error {
@@ -88,12 +102,12 @@ func (f *File) updateHash() error {
 		if os.IsNotExist(err) {
 			return nil
 		}
-		return fmt.Errorf("Open file error: %s", err)
+		return fmt.Errorf("Open file error: %w", err)
 	}
 	defer file.Close()
 	s, err := file.Stat()
 	if err != nil {
-		return fmt.Errorf("Get file stat error: %s", err)
+		return fmt.Errorf("Get file stat error: %w", err)
 	}
 	f.hash = fmt.Sprintf("%d|%d", s.ModTime().UnixNano(), s.Size())
 	return nil
@@ -1,11 +1,10 @@
 package fetcher
 
 import (
-	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"runtime"
 	"strings"
@@ -27,7 +26,6 @@ type Github struct {
 	Asset func(filename string) bool
 	//internal state
 	releaseURL    string
-	delay         bool
 	lastETag      string
 	latestRelease struct {
 		TagName string `json:"tag_name"`
@@ -36,6 +34,7 @@ type Github struct {
 			URL  string `json:"browser_download_url"`
 		} `json:"assets"`
 	}
+	poller
 }
 
 func (h *Github) defaultAsset(filename string) bool {
@@ -58,22 +57,30 @@ func (h *Github) Init() error {
 	if h.Interval == 0 {
 		h.Interval = 5 * time.Minute
 	} else if h.Interval < 1*time.Minute {
-		log.Printf("[overseer.github] warning: intervals less than 1 minute will surpass the public rate limit")
+		h.logf("github: intervals less than 1 minute will surpass the public rate limit")
 	}
 	return nil
 }
 
 // Fetch the binary from the provided Repository
 func (h *Github) Fetch() (io.Reader, error) {
+	return h.FetchContext(context.Background())
+}
+
+// FetchContext fetches the binary, until ctx is done, see ContextFetcher
+func (h *Github) FetchContext(ctx context.Context) (io.Reader, error) {
 	//delay fetches after first
-	if h.delay {
-		time.Sleep(h.Interval)
+	if err := h.wait(ctx, h.Interval); err != nil {
+		return nil, err
 	}
-	h.delay = true
 	//check release status
-	resp, err := http.Get(h.releaseURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", h.releaseURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("release info request failed (%w)", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("release info request failed (%s)", err)
+		return nil, fmt.Errorf("release info request failed (%w)", err)
 	}
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
@@ -82,7 +89,7 @@ func (h *Github) Fetch() (io.Reader, error) {
 	//clear assets
 	h.latestRelease.Assets = nil
 	if err := json.NewDecoder(resp.Body).Decode(&h.latestRelease); err != nil {
-		return nil, fmt.Errorf("invalid request info (%s)", err)
+		return nil, fmt.Errorf("invalid request info (%w)", err)
 	}
 	resp.Body.Close()
 	//find appropriate asset
@@ -97,10 +104,10 @@ func (h *Github) Fetch() (io.Reader, error) {
 		return nil, fmt.Errorf("no matching assets in this release (%s)", h.latestRelease.TagName)
 	}
 	//fetch location
-	req, _ := http.NewRequest("HEAD", assetURL, nil)
+	req, _ = http.NewRequestWithContext(ctx, "HEAD", assetURL, nil)
 	resp, err = http.DefaultTransport.RoundTrip(req)
 	if err != nil {
-		return nil, fmt.Errorf("release location request failed (%s)", err)
+		return nil, fmt.Errorf("release location request failed (%w)", err)
 	}
 	resp.Body.Close()
 	if resp.StatusCode != http.StatusFound {
@@ -108,14 +115,14 @@ func (h *Github) Fetch() (io.Reader, error) {
 	}
 	s3URL := resp.Header.Get("Location")
 	//pseudo-HEAD request
-	req, err = http.NewRequest("GET", s3URL, nil)
+	req, err = http.NewRequestWithContext(ctx, "GET", s3URL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("release location url error (%s)", err)
+		return nil, fmt.Errorf("release location url error (%w)", err)
 	}
 	req.Header.Set("Range", "bytes=0-0") // HEAD not allowed so we request for 1 byte
 	resp, err = http.DefaultTransport.RoundTrip(req)
 	if err != nil {
-		return nil, fmt.Errorf("release location request failed (%s)", err)
+		return nil, fmt.Errorf("release location request failed (%w)", err)
 	}
 	resp.Body.Close()
 	if resp.StatusCode != http.StatusPartialContent {
@@ -126,19 +133,25 @@ func (h *Github) Fetch() (io.Reader, error) {
 		return nil, nil //skip, hash match
 	}
 	//get binary request
-	resp, err = http.Get(s3URL)
+	req, err = http.NewRequestWithContext(ctx, "GET", s3URL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("release binary request failed (%s)", err)
+		return nil, fmt.Errorf("release binary request failed (%w)", err)
+	}
+	resp, err = getBinary(http.DefaultClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("release binary request failed (%w)", err)
 	}
 	if resp.StatusCode != http.StatusOK {
 		resp.Body.Close()
 		return nil, fmt.Errorf("release binary request failed (status code %d)", resp.StatusCode)
 	}
 	h.lastETag = etag
-	//success!
-	//extract gz files
-	if strings.HasSuffix(assetURL, ".gz") && resp.Header.Get("Content-Encoding") != "gzip" {
-		return gzip.NewReader(resp.Body)
+	//success! extract compressed files
+	body, info, err := decode(resp, assetURL)
+	if err != nil {
+		return nil, err
 	}
-	return resp.Body, nil
+	info.Version = h.latestRelease.TagName
+	info.Source = assetURL
+	return Describe(body, info), nil
 }
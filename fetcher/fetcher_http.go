@@ -1,11 +1,10 @@
 package fetcher
 
 import (
-	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -17,9 +16,13 @@ type HTTP struct {
 	URL          string
 	Interval     time.Duration
 	CheckHeaders []string
+	//Peers optionally fetches updates from other overseer instances,
+	//when the HEAD response includes the SHA-256 of the binary in an
+	//X-Checksum-Sha256 or Digest header. Does not apply to .gz or .zst URLs.
+	Peers *Peers
 	//internal state
-	delay bool
 	lasts map[string]string
+	poller
 }
 
 //if any of these change, the binary has been updated
@@ -44,15 +47,23 @@ func (h *HTTP) Init() error {
 
 // Fetch the binary from the provided URL
 func (h *HTTP) Fetch() (io.Reader, error) {
+	return h.FetchContext(context.Background())
+}
+
+// FetchContext fetches the binary, until ctx is done, see ContextFetcher
+func (h *HTTP) FetchContext(ctx context.Context) (io.Reader, error) {
 	//delay fetches after first
-	if h.delay {
-		time.Sleep(h.Interval)
+	if err := h.wait(ctx, h.Interval); err != nil {
+		return nil, err
 	}
-	h.delay = true
 	//status check using HEAD
-	resp, err := http.Head(h.URL)
+	req, err := http.NewRequestWithContext(ctx, "HEAD", h.URL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("HEAD request failed (%s)", err)
+		return nil, fmt.Errorf("HEAD request failed (%w)", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HEAD request failed (%w)", err)
 	}
 	resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
@@ -73,19 +84,37 @@ func (h *HTTP) Fetch() (io.Reader, error) {
 	if matches == total {
 		return nil, nil //skip, file match
 	}
+	//binary fetch from peers
+	if h.Peers != nil && !compressed(h.URL) {
+		if sum := headerSHA256(resp.Header); sum != "" {
+			r, err := h.Peers.fetch(ctx, sum, func() {
+				//bad peer binary, retry on next fetch
+				h.lasts = map[string]string{}
+			})
+			if err == nil {
+				return r, nil
+			}
+		}
+	}
 
 	//binary fetch using GET
-	resp, err = http.Get(h.URL)
+	req, err = http.NewRequestWithContext(ctx, "GET", h.URL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("GET request failed (%s)", err)
+		return nil, fmt.Errorf("GET request failed (%w)", err)
+	}
+	resp, err = getBinary(http.DefaultClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("GET request failed (%w)", err)
 	}
 	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
 		return nil, fmt.Errorf("GET request failed (status code %d)", resp.StatusCode)
 	}
-	//extract gz files
-	if strings.HasSuffix(h.URL, ".gz") && resp.Header.Get("Content-Encoding") != "gzip" {
-		return gzip.NewReader(resp.Body)
+	//success! extract compressed files
+	body, info, err := decode(resp, h.URL)
+	if err != nil {
+		return nil, err
 	}
-	//success!
-	return resp.Body, nil
+	info.Source = h.URL
+	return Describe(body, info), nil
 }
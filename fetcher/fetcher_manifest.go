@@ -0,0 +1,425 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Manifest polls a JSON release manifest (see Release) and fetches
+// the binary it describes. Releases may be staged by setting their
+// rollout percentage, each host is then placed in a stable bucket so
+// that raising the percentage only ever adds hosts to the rollout.
+type Manifest struct {
+	//URL of the JSON release manifest
+	URL string
+	//Interval between manifest checks, defaults to 5 minutes
+	Interval time.Duration
+	//ID identifies this host for staged rollouts, defaults to the hostname
+	ID string
+	//Peers optionally fetches releases with a SHA-256 from other
+	//overseer instances, see Peers
+	Peers *Peers
+	//Client defaults to http.DefaultClient
+	Client *http.Client
+	//internal state
+	current   string
+	last      string
+	badDeltas map[string]bool
+	poller
+}
+
+// Release is the JSON document polled by the Manifest fetcher
+type Release struct {
+	//Version of this release
+	Version string `json:"version"`
+	//URL of the binary, may be relative to the manifest URL.
+	//URLs ending in .gz or .zst are decompressed.
+	URL string `json:"url"`
+	//SHA256 optionally verifies the binary
+	SHA256 string `json:"sha256,omitempty"`
+	//Size of the binary in bytes, optional
+	Size int64 `json:"size,omitempty"`
+	//Rollout is the percentage of hosts which should apply this
+	//release (0 to 100), defaults to 100
+	Rollout *float64 `json:"rollout,omitempty"`
+	//Notes describing the changes in this release, optional
+	Notes string `json:"notes,omitempty"`
+	//Deltas optionally lists patches which produce this release
+	//from previous releases. Requires SHA256.
+	Deltas []Delta `json:"deltas,omitempty"`
+	//Chunks is the optional URL of the ChunkIndex of the binary, may
+	//be relative to the manifest URL. Hosts then fetch only the chunks
+	//missing from their binary, using range requests against URL.
+	//Requires SHA256, and URL must not be compressed.
+	Chunks string `json:"chunks,omitempty"`
+}
+
+// Delta is a bsdiff(1) patch from a previous release
+type Delta struct {
+	//From is the SHA-256 of the binary the patch applies to
+	From string `json:"from"`
+	//URL of the patch, may be relative to the manifest URL
+	URL string `json:"url"`
+}
+
+// Init validates the provided config
+func (m *Manifest) Init() error {
+	if m.URL == "" {
+		return fmt.Errorf("URL required")
+	}
+	if m.Interval <= 0 {
+		m.Interval = 5 * time.Minute
+	}
+	if m.ID == "" {
+		host, err := os.Hostname()
+		if err != nil {
+			return fmt.Errorf("ID required (%w)", err)
+		}
+		m.ID = host
+	}
+	if m.Client == nil {
+		m.Client = http.DefaultClient
+	}
+	//checksum of the running binary, to skip
+	//fetching the release we're already running
+	if p, _ := os.Executable(); p != "" {
+		if f, err := os.Open(p); err == nil {
+			h := sha256.New()
+			io.Copy(h, f)
+			f.Close()
+			m.current = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	return nil
+}
+
+// Fetch the manifest and, if this host is part of its rollout, the binary
+func (m *Manifest) Fetch() (io.Reader, error) {
+	return m.FetchContext(context.Background())
+}
+
+// FetchContext fetches the manifest and binary, until ctx
+// is done, see ContextFetcher
+func (m *Manifest) FetchContext(ctx context.Context) (io.Reader, error) {
+	//delay fetches after first
+	if err := m.wait(ctx, m.Interval); err != nil {
+		return nil, err
+	}
+	resp, err := m.get(ctx, m.URL)
+	if err != nil {
+		return nil, fmt.Errorf("manifest request failed (%w)", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("manifest request failed (status code %d)", resp.StatusCode)
+	}
+	r := Release{}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid manifest (%w)", err)
+	}
+	if r.URL == "" {
+		return nil, fmt.Errorf("invalid manifest (url missing)")
+	}
+	sum := strings.ToLower(r.SHA256)
+	key := r.Version + "|" + sum + "|" + r.URL
+	if key == m.last || (sum != "" && sum == m.current) {
+		return nil, nil //skip, already fetched
+	}
+	if !m.InRollout(r) {
+		return nil, nil //skip, not yet
+	}
+	binURL, err := resolveURL(m.URL, r.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest url (%w)", err)
+	}
+	failed := func() {
+		//bad binary, retry on next fetch
+		m.last = ""
+	}
+	m.last = key
+	//binary patch of the current binary
+	if sum != "" && len(r.Deltas) > 0 {
+		dr, err := m.fetchDelta(ctx, r, sum, failed)
+		if err != nil {
+			m.logf("manifest: delta failed, fetching full binary: %s", err)
+		} else if dr != nil {
+			return dr, nil
+		}
+	}
+	//binary sync of the missing chunks
+	if sum != "" && r.Chunks != "" && !compressed(binURL) {
+		cr, err := m.fetchChunks(ctx, r, binURL, sum, failed)
+		if err != nil {
+			m.logf("manifest: chunk sync failed, fetching full binary: %s", err)
+		} else if cr != nil {
+			return cr, nil
+		}
+	}
+	//binary fetch from peers
+	if m.Peers != nil && sum != "" && !compressed(binURL) {
+		if pr, err := m.Peers.fetch(ctx, sum, failed); err == nil {
+			info := Info{Size: r.Size, Version: r.Version, Notes: r.Notes}
+			if d, ok := pr.(Describer); ok {
+				if info.Size <= 0 {
+					info.Size = d.Info().Size
+				}
+				info.Source = d.Info().Source
+			}
+			return Describe(pr, info), nil
+		}
+	}
+	//binary fetch from origin
+	req, err := http.NewRequestWithContext(ctx, "GET", binURL, nil)
+	if err != nil {
+		m.last = ""
+		return nil, fmt.Errorf("binary request failed (%w)", err)
+	}
+	resp, err = getBinary(m.Client, req)
+	if err != nil {
+		m.last = ""
+		return nil, fmt.Errorf("binary request failed (%w)", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		m.last = ""
+		return nil, fmt.Errorf("binary request failed (status code %d)", resp.StatusCode)
+	}
+	//extract compressed files
+	body, info, err := decode(resp, binURL)
+	if err != nil {
+		m.last = ""
+		return nil, err
+	}
+	info.Version = r.Version
+	info.Notes = r.Notes
+	info.Source = binURL
+	if r.Size > 0 {
+		info.Size = r.Size
+	}
+	if sum != "" {
+		body = &verifier{ReadCloser: body, hash: sha256.New(), sum: sum, failed: failed}
+	}
+	return Describe(body, info), nil
+}
+
+// fetchDelta downloads a patch from the binary on disk (which may
+// differ from the running binary after an upgrade) and applies it,
+// the result is verified against the release sum
+func (m *Manifest) fetchDelta(ctx context.Context, r Release, sum string, failed func()) (io.Reader, error) {
+	if m.badDeltas == nil {
+		m.badDeltas = map[string]bool{}
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	old, err := os.Open(exe)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	oldSize, err := io.Copy(h, old)
+	if err != nil {
+		old.Close()
+		return nil, err
+	}
+	from := hex.EncodeToString(h.Sum(nil))
+	d := Delta{}
+	for _, rd := range r.Deltas {
+		if strings.EqualFold(rd.From, from) && !m.badDeltas[rd.URL] {
+			d = rd
+			break
+		}
+	}
+	if d.URL == "" {
+		old.Close()
+		return nil, nil //no applicable delta
+	}
+	deltaURL, err := resolveURL(m.URL, d.URL)
+	if err != nil {
+		old.Close()
+		return nil, fmt.Errorf("invalid delta url (%w)", err)
+	}
+	p, pf, err := m.fetchPatch(ctx, deltaURL)
+	if err != nil {
+		old.Close()
+		m.badDeltas[d.URL] = true
+		return nil, err
+	}
+	//stream the patched binary
+	pr, pw := io.Pipe()
+	go func() {
+		err := p.apply(old, oldSize, pw)
+		old.Close()
+		removeFile(pf)
+		pw.CloseWithError(err)
+	}()
+	return Describe(&verifier{ReadCloser: pr, hash: sha256.New(), sum: sum, failed: func() {
+		//bad patch, retry with the full binary
+		m.badDeltas[d.URL] = true
+		failed()
+	}}, Info{Size: r.Size, Version: r.Version, Notes: r.Notes, Source: deltaURL}), nil
+}
+
+// fetchChunks fetches the chunk index of the release and syncs
+// the binary on disk with it, the result is verified against the
+// release sum
+func (m *Manifest) fetchChunks(ctx context.Context, r Release, binURL, sum string, failed func()) (io.Reader, error) {
+	if m.badDeltas == nil {
+		m.badDeltas = map[string]bool{}
+	}
+	indexURL, err := resolveURL(m.URL, r.Chunks)
+	if err != nil {
+		return nil, fmt.Errorf("invalid chunks url (%w)", err)
+	}
+	if m.badDeltas[indexURL] {
+		return nil, nil
+	}
+	index, err := m.fetchIndex(ctx, indexURL)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(index.SHA256, sum) {
+		m.badDeltas[indexURL] = true
+		return nil, fmt.Errorf("chunk index does not match release")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return nil, err
+	}
+	local, err := localChunks(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	get := func(offset, size int64) (io.ReadCloser, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", binURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+size-1))
+		resp, err := m.Client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("chunk request failed (%w)", err)
+		}
+		if resp.StatusCode != http.StatusPartialContent {
+			resp.Body.Close()
+			return nil, fmt.Errorf("chunk request failed (status code %d)", resp.StatusCode)
+		}
+		return resp.Body, nil
+	}
+	//stream the synced binary
+	pr, pw := io.Pipe()
+	go func() {
+		err := syncChunks(index, f, local, get, pw)
+		f.Close()
+		pw.CloseWithError(err)
+	}()
+	return Describe(&verifier{ReadCloser: pr, hash: sha256.New(), sum: sum, failed: func() {
+		//bad index or server, retry with the full binary
+		m.badDeltas[indexURL] = true
+		failed()
+	}}, Info{Size: index.Size, Version: r.Version, Notes: r.Notes, Source: binURL}), nil
+}
+
+func (m *Manifest) fetchIndex(ctx context.Context, indexURL string) (*ChunkIndex, error) {
+	resp, err := m.get(ctx, indexURL)
+	if err != nil {
+		return nil, fmt.Errorf("chunk index request failed (%w)", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("chunk index request failed (status code %d)", resp.StatusCode)
+	}
+	index := &ChunkIndex{}
+	if err := json.NewDecoder(resp.Body).Decode(index); err != nil {
+		return nil, fmt.Errorf("invalid chunk index (%w)", err)
+	}
+	return index, nil
+}
+
+// fetchPatch downloads the patch to a temporary file, which
+// the caller must close and remove once the patch is applied
+func (m *Manifest) fetchPatch(ctx context.Context, deltaURL string) (*bspatch, *os.File, error) {
+	resp, err := m.get(ctx, deltaURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("delta request failed (%w)", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("delta request failed (status code %d)", resp.StatusCode)
+	}
+	f, err := ioutil.TempFile("", "overseer-patch-")
+	if err != nil {
+		return nil, nil, err
+	}
+	size, err := io.Copy(f, resp.Body)
+	if err != nil {
+		removeFile(f)
+		return nil, nil, fmt.Errorf("delta request failed (%w)", err)
+	}
+	p, err := parseBSPatch(f, size)
+	if err != nil {
+		removeFile(f)
+		return nil, nil, err
+	}
+	return p, f, nil
+}
+
+// get requests the url with the Client, until ctx is done
+func (m *Manifest) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	return m.Client.Do(req)
+}
+
+func removeFile(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// InRollout returns whether this host should apply the release.
+// Hosts are bucketed by a hash of their ID and the release version.
+func (m *Manifest) InRollout(r Release) bool {
+	if r.Rollout == nil || *r.Rollout >= 100 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(m.ID + "|" + r.Version))
+	bucket := float64(h.Sum32()%10000) / 100
+	return bucket < *r.Rollout
+}
+
+func resolveURL(base, ref string) (string, error) {
+	b, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	r, err := url.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return b.ResolveReference(r).String(), nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,199 @@
+package fetcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PeerPath is the HTTP path prefix under which overseer masters serve
+// their current binary to peers, followed by its hex encoded SHA-256.
+const PeerPath = "/overseer/binary/"
+
+// PeerAnnouncement is periodically multicast by overseer masters
+// serving their binary to peers (see overseer.Config.PeerAnnounce).
+type PeerAnnouncement struct {
+	SHA256 string `json:"sha256"`
+	Port   int    `json:"port"`
+}
+
+// Peers fetches binaries from other overseer masters on the same
+// network, instead of from the origin. A binary is requested by its
+// SHA-256, so it may only be used by fetchers which learn the checksum
+// of an update before downloading it (see HTTP.Peers). Every download
+// is verified against the checksum.
+type Peers struct {
+	//Addresses of peers serving binaries (overseer.Config.PeerAddress)
+	Addresses []string
+	//Discover is a UDP multicast address on which peers announce
+	//their binaries (overseer.Config.PeerAnnounce)
+	Discover string
+	//Wait is the maximum (randomised) duration to wait for a peer to
+	//announce a binary before falling back to the origin. This spreads
+	//out fetches so that only the first few instances hit the origin.
+	Wait time.Duration
+	//Client defaults to an http.Client with a 5 minute timeout
+	Client *http.Client
+	//internal state
+	init  sync.Once
+	mut   sync.Mutex
+	known map[string]map[string]time.Time
+}
+
+// peerExpiry is how long a discovered peer is considered available
+const peerExpiry = 1 * time.Minute
+
+func (p *Peers) setup() {
+	p.init.Do(func() {
+		p.known = map[string]map[string]time.Time{}
+		if p.Client == nil {
+			p.Client = &http.Client{Timeout: 5 * time.Minute}
+		}
+		if p.Discover != "" {
+			go p.discover()
+		}
+	})
+}
+
+func (p *Peers) discover() {
+	addr, err := net.ResolveUDPAddr("udp", p.Discover)
+	if err != nil {
+		return
+	}
+	conn, err := net.ListenMulticastUDP("udp", nil, addr)
+	if err != nil {
+		return
+	}
+	buff := make([]byte, 512)
+	for {
+		n, src, err := conn.ReadFromUDP(buff)
+		if err != nil {
+			return
+		}
+		a := PeerAnnouncement{}
+		if json.Unmarshal(buff[:n], &a) != nil || a.SHA256 == "" || a.Port == 0 {
+			continue
+		}
+		peer := net.JoinHostPort(src.IP.String(), strconv.Itoa(a.Port))
+		p.mut.Lock()
+		if p.known[a.SHA256] == nil {
+			p.known[a.SHA256] = map[string]time.Time{}
+		}
+		p.known[a.SHA256][peer] = time.Now()
+		p.mut.Unlock()
+	}
+}
+
+// find returns the discovered peers with the binary, followed by
+// the statically configured peers
+func (p *Peers) find(sum string) []string {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	peers := []string{}
+	for peer, t := range p.known[sum] {
+		if time.Since(t) < peerExpiry {
+			peers = append(peers, peer)
+		}
+	}
+	return append(peers, p.Addresses...)
+}
+
+// Fetch the binary with the given hex encoded SHA-256 from a peer.
+// The returned reader fails, and calls failed, if the downloaded
+// binary does not match the checksum.
+func (p *Peers) Fetch(sum string, failed func()) (io.Reader, error) {
+	return p.fetch(context.Background(), sum, failed)
+}
+
+func (p *Peers) fetch(ctx context.Context, sum string, failed func()) (io.Reader, error) {
+	p.setup()
+	sum = strings.ToLower(sum)
+	peers := p.find(sum)
+	if len(peers) == 0 && p.Wait > 0 {
+		deadline := time.Now().Add(time.Duration(rand.Int63n(int64(p.Wait))))
+		for len(peers) == 0 && time.Now().Before(deadline) {
+			select {
+			case <-time.After(250 * time.Millisecond):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			peers = p.find(sum)
+		}
+	}
+	for _, peer := range peers {
+		peerURL := "http://" + peer + PeerPath + sum
+		req, err := http.NewRequestWithContext(ctx, "GET", peerURL, nil)
+		if err != nil {
+			continue
+		}
+		resp, err := p.Client.Do(req)
+		if err != nil {
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			continue
+		}
+		return Describe(&verifier{
+			ReadCloser: resp.Body,
+			hash:       sha256.New(),
+			sum:        sum,
+			failed:     failed,
+		}, Info{Size: resp.ContentLength, Source: peerURL}), nil
+	}
+	return nil, errors.New("no peers have this binary")
+}
+
+// verifier checks the stream matches the
+// expected SHA-256 as it is read
+type verifier struct {
+	io.ReadCloser
+	hash   hash.Hash
+	sum    string
+	failed func()
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+	n, err := v.ReadCloser.Read(p)
+	v.hash.Write(p[:n])
+	if err == io.EOF {
+		if got := hex.EncodeToString(v.hash.Sum(nil)); got != v.sum {
+			err = fmt.Errorf("binary checksum mismatch (%s)", got)
+		}
+	}
+	if err != nil && err != io.EOF && v.failed != nil {
+		v.failed()
+		v.failed = nil
+	}
+	return n, err
+}
+
+// headerSHA256 extracts the hex encoded SHA-256 of the response body
+// from either the X-Checksum-Sha256 or Digest (RFC 3230) headers
+func headerSHA256(h http.Header) string {
+	if sum := h.Get("X-Checksum-Sha256"); len(sum) == sha256.Size*2 {
+		return strings.ToLower(sum)
+	}
+	for _, d := range strings.Split(h.Get("Digest"), ",") {
+		d = strings.TrimSpace(d)
+		if i := strings.Index(d, "="); i > 0 && strings.EqualFold(d[:i], "sha-256") {
+			if b, err := base64.StdEncoding.DecodeString(d[i+1:]); err == nil && len(b) == sha256.Size {
+				return hex.EncodeToString(b)
+			}
+		}
+	}
+	return ""
+}
@@ -1,7 +1,7 @@
 package fetcher
 
 import (
-	"compress/gzip"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"errors"
@@ -35,8 +35,8 @@ type S3 struct {
 	GetTimeout time.Duration
 	//interal state
 	client   *http.Client
-	delay    bool
 	lastETag string
+	poller
 }
 
 // Init validates the provided config
@@ -73,11 +73,15 @@ func (s *S3) Init() error {
 
 // Fetch the binary from S3
 func (s *S3) Fetch() (io.Reader, error) {
+	return s.FetchContext(context.Background())
+}
+
+// FetchContext fetches the binary, until ctx is done, see ContextFetcher
+func (s *S3) FetchContext(ctx context.Context) (io.Reader, error) {
 	//delay fetches after first
-	if s.delay {
-		time.Sleep(s.Interval)
+	if err := s.wait(ctx, s.Interval); err != nil {
+		return nil, err
 	}
-	s.delay = true
 	//http client where we change the timeout
 	c := http.Client{}
 	//options for this key
@@ -91,10 +95,11 @@ func (s *S3) Fetch() (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 	c.Timeout = s.HeadTimeout
 	resp, err := c.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("HEAD request failed (%s)", err)
+		return nil, fmt.Errorf("HEAD request failed (%w)", err)
 	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("HEAD request failed (%s)", resp.Status)
@@ -109,18 +114,21 @@ func (s *S3) Fetch() (io.Reader, error) {
 	if err != nil {
 		return nil, err
 	}
+	req = req.WithContext(ctx)
 	c.Timeout = s.GetTimeout
-	resp, err = c.Do(req)
+	resp, err = getBinary(&c, req)
 	if err != nil {
-		return nil, fmt.Errorf("GET request failed (%s)", err)
+		return nil, fmt.Errorf("GET request failed (%w)", err)
 	}
 	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
 		return nil, fmt.Errorf("GET request failed (%s)", resp.Status)
 	}
-	//extract gz files
-	if strings.HasSuffix(s.Key, ".gz") && resp.Header.Get("Content-Encoding") != "gzip" {
-		return gzip.NewReader(resp.Body)
+	//success! extract compressed files
+	body, info, err := decode(resp, s.Key)
+	if err != nil {
+		return nil, err
 	}
-	//success!
-	return resp.Body, nil
+	info.Source = "s3://" + s.Bucket + "/" + s.Key
+	return Describe(body, info), nil
 }
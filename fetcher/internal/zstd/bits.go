@@ -0,0 +1,99 @@
+package zstd
+
+import "math/bits"
+
+// forwardBits reads bits from least to most significant
+type forwardBits struct {
+	data []byte
+	off  uint //bit offset
+}
+
+func (f *forwardBits) peek(n uint) uint32 {
+	v := uint64(0)
+	for i := uint(0); i < 8; i++ {
+		if j := f.off/8 + i; j < uint(len(f.data)) {
+			v |= uint64(f.data[j]) << (8 * i)
+		}
+	}
+	return uint32(v>>(f.off%8)) & (1<<n - 1)
+}
+
+func (f *forwardBits) skip(n uint) {
+	f.off += n
+}
+
+// overrun reports whether more bits were read than available
+func (f *forwardBits) overrun() bool {
+	return f.off > uint(len(f.data))*8
+}
+
+// bytes returns the number of bytes read, including partial bytes
+func (f *forwardBits) bytes() int {
+	return int((f.off + 7) / 8)
+}
+
+// reverseBits reads the backward bitstreams used by the huffman
+// and FSE decoders, which start at the highest set bit of the
+// last byte. Reading beyond the start of the stream yields zeros.
+type reverseBits struct {
+	data     []byte
+	off      int //next byte to load
+	bits     uint64
+	cnt      uint
+	overread uint
+}
+
+func newReverseBits(data []byte) (*reverseBits, error) {
+	if len(data) == 0 || data[len(data)-1] == 0 {
+		return nil, errCorrupt
+	}
+	last := data[len(data)-1]
+	cnt := uint(bits.Len8(last) - 1)
+	return &reverseBits{
+		data: data,
+		off:  len(data) - 1,
+		bits: uint64(last) & (1<<cnt - 1),
+		cnt:  cnt,
+	}, nil
+}
+
+func (r *reverseBits) fill(n uint) {
+	for r.cnt < n {
+		r.bits <<= 8
+		if r.off > 0 {
+			r.off--
+			r.bits |= uint64(r.data[r.off])
+		} else {
+			r.overread += 8
+		}
+		r.cnt += 8
+	}
+}
+
+func (r *reverseBits) peek(n uint) uint32 {
+	r.fill(n)
+	return uint32(r.bits>>(r.cnt-n)) & (1<<n - 1)
+}
+
+func (r *reverseBits) skip(n uint) {
+	r.cnt -= n
+}
+
+func (r *reverseBits) read(n uint) uint32 {
+	if n == 0 {
+		return 0
+	}
+	v := r.peek(n)
+	r.skip(n)
+	return v
+}
+
+// overrun reports whether more bits were read than available
+func (r *reverseBits) overrun() bool {
+	return r.overread > r.cnt
+}
+
+// done reports whether the stream was read exactly
+func (r *reverseBits) done() bool {
+	return r.off == 0 && r.cnt == r.overread
+}
@@ -0,0 +1,319 @@
+package zstd
+
+import "encoding/binary"
+
+var (
+	llBase = [36]uint32{
+		0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+		16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+		8192, 16384, 32768, 65536,
+	}
+	llBits = [36]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+		13, 14, 15, 16,
+	}
+	mlBase = [53]uint32{
+		3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18,
+		19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32, 33, 34,
+		35, 37, 39, 41, 43, 47, 51, 59, 67, 83, 99, 131, 259, 515, 1027, 2051,
+		4099, 8195, 16387, 32771, 65539,
+	}
+	mlBits = [53]uint8{
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+		12, 13, 14, 15, 16,
+	}
+	llDefault = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	mlDefault = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	ofDefault = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+	llDefaultTable, _ = buildFSE(llDefault, 6)
+	mlDefaultTable, _ = buildFSE(mlDefault, 6)
+	ofDefaultTable, _ = buildFSE(ofDefault, 5)
+)
+
+// decodeBlock appends the decompressed block to the window
+func (z *Reader) decodeBlock(data []byte) error {
+	lits, n, err := z.readLiterals(data)
+	if err != nil {
+		return err
+	}
+	data = data[n:]
+	if len(data) == 0 {
+		return errCorrupt
+	}
+	//number of sequences
+	nbSeq := int(data[0])
+	switch {
+	case nbSeq == 0:
+		z.window = append(z.window, lits...)
+		return nil
+	case nbSeq < 128:
+		data = data[1:]
+	case nbSeq < 255:
+		if len(data) < 2 {
+			return errCorrupt
+		}
+		nbSeq = (nbSeq-128)<<8 + int(data[1])
+		data = data[2:]
+	default:
+		if len(data) < 3 {
+			return errCorrupt
+		}
+		nbSeq = int(data[1]) + int(data[2])<<8 + 0x7f00
+		data = data[3:]
+	}
+	if len(data) == 0 {
+		return errCorrupt
+	}
+	modes := data[0]
+	data = data[1:]
+	if modes&3 != 0 {
+		return errCorrupt
+	}
+	if data, err = z.readTable(&z.llTable, modes>>6, data, llDefaultTable, 35, 9); err != nil {
+		return err
+	}
+	if data, err = z.readTable(&z.ofTable, modes>>4&3, data, ofDefaultTable, 31, 8); err != nil {
+		return err
+	}
+	if data, err = z.readTable(&z.mlTable, modes>>2&3, data, mlDefaultTable, 52, 9); err != nil {
+		return err
+	}
+	return z.execSequences(data, nbSeq, lits)
+}
+
+// readTable reads the decoding table of a sequence field
+func (z *Reader) readTable(t **fseTable, mode uint8, data []byte, def *fseTable, maxSymbol int, maxLog uint) ([]byte, error) {
+	switch mode {
+	case 0: //predefined
+		*t = def
+	case 1: //rle
+		if len(data) == 0 || int(data[0]) > maxSymbol {
+			return nil, errCorrupt
+		}
+		*t = rleFSE(data[0])
+		return data[1:], nil
+	case 2: //compressed
+		table, n, err := readFSE(data, maxSymbol, maxLog)
+		if err != nil {
+			return nil, err
+		}
+		*t = table
+		return data[n:], nil
+	case 3: //repeat
+		if *t == nil {
+			return nil, errCorrupt
+		}
+	}
+	return data, nil
+}
+
+// readLiterals decodes the literals section, returning
+// the literals and the size of the section
+func (z *Reader) readLiterals(data []byte) ([]byte, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errCorrupt
+	}
+	kind := data[0] & 3
+	format := data[0] >> 2 & 3
+	if kind < 2 {
+		//raw or rle
+		var size, n int
+		switch format {
+		case 0, 2:
+			size, n = int(data[0]>>3), 1
+		case 1:
+			if len(data) < 2 {
+				return nil, 0, errCorrupt
+			}
+			size, n = int(data[0]>>4)+int(data[1])<<4, 2
+		case 3:
+			if len(data) < 3 {
+				return nil, 0, errCorrupt
+			}
+			size, n = int(data[0]>>4)+int(data[1])<<4+int(data[2])<<12, 3
+		}
+		if size > maxBlockSize {
+			return nil, 0, errCorrupt
+		}
+		if kind == 0 {
+			if len(data) < n+size {
+				return nil, 0, errCorrupt
+			}
+			return data[n : n+size], n + size, nil
+		}
+		if len(data) < n+1 {
+			return nil, 0, errCorrupt
+		}
+		lits := z.lits[:0]
+		for i := 0; i < size; i++ {
+			lits = append(lits, data[n])
+		}
+		z.lits = lits
+		return lits, n + 1, nil
+	}
+	//huffman compressed
+	if len(data) < [4]int{3, 3, 4, 5}[format] {
+		return nil, 0, errCorrupt
+	}
+	var regen, size, n int
+	streams := 4
+	switch format {
+	case 0, 1:
+		if format == 0 {
+			streams = 1
+		}
+		v := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+		regen, size, n = int(v>>4&0x3ff), int(v>>14&0x3ff), 3
+	case 2:
+		v := binary.LittleEndian.Uint32(data)
+		regen, size, n = int(v>>4&0x3fff), int(v>>18&0x3fff), 4
+	case 3:
+		v := uint64(binary.LittleEndian.Uint32(data)) | uint64(data[4])<<32
+		regen, size, n = int(v>>4&0x3ffff), int(v>>22&0x3ffff), 5
+	}
+	if regen > maxBlockSize || len(data) < n+size {
+		return nil, 0, errCorrupt
+	}
+	huff := data[n : n+size]
+	if kind == 2 {
+		t, hn, err := readHuff(huff)
+		if err != nil {
+			return nil, 0, err
+		}
+		z.huff = t
+		huff = huff[hn:]
+	} else if z.huff == nil {
+		return nil, 0, errCorrupt
+	}
+	lits := z.lits[:0]
+	var err error
+	if streams == 1 {
+		lits, err = z.huff.decode(lits, huff, regen)
+	} else {
+		lits, err = z.huff.decode4(lits, huff, regen)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	z.lits = lits
+	return lits, n + size, nil
+}
+
+// decode4 decodes the 4 streams of a huffman literals section
+func (t *huffTable) decode4(out, data []byte, n int) ([]byte, error) {
+	if len(data) < 6 {
+		return nil, errCorrupt
+	}
+	sizes := [3]int{
+		int(binary.LittleEndian.Uint16(data)),
+		int(binary.LittleEndian.Uint16(data[2:])),
+		int(binary.LittleEndian.Uint16(data[4:])),
+	}
+	data = data[6:]
+	per := (n + 3) / 4
+	var err error
+	for i := 0; i < 4; i++ {
+		size, count := len(data), n-3*per
+		if i < 3 {
+			size, count = sizes[i], per
+		}
+		if size > len(data) || count < 0 {
+			return nil, errCorrupt
+		}
+		if out, err = t.decode(out, data[:size], count); err != nil {
+			return nil, err
+		}
+		data = data[size:]
+	}
+	return out, nil
+}
+
+// execSequences decodes and executes the sequences,
+// appending the result to the window
+func (z *Reader) execSequences(data []byte, nbSeq int, lits []byte) error {
+	r, err := newReverseBits(data)
+	if err != nil {
+		return err
+	}
+	var ll, of, ml fseState
+	ll.init(z.llTable, r)
+	of.init(z.ofTable, r)
+	ml.init(z.mlTable, r)
+	for i := 0; i < nbSeq; i++ {
+		ofCode, llCode, mlCode := of.symbol(), ll.symbol(), ml.symbol()
+		if ofCode > 31 || llCode > 35 || mlCode > 52 {
+			return errCorrupt
+		}
+		offset := uint32(1)<<ofCode + r.read(uint(ofCode))
+		matchLen := mlBase[mlCode] + r.read(uint(mlBits[mlCode]))
+		litLen := llBase[llCode] + r.read(uint(llBits[llCode]))
+		//repeat offsets
+		if offset > 3 {
+			offset -= 3
+			z.reps[2], z.reps[1], z.reps[0] = z.reps[1], z.reps[0], offset
+		} else {
+			if litLen == 0 {
+				offset++
+			}
+			switch offset {
+			case 1:
+				offset = z.reps[0]
+			case 2:
+				offset = z.reps[1]
+				z.reps[1], z.reps[0] = z.reps[0], offset
+			case 3:
+				offset = z.reps[2]
+				z.reps[2], z.reps[1], z.reps[0] = z.reps[1], z.reps[0], offset
+			case 4:
+				offset = z.reps[0] - 1
+				z.reps[2], z.reps[1], z.reps[0] = z.reps[1], z.reps[0], offset
+			}
+		}
+		if i < nbSeq-1 {
+			ll.update(r)
+			ml.update(r)
+			of.update(r)
+		}
+		if r.overrun() {
+			return errCorrupt
+		}
+		//literals then match
+		if int(litLen) > len(lits) {
+			return errCorrupt
+		}
+		z.window = append(z.window, lits[:litLen]...)
+		lits = lits[litLen:]
+		if offset == 0 || int(offset) > len(z.window) || int(offset) > z.windowSize {
+			return errCorrupt
+		}
+		start := len(z.window) - int(offset)
+		if int(matchLen) <= int(offset) {
+			z.window = append(z.window, z.window[start:start+int(matchLen)]...)
+		} else {
+			//overlapping match
+			for j := 0; j < int(matchLen); j++ {
+				z.window = append(z.window, z.window[start+j])
+			}
+		}
+	}
+	if !r.done() {
+		return errCorrupt
+	}
+	z.window = append(z.window, lits...)
+	return nil
+}
@@ -0,0 +1,150 @@
+package zstd
+
+import "math/bits"
+
+// fseEntry is a state of an FSE decoding table
+type fseEntry struct {
+	symbol   uint8
+	nbBits   uint8
+	baseline uint16
+}
+
+type fseTable struct {
+	accuracyLog uint
+	entries     []fseEntry
+}
+
+// readFSE reads an FSE table description, returning
+// the table and the number of bytes read
+func readFSE(data []byte, maxSymbol int, maxLog uint) (*fseTable, int, error) {
+	f := &forwardBits{data: data}
+	accuracyLog := uint(f.peek(4)) + 5
+	f.skip(4)
+	if accuracyLog > maxLog {
+		return nil, 0, errCorrupt
+	}
+	probs := make([]int16, 0, maxSymbol+1)
+	remaining := int32(1<<accuracyLog) + 1
+	threshold := int32(1 << accuracyLog)
+	nbBits := accuracyLog + 1
+	previous0 := false
+	for remaining > 1 && len(probs) <= maxSymbol {
+		if previous0 {
+			//zero probabilities are followed by 2 bit repeat counts
+			for {
+				repeat := f.peek(2)
+				f.skip(2)
+				for i := uint32(0); i < repeat; i++ {
+					probs = append(probs, 0)
+				}
+				if repeat != 3 || f.overrun() {
+					break
+				}
+			}
+			if len(probs) > maxSymbol {
+				return nil, 0, errCorrupt
+			}
+		}
+		max := 2*threshold - 1 - remaining
+		var count int32
+		if v := int32(f.peek(nbBits - 1)); v < max {
+			count = v
+			f.skip(nbBits - 1)
+		} else {
+			count = int32(f.peek(nbBits))
+			if count >= threshold {
+				count -= max
+			}
+			f.skip(nbBits)
+		}
+		count-- //-1 is a probability of less than 1
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		probs = append(probs, int16(count))
+		previous0 = count == 0
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+		if f.overrun() {
+			return nil, 0, errCorrupt
+		}
+	}
+	if remaining != 1 || f.overrun() {
+		return nil, 0, errCorrupt
+	}
+	t, err := buildFSE(probs, accuracyLog)
+	return t, f.bytes(), err
+}
+
+// buildFSE builds the decoding table of a normalized distribution
+func buildFSE(probs []int16, accuracyLog uint) (*fseTable, error) {
+	size := 1 << accuracyLog
+	t := &fseTable{accuracyLog: accuracyLog, entries: make([]fseEntry, size)}
+	next := make([]uint16, len(probs))
+	high := size - 1
+	for s, p := range probs {
+		if p == -1 {
+			t.entries[high].symbol = uint8(s)
+			high--
+			next[s] = 1
+		} else {
+			next[s] = uint16(p)
+		}
+	}
+	step := size>>1 + size>>3 + 3
+	mask := size - 1
+	pos := 0
+	for s, p := range probs {
+		for i := 0; i < int(p); i++ {
+			t.entries[pos].symbol = uint8(s)
+			pos = (pos + step) & mask
+			for pos > high {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+	if pos != 0 {
+		return nil, errCorrupt
+	}
+	for i := range t.entries {
+		e := &t.entries[i]
+		n := next[e.symbol]
+		next[e.symbol]++
+		if n == 0 {
+			return nil, errCorrupt
+		}
+		nb := accuracyLog - uint(bits.Len16(n)-1)
+		e.nbBits = uint8(nb)
+		e.baseline = uint16((uint(n) << nb) - uint(size))
+	}
+	return t, nil
+}
+
+// rleFSE returns a table which always decodes symbol
+func rleFSE(symbol uint8) *fseTable {
+	return &fseTable{entries: []fseEntry{{symbol: symbol}}}
+}
+
+// fseState decodes symbols from a backward bitstream
+type fseState struct {
+	table *fseTable
+	state uint32
+}
+
+func (s *fseState) init(t *fseTable, r *reverseBits) {
+	s.table = t
+	s.state = r.read(t.accuracyLog)
+}
+
+func (s *fseState) symbol() uint8 {
+	return s.table.entries[s.state].symbol
+}
+
+func (s *fseState) update(r *reverseBits) {
+	e := s.table.entries[s.state]
+	s.state = uint32(e.baseline) + r.read(uint(e.nbBits))
+}
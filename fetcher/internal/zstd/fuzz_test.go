@@ -0,0 +1,15 @@
+//go:build go1.18
+// +build go1.18
+
+package zstd
+
+import "testing"
+
+func FuzzReader(f *testing.F) {
+	for _, fr := range frames(f) {
+		f.Add(readFile(f, fr.name))
+	}
+	f.Fuzz(func(t *testing.T, in []byte) {
+		decodeLimited(in)
+	})
+}
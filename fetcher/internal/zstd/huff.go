@@ -0,0 +1,153 @@
+package zstd
+
+import "math/bits"
+
+const maxHuffBits = 11
+
+type huffEntry struct {
+	symbol uint8
+	nbBits uint8
+}
+
+type huffTable struct {
+	maxBits uint
+	entries []huffEntry
+}
+
+// readHuff reads a huffman tree description, returning
+// the table and the number of bytes read
+func readHuff(data []byte) (*huffTable, int, error) {
+	if len(data) == 0 {
+		return nil, 0, errCorrupt
+	}
+	var weights []uint8
+	hb := int(data[0])
+	n := 1
+	if hb < 128 {
+		//fse compressed weights
+		if len(data) < 1+hb {
+			return nil, 0, errCorrupt
+		}
+		var err error
+		weights, err = readHuffWeights(data[1 : 1+hb])
+		if err != nil {
+			return nil, 0, err
+		}
+		n += hb
+	} else {
+		//4 bit weights
+		count := hb - 127
+		size := (count + 1) / 2
+		if len(data) < 1+size {
+			return nil, 0, errCorrupt
+		}
+		for i := 0; i < count; i++ {
+			b := data[1+i/2]
+			if i%2 == 0 {
+				weights = append(weights, b>>4)
+			} else {
+				weights = append(weights, b&0xf)
+			}
+		}
+		n += size
+	}
+	t, err := buildHuff(weights)
+	return t, n, err
+}
+
+func readHuffWeights(data []byte) ([]uint8, error) {
+	t, n, err := readFSE(data, 255, 6)
+	if err != nil {
+		return nil, err
+	}
+	r, err := newReverseBits(data[n:])
+	if err != nil {
+		return nil, err
+	}
+	weights := []uint8{}
+	var s1, s2 fseState
+	s1.init(t, r)
+	s2.init(t, r)
+	//two interleaved states, until the stream is exhausted
+	for len(weights) < 255 {
+		weights = append(weights, s1.symbol())
+		s1.update(r)
+		if r.overrun() {
+			weights = append(weights, s2.symbol())
+			break
+		}
+		weights = append(weights, s2.symbol())
+		s2.update(r)
+		if r.overrun() {
+			weights = append(weights, s1.symbol())
+			break
+		}
+	}
+	if len(weights) > 255 {
+		return nil, errCorrupt
+	}
+	return weights, nil
+}
+
+// buildHuff builds the decoding table, the weight
+// of the last symbol is implied by the others
+func buildHuff(weights []uint8) (*huffTable, error) {
+	total := uint32(0)
+	for _, w := range weights {
+		if w > maxHuffBits {
+			return nil, errCorrupt
+		}
+		if w > 0 {
+			total += 1 << (w - 1)
+		}
+	}
+	if total == 0 {
+		return nil, errCorrupt
+	}
+	maxBits := uint(bits.Len32(total))
+	if maxBits > maxHuffBits {
+		return nil, errCorrupt
+	}
+	left := uint32(1)<<maxBits - total
+	if left&(left-1) != 0 {
+		return nil, errCorrupt
+	}
+	weights = append(weights, uint8(bits.Len32(left)))
+	//codes are assigned by increasing weight, then symbol
+	t := &huffTable{maxBits: maxBits, entries: make([]huffEntry, 1<<maxBits)}
+	pos := 0
+	for w := uint8(1); w <= uint8(maxBits); w++ {
+		for s, sw := range weights {
+			if sw != w {
+				continue
+			}
+			count := 1 << (w - 1)
+			e := huffEntry{symbol: uint8(s), nbBits: uint8(maxBits + 1 - uint(w))}
+			for i := 0; i < count; i++ {
+				t.entries[pos+i] = e
+			}
+			pos += count
+		}
+	}
+	if pos != len(t.entries) {
+		return nil, errCorrupt
+	}
+	return t, nil
+}
+
+// decode decodes n symbols from a single huffman stream
+func (t *huffTable) decode(out, data []byte, n int) ([]byte, error) {
+	r, err := newReverseBits(data)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		e := t.entries[r.peek(t.maxBits)]
+		r.skip(uint(e.nbBits))
+		out = append(out, e.symbol)
+	}
+	if !r.done() {
+		return nil, errCorrupt
+	}
+	return out, nil
+}
@@ -0,0 +1,97 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// xxhash64 is the frame checksum, with a seed of 0
+var (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+type xxhash64 struct {
+	v     [4]uint64
+	total uint64
+	buf   [32]byte
+	n     int
+}
+
+func newXXHash64() *xxhash64 {
+	return &xxhash64{v: [4]uint64{prime1 + prime2, prime2, 0, -prime1}}
+}
+
+func round(acc, input uint64) uint64 {
+	acc += input * prime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * prime1
+}
+
+func merge(acc, v uint64) uint64 {
+	acc ^= round(0, v)
+	return acc*prime1 + prime4
+}
+
+func (x *xxhash64) write(b []byte) {
+	x.total += uint64(len(b))
+	if x.n > 0 {
+		c := copy(x.buf[x.n:], b)
+		x.n += c
+		b = b[c:]
+		if x.n < 32 {
+			return
+		}
+		x.stripe(x.buf[:])
+		x.n = 0
+	}
+	for len(b) >= 32 {
+		x.stripe(b)
+		b = b[32:]
+	}
+	x.n = copy(x.buf[:], b)
+}
+
+func (x *xxhash64) stripe(b []byte) {
+	for i := range x.v {
+		x.v[i] = round(x.v[i], binary.LittleEndian.Uint64(b[8*i:]))
+	}
+}
+
+func (x *xxhash64) sum() uint64 {
+	var h uint64
+	if x.total >= 32 {
+		v := x.v
+		h = bits.RotateLeft64(v[0], 1) + bits.RotateLeft64(v[1], 7) +
+			bits.RotateLeft64(v[2], 12) + bits.RotateLeft64(v[3], 18)
+		for _, vi := range v {
+			h = merge(h, vi)
+		}
+	} else {
+		h = x.v[2] + prime5
+	}
+	h += x.total
+	b := x.buf[:x.n]
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= round(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*prime1 + prime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * prime1
+		h = bits.RotateLeft64(h, 23)*prime2 + prime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * prime5
+		h = bits.RotateLeft64(h, 11) * prime1
+	}
+	h ^= h >> 33
+	h *= prime2
+	h ^= h >> 29
+	h *= prime3
+	h ^= h >> 32
+	return h
+}
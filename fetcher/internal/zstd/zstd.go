@@ -0,0 +1,239 @@
+// Package zstd implements a streaming Zstandard (RFC 8878)
+// decompressor, dictionaries are not supported.
+package zstd
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	frameMagic    = 0xfd2fb528
+	maxBlockSize  = 128 << 10
+	maxWindowSize = 1 << 30
+)
+
+var errCorrupt = errors.New("zstd: corrupt input")
+
+// Reader decompresses a stream of zstd frames
+type Reader struct {
+	r          io.Reader
+	err        error
+	inFrame    bool
+	lastBlock  bool
+	checksum   bool
+	hash       *xxhash64
+	window     []byte //decoded frame history
+	windowSize int
+	out        []byte //decoded but not yet read
+	block      []byte
+	lits       []byte
+	huff       *huffTable
+	llTable    *fseTable
+	ofTable    *fseTable
+	mlTable    *fseTable
+	reps       [3]uint32
+	frames     int
+}
+
+// NewReader returns a Reader decompressing r
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r}
+}
+
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.next()
+	}
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+	return n, nil
+}
+
+// next decodes the next block
+func (z *Reader) next() error {
+	if !z.inFrame {
+		if err := z.readFrameHeader(); err != nil {
+			return err
+		}
+		return nil
+	}
+	if z.lastBlock {
+		if z.checksum {
+			b := make([]byte, 4)
+			if _, err := io.ReadFull(z.r, b); err != nil {
+				return unexpected(err)
+			}
+			if binary.LittleEndian.Uint32(b) != uint32(z.hash.sum()) {
+				return errors.New("zstd: checksum mismatch")
+			}
+		}
+		z.inFrame = false
+		return nil
+	}
+	//keep only the history matches may refer to
+	if len(z.window) > 2*z.windowSize && len(z.window) > maxBlockSize {
+		keep := z.window[len(z.window)-z.windowSize:]
+		z.window = append(z.window[:0], keep...)
+	}
+	h := make([]byte, 3)
+	if _, err := io.ReadFull(z.r, h); err != nil {
+		return unexpected(err)
+	}
+	v := uint32(h[0]) | uint32(h[1])<<8 | uint32(h[2])<<16
+	z.lastBlock = v&1 != 0
+	kind := v >> 1 & 3
+	size := int(v >> 3)
+	start := len(z.window)
+	switch kind {
+	case 0: //raw
+		if size > maxBlockSize {
+			return errCorrupt
+		}
+		if err := z.readBlock(size); err != nil {
+			return err
+		}
+		z.window = append(z.window, z.block...)
+	case 1: //rle
+		if size > maxBlockSize {
+			return errCorrupt
+		}
+		if err := z.readBlock(1); err != nil {
+			return err
+		}
+		for i := 0; i < size; i++ {
+			z.window = append(z.window, z.block[0])
+		}
+	case 2: //compressed
+		if size > maxBlockSize {
+			return errCorrupt
+		}
+		if err := z.readBlock(size); err != nil {
+			return err
+		}
+		if err := z.decodeBlock(z.block); err != nil {
+			return err
+		}
+		if len(z.window)-start > maxBlockSize {
+			return errCorrupt
+		}
+	default:
+		return errCorrupt
+	}
+	z.out = z.window[start:]
+	if z.checksum {
+		z.hash.write(z.out)
+	}
+	return nil
+}
+
+func (z *Reader) readBlock(size int) error {
+	if cap(z.block) < size {
+		z.block = make([]byte, size)
+	}
+	z.block = z.block[:size]
+	_, err := io.ReadFull(z.r, z.block)
+	return unexpected(err)
+}
+
+// readFrameHeader starts the next frame, skipping any
+// skippable frames. Returns io.EOF after the last frame.
+func (z *Reader) readFrameHeader() error {
+	b := make([]byte, 14)
+	for {
+		if _, err := io.ReadFull(z.r, b[:4]); err != nil {
+			if err == io.EOF && z.frames > 0 {
+				return io.EOF
+			}
+			return unexpected(err)
+		}
+		magic := binary.LittleEndian.Uint32(b)
+		if magic == frameMagic {
+			break
+		}
+		if magic&0xfffffff0 != 0x184d2a50 {
+			return errors.New("zstd: invalid magic number")
+		}
+		//skippable frame
+		if _, err := io.ReadFull(z.r, b[:4]); err != nil {
+			return unexpected(err)
+		}
+		size := int64(binary.LittleEndian.Uint32(b))
+		if _, err := io.CopyN(ioutil.Discard, z.r, size); err != nil {
+			return unexpected(err)
+		}
+	}
+	if _, err := io.ReadFull(z.r, b[:1]); err != nil {
+		return unexpected(err)
+	}
+	desc := b[0]
+	fcsFlag := desc >> 6
+	single := desc&0x20 != 0
+	if desc&0x08 != 0 {
+		return errCorrupt
+	}
+	dictSize := [4]int{0, 1, 2, 4}[desc&3]
+	fcsSize := [4]int{0, 2, 4, 8}[fcsFlag]
+	if fcsFlag == 0 && single {
+		fcsSize = 1
+	}
+	n := dictSize + fcsSize
+	if !single {
+		n++
+	}
+	if _, err := io.ReadFull(z.r, b[:n]); err != nil {
+		return unexpected(err)
+	}
+	h := b[:n]
+	windowSize := uint64(0)
+	if !single {
+		exp, mantissa := uint(h[0]>>3), uint64(h[0]&7)
+		base := uint64(1) << (10 + exp)
+		windowSize = base + base/8*mantissa
+		h = h[1:]
+	}
+	dict := uint32(0)
+	for i := 0; i < dictSize; i++ {
+		dict |= uint32(h[i]) << (8 * uint(i))
+	}
+	if dict != 0 {
+		return errors.New("zstd: dictionaries are not supported")
+	}
+	h = h[dictSize:]
+	if single {
+		fcs := uint64(0)
+		for i := 0; i < fcsSize; i++ {
+			fcs |= uint64(h[i]) << (8 * uint(i))
+		}
+		if fcsSize == 2 {
+			fcs += 256
+		}
+		windowSize = fcs
+	}
+	if windowSize > maxWindowSize {
+		return fmt.Errorf("zstd: window size %d too large", windowSize)
+	}
+	z.windowSize = int(windowSize)
+	z.window = z.window[:0]
+	z.checksum = desc&0x04 != 0
+	z.hash = newXXHash64()
+	z.huff, z.llTable, z.ofTable, z.mlTable = nil, nil, nil, nil
+	z.reps = [3]uint32{1, 4, 8}
+	z.inFrame = true
+	z.lastBlock = false
+	z.frames++
+	return nil
+}
+
+func unexpected(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
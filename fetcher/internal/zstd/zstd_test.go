@@ -0,0 +1,173 @@
+package zstd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// the testdata frames were compressed by the reference implementation,
+// zstd v1.5.6, from testdata/readme.txt and the inputs below, e.g.
+// zstd -19 readme.txt -o readme-19.zst, and with --no-check, and
+// sample-stream.zst from stdin, so that its frame has no content size
+
+// sample is compressible input spanning several blocks
+func sample() []byte {
+	words := []string{"overseer", "master", "program", "fetch", "binary", "restart", "socket", "upgrade", " ", "\n", "\t", "0x", "{", "}"}
+	r := rand.New(rand.NewSource(1))
+	n := 160 << 10
+	b := make([]byte, 0, n)
+	for len(b) < n {
+		if r.Intn(64) == 0 {
+			b = append(b, byte(r.Intn(256)))
+			continue
+		}
+		b = append(b, words[r.Intn(len(words))]...)
+	}
+	return b[:n]
+}
+
+// random is incompressible input, stored in raw blocks
+func random() []byte {
+	b := make([]byte, 8192)
+	rand.New(rand.NewSource(2)).Read(b)
+	return b
+}
+
+func readFile(t testing.TB, name string) []byte {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+type frame struct {
+	name string
+	want []byte
+}
+
+func frames(t testing.TB) []frame {
+	readme := readFile(t, "readme.txt")
+	return []frame{
+		{"empty.zst", []byte{}},
+		{"readme-1.zst", readme},
+		{"readme-19.zst", readme},
+		{"readme-nocheck.zst", readme},
+		{"sample-3.zst", sample()},
+		{"sample-19.zst", sample()},
+		{"sample-stream.zst", sample()},
+		{"random.zst", random()},
+		{"zeros.zst", make([]byte, 300<<10)},
+	}
+}
+
+func TestReader(t *testing.T) {
+	for _, f := range frames(t) {
+		got, err := ioutil.ReadAll(NewReader(bytes.NewReader(readFile(t, f.name))))
+		if err != nil {
+			t.Errorf("%s: %s", f.name, err)
+			continue
+		}
+		if !bytes.Equal(got, f.want) {
+			t.Errorf("%s: got %d bytes, which differ from the %d expected", f.name, len(got), len(f.want))
+		}
+	}
+}
+
+func TestReaderShortReads(t *testing.T) {
+	for _, f := range frames(t) {
+		z := NewReader(iotest.HalfReader(bytes.NewReader(readFile(t, f.name))))
+		got, err := ioutil.ReadAll(iotest.OneByteReader(z))
+		if err != nil {
+			t.Errorf("%s: %s", f.name, err)
+			continue
+		}
+		if !bytes.Equal(got, f.want) {
+			t.Errorf("%s: got %d bytes, which differ from the %d expected", f.name, len(got), len(f.want))
+		}
+	}
+}
+
+func TestReaderFrames(t *testing.T) {
+	skippable := make([]byte, 8, 13)
+	binary.LittleEndian.PutUint32(skippable, 0x184d2a5e)
+	binary.LittleEndian.PutUint32(skippable[4:], 5)
+	skippable = append(skippable, "skip!"...)
+	in := append(readFile(t, "readme-1.zst"), skippable...)
+	in = append(in, readFile(t, "sample-3.zst")...)
+	got, err := ioutil.ReadAll(NewReader(bytes.NewReader(in)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := append(readFile(t, "readme.txt"), sample()...); !bytes.Equal(got, want) {
+		t.Fatalf("got %d bytes, which differ from the %d expected", len(got), len(want))
+	}
+}
+
+func TestReaderChecksum(t *testing.T) {
+	in := readFile(t, "readme-1.zst")
+	in[len(in)-1] ^= 0xff
+	_, err := ioutil.ReadAll(NewReader(bytes.NewReader(in)))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+}
+
+func TestReaderTruncated(t *testing.T) {
+	in := readFile(t, "readme-19.zst")
+	for n := 0; n < len(in); n++ {
+		_, err := ioutil.ReadAll(NewReader(bytes.NewReader(in[:n])))
+		if err == nil {
+			t.Fatalf("truncated to %d bytes: expected an error", n)
+		}
+	}
+}
+
+func TestReaderInvalid(t *testing.T) {
+	for name, in := range map[string][]byte{
+		"magic":      []byte("not zstd"),
+		"reserved":   {0x28, 0xb5, 0x2f, 0xfd, 0x08, 0x00},
+		"dictionary": {0x28, 0xb5, 0x2f, 0xfd, 0x01, 0x00, 0x01},
+		"window":     {0x28, 0xb5, 0x2f, 0xfd, 0x00, 0xf8},
+		"block type": {0x28, 0xb5, 0x2f, 0xfd, 0x20, 0x00, 0x07, 0x00, 0x00},
+	} {
+		if _, err := ioutil.ReadAll(NewReader(bytes.NewReader(in))); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+// TestReaderCorrupt flips bytes of the reference frames, which must
+// either fail to decode or, when only unused bits were flipped,
+// decode as before, and must never panic
+func TestReaderCorrupt(t *testing.T) {
+	r := rand.New(rand.NewSource(3))
+	for _, f := range frames(t) {
+		in := readFile(t, f.name)
+		for i := 0; i < 200; i++ {
+			corrupt := append([]byte(nil), in...)
+			for j := r.Intn(3); j >= 0; j-- {
+				corrupt[r.Intn(len(corrupt))] ^= byte(1 + r.Intn(255))
+			}
+			got, err := decodeLimited(corrupt)
+			if err == nil && !bytes.Equal(got, f.want) && !strings.Contains(f.name, "nocheck") {
+				t.Fatalf("%s: corruption went unnoticed", f.name)
+			}
+		}
+	}
+}
+
+// decodeLimited decodes up to 16MB, the frames corruption
+// produces may otherwise be too large to keep in memory
+func decodeLimited(in []byte) ([]byte, error) {
+	b := &bytes.Buffer{}
+	_, err := io.Copy(b, io.LimitReader(NewReader(bytes.NewReader(in)), 16<<20))
+	return b.Bytes(), err
+}
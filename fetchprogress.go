@@ -0,0 +1,82 @@
+package overseer
+
+import (
+	"io"
+	"time"
+)
+
+// fetchProgressInterval is how often Config.FetchProgress is called
+const fetchProgressInterval = time.Second
+
+// FetchProgress describes the download of a binary,
+// see Config.FetchProgress and Status.Fetching
+type FetchProgress struct {
+	Source  string `json:"source,omitempty"`
+	Version string `json:"version,omitempty"`
+	//Bytes of the binary read so far, of Size when known
+	Bytes int64 `json:"bytes"`
+	Size  int64 `json:"size,omitempty"`
+	//Elapsed since the download started, in nanoseconds
+	Elapsed time.Duration `json:"elapsed"`
+	//BytesPerSecond is the average throughput of the download
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	//Retries is the number of fetches which failed since the last
+	//one which succeeded, as the fetcher retries at its Interval
+	Retries int `json:"retries,omitempty"`
+	//Done is set once the download completed, or failed with Error
+	Done  bool   `json:"done,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// progressReader reports the progress of the download it reads
+type progressReader struct {
+	r        io.Reader
+	mp       *master
+	progress FetchProgress
+	started  time.Time
+	reported time.Time
+}
+
+// trackFetch reports the progress of reading the binary
+func (mp *master) trackFetch(r io.Reader, event Event, size int64) *progressReader {
+	p := &progressReader{r: r, mp: mp, started: time.Now()}
+	p.reported = p.started
+	p.progress = FetchProgress{Source: event.Source, Version: event.Version, Size: size, Retries: mp.metricCounts.fetchRetries()}
+	p.report()
+	return p
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if err := p.mp.cancelled(); err != nil {
+		//see RunContext
+		return 0, err
+	}
+	n, err := p.r.Read(b)
+	p.progress.Bytes += int64(n)
+	if time.Since(p.reported) >= fetchProgressInterval {
+		p.report()
+	}
+	return n, err
+}
+
+// finish reports the outcome of the download
+func (p *progressReader) finish(err error) {
+	p.progress.Done = true
+	if err != nil {
+		p.progress.Error = err.Error()
+	}
+	p.report()
+}
+
+func (p *progressReader) report() {
+	p.reported = time.Now()
+	p.progress.Elapsed = p.reported.Sub(p.started)
+	if s := p.progress.Elapsed.Seconds(); s > 0 {
+		p.progress.BytesPerSecond = float64(p.progress.Bytes) / s
+	}
+	progress := p.progress
+	p.mp.metricCounts.fetching(progress)
+	if p.mp.Config.FetchProgress != nil {
+		p.mp.Config.FetchProgress(progress)
+	}
+}
@@ -0,0 +1,77 @@
+package overseer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+const (
+	envFiles       = "OVERSEER_FILES"
+	envMasterFiles = "OVERSEER_MASTER_FILES"
+)
+
+// openFiles opens the files of Config.Files, or adopts those
+// handed over by the previous master
+func (mp *master) openFiles() error {
+	inherited := map[string]int{}
+	if v := os.Getenv(envMasterFiles); v != "" {
+		os.Unsetenv(envMasterFiles)
+		if err := json.Unmarshal([]byte(v), &inherited); err != nil {
+			mp.warnf("invalid %s: %s", envMasterFiles, err)
+		}
+	}
+	mp.files = map[string]*os.File{}
+	for name, fd := range inherited {
+		f := os.NewFile(uintptr(fd), name)
+		if _, ok := mp.Config.Files[name]; !ok {
+			f.Close()
+			continue
+		}
+		closeOnExec(f)
+		mp.files[name] = f
+	}
+	for name, open := range mp.Config.Files {
+		if _, ok := mp.files[name]; ok {
+			continue
+		}
+		f, err := open()
+		if err != nil {
+			return fmt.Errorf("failed to open file %s (%w)", name, err)
+		}
+		mp.files[name] = f
+	}
+	return nil
+}
+
+// fileNames returns the names of the files in the
+// order they are passed to the program
+func (mp *master) fileNames() []string {
+	names := make([]string, 0, len(mp.files))
+	for name := range mp.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// inheritFiles opens the files passed by the master
+func (sp *slave) inheritFiles() error {
+	sp.state.Files = map[string]*os.File{}
+	v := os.Getenv(envFiles)
+	if v == "" {
+		return nil
+	}
+	fds := map[string]int{}
+	if err := json.Unmarshal([]byte(v), &fds); err != nil {
+		return fmt.Errorf("invalid %s", envFiles)
+	}
+	for name, fd := range fds {
+		f := os.NewFile(uintptr(fd), name)
+		//not inherited by processes the program starts
+		closeOnExec(f)
+		sp.state.Files[name] = f
+	}
+	return nil
+}
@@ -8,34 +8,96 @@ import (
 	"net"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-func newOverseerListener(l net.Listener) *overseerListener {
-	return &overseerListener{
+// Drain reports the connections of a program which were still open
+// when its drain deadline passed, see Config.DrainExpired
+type Drain struct {
+	//Generation of the program, see State.Generation
+	Generation int
+	//Open is the number of connections closed by force
+	Open int
+	//Addresses holds the number of open connections by
+	//the address they were accepted from
+	Addresses map[string]int
+}
+
+//callbacks registered with State.OnDrain
+type drainHooks struct {
+	mux   sync.Mutex
+	hooks []func()
+}
+
+func (d *drainHooks) add(fn func()) {
+	d.mux.Lock()
+	d.hooks = append(d.hooks, fn)
+	d.mux.Unlock()
+}
+
+func (d *drainHooks) run() {
+	d.mux.Lock()
+	hooks := d.hooks
+	d.mux.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+func newOverseerListener(l net.Listener, o *ListenOptions) *overseerListener {
+	u := &overseerListener{
 		Listener:     l,
 		closeByForce: make(chan bool),
+		keepAlive:    3 * time.Minute, // see http.tcpKeepAliveListener
+	}
+	if o != nil {
+		if o.KeepAlive != 0 {
+			u.keepAlive = o.KeepAlive
+		}
+		u.delay = o.Delay
 	}
+	return u
 }
 
 //gracefully closing net.Listener
 type overseerListener struct {
+	open int64 //accessed atomically, first for alignment
 	net.Listener
+	addr         string
 	closeError   error
 	closeByForce chan bool
 	wg           sync.WaitGroup
+	keepAlive    time.Duration
+	delay        bool
 }
 
 func (l *overseerListener) Accept() (net.Conn, error) {
-	conn, err := l.Listener.(*net.TCPListener).AcceptTCP()
+	conn, err := l.Listener.Accept()
 	if err != nil {
 		return nil, err
 	}
-	conn.SetKeepAlive(true)                  // see http.tcpKeepAliveListener
-	conn.SetKeepAlivePeriod(3 * time.Minute) // see http.tcpKeepAliveListener
+	if tc, ok := conn.(*net.TCPConn); ok {
+		if l.keepAlive > 0 {
+			tc.SetKeepAlive(true)
+			tc.SetKeepAlivePeriod(l.keepAlive)
+		} else {
+			tc.SetKeepAlive(false)
+		}
+		if l.delay {
+			tc.SetNoDelay(false)
+		}
+	}
+	return l.track(conn), nil
+}
+
+//track the connection until it is closed
+func (l *overseerListener) track(conn net.Conn) net.Conn {
 	uconn := overseerConn{
 		Conn:   conn,
+		addr:   l.addr,
 		wg:     &l.wg,
+		open:   &l.open,
 		closed: make(chan bool),
 	}
 	go func() {
@@ -48,27 +110,24 @@ func (l *overseerListener) Accept() (net.Conn, error) {
 		}
 	}()
 	l.wg.Add(1)
-	return uconn, nil
+	atomic.AddInt64(&l.open, 1)
+	return uconn
 }
 
 //non-blocking trigger close
-func (l *overseerListener) release(timeout time.Duration) {
+func (l *overseerListener) release() {
 	//stop accepting connections - release fd
 	l.closeError = l.Listener.Close()
-	//start timer, close by force if deadline not met
-	waited := make(chan bool)
-	go func() {
-		l.wg.Wait()
-		waited <- true
-	}()
-	go func() {
-		select {
-		case <-time.After(timeout):
-			close(l.closeByForce)
-		case <-waited:
-			//no need to force close
-		}
-	}()
+}
+
+//number of accepted connections which are still open
+func (l *overseerListener) connections() int {
+	return int(atomic.LoadInt64(&l.open))
+}
+
+//close the remaining connections
+func (l *overseerListener) forceClose() {
+	close(l.closeByForce)
 }
 
 //blocking wait for close
@@ -79,21 +138,28 @@ func (l *overseerListener) Close() error {
 
 func (l *overseerListener) File() *os.File {
 	// returns a dup(2) - FD_CLOEXEC flag *not* set
-	tl := l.Listener.(*net.TCPListener)
-	fl, _ := tl.File()
-	return fl
+	if fl, ok := l.Listener.(interface {
+		File() (*os.File, error)
+	}); ok {
+		f, _ := fl.File()
+		return f
+	}
+	return nil
 }
 
 //notifying on close net.Conn
 type overseerConn struct {
 	net.Conn
+	addr   string
 	wg     *sync.WaitGroup
+	open   *int64
 	closed chan bool
 }
 
 func (o overseerConn) Close() error {
 	err := o.Conn.Close()
 	if err == nil {
+		atomic.AddInt64(o.open, -1)
 		o.wg.Done()
 		o.closed <- true
 	}
@@ -0,0 +1,104 @@
+package overseer
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"sync"
+)
+
+const envHandoffFD = "OVERSEER_HANDOFF_FD"
+
+// maxHandoffData limits the data sent with a handed off connection
+const maxHandoffData = 32 << 10
+
+var errNoHandoff = errors.New("overseer.Config.ConnectionHandoff required")
+
+// HandedOffConn is an established connection handed off
+// by the previous program, see State.HandOff
+type HandedOffConn struct {
+	net.Conn
+	//Address the connection was accepted from,
+	//as listed in State.Addresses
+	Address string
+	//Data passed by the previous program,
+	//describing how to resume the connection
+	Data []byte
+}
+
+// handoffMessage accompanies each handed off socket
+type handoffMessage struct {
+	Address string `json:"address"`
+	Data    []byte `json:"data,omitempty"`
+}
+
+// handoff is the program's end of its handoff socket
+type handoff struct {
+	mux  sync.Mutex
+	conn *net.UnixConn
+}
+
+// masterHandoff is the master's end of a slave's handoff socket
+type masterHandoff struct {
+	conn  *net.UnixConn
+	child *os.File
+}
+
+// queuedHandoff waits for the next program to be started
+type queuedHandoff struct {
+	msg []byte
+	fd  int
+}
+
+// HandOff passes an established connection, accepted from Listeners,
+// to the next program along with data describing how to resume it
+// mid-stream, instead of waiting for the connection to drain. It is
+// closed in this program once sent, and received by the next program
+// on State.HandedOff. Connections read through TLS or the PROXY
+// protocol cannot be handed off as their state would be lost.
+// Requires Config.ConnectionHandoff.
+func (s State) HandOff(c net.Conn, data []byte) error {
+	if s.handoff == nil {
+		return errNoHandoff
+	}
+	if len(data) > maxHandoffData {
+		return errors.New("handoff data too large")
+	}
+	return s.handoff.send(c, data)
+}
+
+// handoffFile returns a duplicate of the connection's socket
+// and the address it was accepted from
+func handoffFile(c net.Conn) (*os.File, string, error) {
+	switch c.(type) {
+	case *tls.Conn:
+		return nil, "", errors.New("TLS connections cannot be handed off")
+	case *proxyConn:
+		return nil, "", errors.New("PROXY protocol connections cannot be handed off")
+	}
+	addr := ""
+	if u, ok := c.(overseerConn); ok {
+		addr = u.addr
+		c = u.Conn
+	}
+	fc, ok := c.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		return nil, "", errors.New("connection has no file descriptor")
+	}
+	f, err := fc.File()
+	return f, addr, err
+}
+
+// trackHandoff counts the handed off connection
+// with the listener of its address
+func (sp *slave) trackHandoff(addr string, c net.Conn) net.Conn {
+	for _, l := range sp.listeners {
+		if l.addr == addr {
+			return l.track(c)
+		}
+	}
+	return c
+}
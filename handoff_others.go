@@ -0,0 +1,24 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package overseer
+
+import (
+	"net"
+)
+
+func (mp *master) handoffSocket() (*masterHandoff, error) {
+	return nil, nil
+}
+
+func (mp *master) handoffTo(h *masterHandoff) {}
+
+func (mp *master) closeHandoffs() {}
+
+func (sp *slave) openHandoff() error {
+	return nil
+}
+
+func (h *handoff) send(c net.Conn, data []byte) error {
+	return errNoHandoff
+}
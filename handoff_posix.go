@@ -0,0 +1,228 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package overseer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// handoffTimeout is how long a connection may take to be handed
+// off to the next program, which may not be receiving them
+const handoffTimeout = 5 * time.Second
+
+// handoffSocket creates the socket over which the slave hands
+// off connections to the master, which relays them to the next
+func (mp *master) handoffSocket() (*masterHandoff, error) {
+	if !mp.Config.ConnectionHandoff {
+		return nil, nil
+	}
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+	f := os.NewFile(uintptr(fds[0]), "handoff")
+	c, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		syscall.Close(fds[1])
+		return nil, err
+	}
+	return &masterHandoff{conn: c.(*net.UnixConn), child: os.NewFile(uintptr(fds[1]), "handoff")}, nil
+}
+
+// handoffTo makes the started slave the recipient of handed off
+// connections, including those queued while it was starting
+func (mp *master) handoffTo(h *masterHandoff) {
+	h.child.Close()
+	mp.handoffMux.Lock()
+	mp.handoffConn = h.conn
+	queued := mp.handoffQueue
+	mp.handoffQueue = nil
+	mp.handoffMux.Unlock()
+	//not awaited, the program is yet to be supervised
+	go func() {
+		for _, q := range queued {
+			mp.forwardHandoff(h.conn, q.msg, q.fd)
+		}
+	}()
+	go mp.relayHandoffs(h.conn)
+}
+
+// closeHandoffs closes the connections queued
+// for a next program, which will not be started
+func (mp *master) closeHandoffs() {
+	mp.handoffMux.Lock()
+	queued := mp.handoffQueue
+	mp.handoffQueue = nil
+	mp.handoffMux.Unlock()
+	for _, q := range queued {
+		syscall.Close(q.fd)
+	}
+}
+
+// relayHandoffs reads the slave's handed off connections until it exits
+func (mp *master) relayHandoffs(conn *net.UnixConn) {
+	defer conn.Close()
+	r := &handoffReader{conn: conn}
+	for {
+		msg, fd, err := r.next()
+		if err != nil {
+			return
+		}
+		mp.handoffMux.Lock()
+		to := mp.handoffConn
+		if to == nil || to == conn {
+			//the next program is yet to start
+			mp.handoffQueue = append(mp.handoffQueue, queuedHandoff{msg: msg, fd: fd})
+			mp.handoffMux.Unlock()
+			continue
+		}
+		mp.handoffMux.Unlock()
+		mp.forwardHandoff(to, msg, fd)
+	}
+}
+
+// forwardHandoff sends the connection to the program, one at a time,
+// failing once the program has not received it within handoffTimeout
+func (mp *master) forwardHandoff(to *net.UnixConn, msg []byte, fd int) {
+	defer syscall.Close(fd)
+	mp.forwardMux.Lock()
+	defer mp.forwardMux.Unlock()
+	to.SetWriteDeadline(time.Now().Add(handoffTimeout))
+	if err := writeHandoff(to, msg, fd); err != nil {
+		if ne, ok := err.(net.Error); ok && ne.Timeout() {
+			//part of the message may have been written,
+			//the program no longer receives handoffs
+			to.Close()
+		}
+		mp.warnf("failed to hand off connection (%s)", err)
+		return
+	}
+	mp.debugf("handed off connection")
+}
+
+// openHandoff opens the program's handoff socket
+func (sp *slave) openHandoff() error {
+	fd, err := strconv.Atoi(os.Getenv(envHandoffFD))
+	if err != nil {
+		return nil
+	}
+	f := os.NewFile(uintptr(fd), "handoff")
+	c, err := net.FileConn(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to open handoff socket (%w)", err)
+	}
+	conn := c.(*net.UnixConn)
+	received := make(chan HandedOffConn)
+	sp.state.handoff = &handoff{conn: conn}
+	sp.state.HandedOff = received
+	go sp.receiveHandoffs(conn, received)
+	return nil
+}
+
+func (sp *slave) receiveHandoffs(conn *net.UnixConn, received chan<- HandedOffConn) {
+	r := &handoffReader{conn: conn}
+	for {
+		msg, fd, err := r.next()
+		if err != nil {
+			return
+		}
+		m := handoffMessage{}
+		json.Unmarshal(msg, &m)
+		f := os.NewFile(uintptr(fd), "")
+		c, err := net.FileConn(f)
+		f.Close()
+		if err != nil {
+			sp.warnf("failed to receive handed off connection (%s)", err)
+			continue
+		}
+		received <- HandedOffConn{Conn: sp.trackHandoff(m.Address, c), Address: m.Address, Data: m.Data}
+	}
+}
+
+func (h *handoff) send(c net.Conn, data []byte) error {
+	f, addr, err := handoffFile(c)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	msg, _ := json.Marshal(handoffMessage{Address: addr, Data: data})
+	h.mux.Lock()
+	err = writeHandoff(h.conn, msg, int(f.Fd()))
+	h.mux.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to hand off connection (%w)", err)
+	}
+	return c.Close()
+}
+
+// writeHandoff writes the length prefixed message,
+// the socket is attached to its first byte
+func writeHandoff(conn *net.UnixConn, msg []byte, fd int) error {
+	frame := make([]byte, 4+len(msg))
+	binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+	copy(frame[4:], msg)
+	n, _, err := conn.WriteMsgUnix(frame, syscall.UnixRights(fd), nil)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(frame[n:])
+	return err
+}
+
+// handoffReader reads length prefixed messages, each
+// accompanied by a socket, from a stream socket
+type handoffReader struct {
+	conn *net.UnixConn
+	buf  []byte
+	fds  []int
+}
+
+func (r *handoffReader) next() ([]byte, int, error) {
+	b := make([]byte, 64<<10)
+	oob := make([]byte, syscall.CmsgSpace(4*4))
+	for {
+		if len(r.buf) >= 4 && len(r.fds) > 0 {
+			if n := int(binary.BigEndian.Uint32(r.buf)); len(r.buf) >= 4+n {
+				msg := append([]byte{}, r.buf[4:4+n]...)
+				fd := r.fds[0]
+				r.buf = r.buf[4+n:]
+				r.fds = r.fds[1:]
+				return msg, fd, nil
+			}
+		}
+		n, oobn, _, _, err := r.conn.ReadMsgUnix(b, oob)
+		if err != nil {
+			for _, fd := range r.fds {
+				syscall.Close(fd)
+			}
+			return nil, -1, err
+		}
+		r.buf = append(r.buf, b[:n]...)
+		if oobn == 0 {
+			continue
+		}
+		msgs, err := syscall.ParseSocketControlMessage(oob[:oobn])
+		if err != nil {
+			continue
+		}
+		for _, m := range msgs {
+			fds, _ := syscall.ParseUnixRights(&m)
+			for _, fd := range fds {
+				syscall.CloseOnExec(fd)
+			}
+			r.fds = append(r.fds, fds...)
+		}
+	}
+}
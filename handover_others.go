@@ -0,0 +1,96 @@
+//go:build !windows
+// +build !windows
+
+package overseer
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// envRequestFD is set when the slave runs as another
+// user, which may not signal the master
+const envRequestFD = "OVERSEER_REQUEST_FD"
+
+// childFD returns the descriptor of files[i] in the slave,
+// ExtraFiles are numbered from 3 onwards
+func childFD(i int, f *os.File) uintptr {
+	return uintptr(3 + i)
+}
+
+// passFiles hands the files over to the slave process,
+// the returned func is called once it has started
+func passFiles(cmd *exec.Cmd, files []*os.File) func() {
+	cmd.ExtraFiles = files
+	return func() {}
+}
+
+// inheritedFD returns the descriptor of the i'th socket
+func inheritedFD(i int) uintptr {
+	return uintptr(3 + i)
+}
+
+// signalPipes is only needed where processes cannot be signaled,
+// a slave running as another user writes its requests to a pipe
+func (mp *master) signalPipes(n int) ([]string, []*os.File, error) {
+	if mp.credential == nil {
+		return nil, nil, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	go mp.serveRequests(r)
+	e := fmt.Sprintf("%s=%d", envRequestFD, childFD(n, w))
+	return []string{e}, []*os.File{w}, nil
+}
+
+// serveRequests handles the slave's requests until it exits
+func (mp *master) serveRequests(r *os.File) {
+	defer r.Close()
+	b := make([]byte, 1)
+	for {
+		if _, err := r.Read(b); err != nil {
+			return
+		}
+		switch masterRequest(b[0]) {
+		case requestReleased:
+			mp.slaveReleased()
+		case requestRestart:
+			mp.triggerRestart()
+		case requestFetch:
+			go mp.triggerFetch()
+		}
+	}
+}
+
+func (mp *master) signalSlave(s os.Signal) error {
+	return mp.activeProc().Signal(s)
+}
+
+// watchMaster opens the request pipe, if any,
+// the master signals the slave itself
+func (sp *slave) watchMaster(restart chan<- os.Signal) {
+	if fd, err := strconv.Atoi(os.Getenv(envRequestFD)); err == nil {
+		sp.requests = os.NewFile(uintptr(fd), "requests")
+	}
+}
+
+// signalMaster passes the request by signal, or over the request
+// pipe, to masters which do not speak the control protocol
+func (sp *slave) signalMaster(r masterRequest) error {
+	if sp.requests != nil {
+		_, err := sp.requests.Write([]byte{byte(r)})
+		return err
+	}
+	var s os.Signal = SIGUSR1
+	switch r {
+	case requestRestart:
+		s = sp.Config.RestartSignal
+	case requestFetch:
+		s = sp.Config.FetchSignal
+	}
+	return sp.masterProc.Signal(s)
+}
@@ -0,0 +1,157 @@
+//go:build windows
+// +build windows
+
+package overseer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	envHandles       = "OVERSEER_HANDLES"
+	envSignalHandles = "OVERSEER_SIGNAL_HANDLES"
+)
+
+// processes cannot be signaled on windows, signals
+// are written to a pipe held by the slave instead
+const (
+	signalRestart   byte = 'r'
+	signalTerminate byte = 't'
+)
+
+// childFD returns the handle of f in the slave,
+// inherited handles keep their value
+func childFD(i int, f *os.File) uintptr {
+	return f.Fd()
+}
+
+// passFiles marks the files inheritable for the duration of the
+// slave's creation, the returned func is called once it has started.
+// Socket handles, duplicated by net's File, are adopted by the slave
+// with net.FileListener just as descriptors are elsewhere.
+func passFiles(cmd *exec.Cmd, files []*os.File) func() {
+	handles := make([]syscall.Handle, len(files))
+	values := make([]string, len(files))
+	for i, f := range files {
+		handles[i] = syscall.Handle(f.Fd())
+		values[i] = strconv.FormatUint(uint64(handles[i]), 10)
+		syscall.SetHandleInformation(handles[i], syscall.HANDLE_FLAG_INHERIT, syscall.HANDLE_FLAG_INHERIT)
+	}
+	inheritHandles(cmd, handles)
+	cmd.Env = append(cmd.Env, envHandles+"="+strings.Join(values, ","))
+	return func() {
+		//not inherited by other processes
+		for _, h := range handles {
+			syscall.SetHandleInformation(h, syscall.HANDLE_FLAG_INHERIT, 0)
+		}
+	}
+}
+
+// inheritedFD returns the handle of the i'th socket
+func inheritedFD(i int) uintptr {
+	values := strings.Split(os.Getenv(envHandles), ",")
+	if i < len(values) {
+		if h, err := strconv.ParseUint(values[i], 10, 64); err == nil {
+			return uintptr(h)
+		}
+	}
+	return uintptr(syscall.InvalidHandle)
+}
+
+// signalPipes creates the pipes which carry signals to the slave
+// and its requests to the master, returning the slave's ends
+func (mp *master) signalPipes(n int) ([]string, []*os.File, error) {
+	sigR, sigW, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	reqR, reqW, err := os.Pipe()
+	if err != nil {
+		sigR.Close()
+		sigW.Close()
+		return nil, nil, err
+	}
+	mp.slaveSignals = sigW
+	go mp.serveRequests(reqR, sigW)
+	e := fmt.Sprintf("%s=%d,%d", envSignalHandles, childFD(n, sigR), childFD(n+1, reqW))
+	return []string{e}, []*os.File{sigR, reqW}, nil
+}
+
+// serveRequests handles the slave's requests until it exits
+func (mp *master) serveRequests(r, w *os.File) {
+	defer r.Close()
+	defer w.Close()
+	b := make([]byte, 1)
+	for {
+		if _, err := r.Read(b); err != nil {
+			return
+		}
+		switch masterRequest(b[0]) {
+		case requestReleased:
+			mp.released()
+		case requestRestart:
+			mp.triggerRestart()
+		case requestFetch:
+			go mp.triggerFetch()
+		}
+	}
+}
+
+func (mp *master) signalSlave(s os.Signal) error {
+	if s == os.Kill || mp.slaveSignals == nil {
+		return mp.activeProc().Kill()
+	}
+	code := signalTerminate
+	if s == mp.Config.RestartSignal {
+		code = signalRestart
+	}
+	_, err := mp.slaveSignals.Write([]byte{code})
+	return err
+}
+
+// watchMaster reads the signals written by the master
+func (sp *slave) watchMaster(restart chan<- os.Signal) {
+	values := strings.Split(os.Getenv(envSignalHandles), ",")
+	if len(values) != 2 {
+		return
+	}
+	r, err1 := strconv.ParseUint(values[0], 10, 64)
+	w, err2 := strconv.ParseUint(values[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return
+	}
+	sp.requests = os.NewFile(uintptr(w), "requests")
+	signals := os.NewFile(uintptr(r), "signals")
+	go func() {
+		b := make([]byte, 1)
+		for {
+			if _, err := signals.Read(b); err != nil {
+				//the master has exited
+				os.Exit(1)
+			}
+			if b[0] != signalRestart {
+				os.Exit(1)
+			}
+			select {
+			case restart <- sp.Config.RestartSignal:
+			default:
+			}
+		}
+	}()
+}
+
+// signalMaster passes the request by signal, or over the request
+// pipe, to masters which do not speak the control protocol
+func (sp *slave) signalMaster(r masterRequest) error {
+	if sp.requests == nil {
+		return errors.New("no pipe to master")
+	}
+	_, err := sp.requests.Write([]byte{byte(r)})
+	return err
+}
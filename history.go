@@ -0,0 +1,168 @@
+package overseer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultHistorySize = 100
+
+// History is a list of upgrade lifecycle events, oldest first
+type History []Event
+
+// At returns the event which started the binary
+// running at the given time, if known
+func (h History) At(t time.Time) (Event, bool) {
+	for i := len(h) - 1; i >= 0; i-- {
+		e := h[i]
+		if e.Time.After(t) {
+			continue
+		}
+		switch e.Type {
+		case EventStart, EventRestart, EventRollback:
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+// HistoryHandler serves the History as JSON, oldest first, such as
+// when each upgrade was installed. The events may be filtered by their
+// type with "?event=upgrade,rollback", and by their time with
+// "?since=2006-01-02T15:04:05Z". It may be mounted by the program on
+// its own server, see UpgradeHistory and Config.AdminAddress.
+func HistoryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentProcess == nil {
+			http.Error(w, ErrNotRunning.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		var since time.Time
+		if s := r.URL.Query().Get("since"); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				http.Error(w, "invalid since, RFC3339 time required", http.StatusBadRequest)
+				return
+			}
+			since = t
+		}
+		var types map[EventType]bool
+		if s := r.URL.Query().Get("event"); s != "" {
+			types = map[EventType]bool{}
+			for _, t := range strings.Split(s, ",") {
+				types[EventType(t)] = true
+			}
+		}
+		h, err := currentProcess.history()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		filtered := History{}
+		for _, e := range h {
+			if e.Time.Before(since) || (types != nil && !types[e.Type]) {
+				continue
+			}
+			filtered = append(filtered, e)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(filtered)
+	})
+}
+
+// inHistory reports whether events of this type are kept in the History
+func inHistory(t EventType) bool {
+	switch t {
+	case EventStart, EventUpgrade, EventRestart, EventRollback, EventFailure, EventCrash:
+		return true
+	}
+	return false
+}
+
+// loadHistory reads the persisted history, when configured
+func (mp *master) loadHistory() {
+	if mp.Config.HistoryFile == "" {
+		return
+	}
+	h, err := readHistory(mp.Config.HistoryFile)
+	if err != nil {
+		mp.warnf("%s", err)
+		return
+	}
+	mp.eventMux.Lock()
+	mp.eventHistory = h
+	mp.eventMux.Unlock()
+}
+
+// record adds the event to the history, must be called
+// while holding the eventMux
+func (mp *master) record(e Event) {
+	if !inHistory(e.Type) {
+		return
+	}
+	size := mp.Config.HistorySize
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+	mp.eventHistory = append(mp.eventHistory, e)
+	if n := len(mp.eventHistory); n > size {
+		mp.eventHistory = append(History{}, mp.eventHistory[n-size:]...)
+	}
+	if mp.Config.HistoryFile == "" {
+		return
+	}
+	if err := writeHistory(mp.Config.HistoryFile, mp.eventHistory); err != nil {
+		mp.warnf("%s", err)
+	}
+}
+
+func (mp *master) history() (History, error) {
+	mp.eventMux.Lock()
+	defer mp.eventMux.Unlock()
+	return append(History{}, mp.eventHistory...), nil
+}
+
+func readHistory(path string) (History, error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return History{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read history (%w)", err)
+	}
+	h := History{}
+	if err := json.Unmarshal(b, &h); err != nil {
+		return nil, fmt.Errorf("invalid history (%w)", err)
+	}
+	return h, nil
+}
+
+func writeHistory(path string, h History) error {
+	b, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	if err := replaceFile(path, b); err != nil {
+		return fmt.Errorf("failed to write history (%w)", err)
+	}
+	return nil
+}
+
+// replaceFile writes the file by renaming a temporary file
+// over it, so that readers never observe a partial write
+func replaceFile(path string, b []byte) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+"."+token())
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
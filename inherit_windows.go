@@ -0,0 +1,15 @@
+//go:build windows && go1.17
+// +build windows,go1.17
+
+package overseer
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// inheritHandles passes the inheritable handles to the slave. Since
+// go1.17 only the handles listed in the SysProcAttr are inherited.
+func inheritHandles(cmd *exec.Cmd, handles []syscall.Handle) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{AdditionalInheritedHandles: handles}
+}
@@ -0,0 +1,14 @@
+//go:build windows && !go1.17
+// +build windows,!go1.17
+
+package overseer
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// inheritHandles passes the inheritable handles to the slave. Before
+// go1.17 every inheritable handle is inherited, so marking them
+// inheritable in passFiles is all that is needed.
+func inheritHandles(cmd *exec.Cmd, handles []syscall.Handle) {}
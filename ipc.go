@@ -0,0 +1,228 @@
+package overseer
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// controlVersion is the version of the protocol spoken over the control
+// pipe, each message a line of JSON, answered by the master. Programs
+// introduce themselves with a "hello" message, masters which predate it
+// reply with an error, and are then signaled instead. Likewise, masters
+// still handle the signals of programs which predate it.
+const controlVersion = 1
+
+// maxSavedState limits the state passed to the next program,
+// each message must fit a line of the master's scanner
+const maxSavedState = 32 << 10
+
+// heartbeatInterval is how often the program sends a heartbeat
+const heartbeatInterval = time.Second
+
+// controlOps are the requests of the program sent
+// over the control pipe, see requestMaster
+var controlOps = map[masterRequest]string{
+	requestReleased: "released",
+	requestRestart:  "restart",
+	requestFetch:    "fetch",
+}
+
+// controlPeer is what the master knows of the
+// program at the other end of a control pipe
+type controlPeer struct {
+	mux       sync.Mutex
+	slaveID   int
+	version   int
+	pid       int
+	heartbeat time.Time
+	ready     bool
+	readied   chan bool
+	closed    chan bool
+	metadata  map[string]string
+}
+
+// hello handles the program's introduction
+func (mp *master) hello(peer *controlPeer, req listenerRequest) listenerResponse {
+	peer.mux.Lock()
+	peer.version = req.Version
+	peer.pid = req.Pid
+	peer.heartbeat = time.Now()
+	peer.mux.Unlock()
+	mp.debugf("program %d speaks control protocol v%d", req.Pid, req.Version)
+	if mp.Config.HeartbeatTimeout > 0 {
+		go mp.watchHeartbeats(peer)
+	}
+	mp.stateMux.Lock()
+	state := mp.savedState
+	mp.stateMux.Unlock()
+	return listenerResponse{Version: controlVersion, Pid: os.Getpid(), Metadata: peer.metadata, State: state}
+}
+
+// metadata is passed to the next program, see Config.Metadata
+func (mp *master) metadata() map[string]string {
+	if mp.Config.Metadata == nil {
+		return nil
+	}
+	return mp.Config.Metadata()
+}
+
+// watchHeartbeats kills the program once it has missed its heartbeats
+// for HeartbeatTimeout, the master then handles its exit as any other
+func (mp *master) watchHeartbeats(peer *controlPeer) {
+	for {
+		time.Sleep(heartbeatInterval)
+		peer.mux.Lock()
+		last, pid := peer.heartbeat, peer.pid
+		peer.mux.Unlock()
+		if last.IsZero() {
+			//the control pipe is closed
+			return
+		}
+		if time.Since(last) < mp.Config.HeartbeatTimeout {
+			continue
+		}
+		mp.warnf("program %d missed its heartbeats for %s, killing it", pid, mp.Config.HeartbeatTimeout)
+		if p, err := os.FindProcess(pid); err == nil {
+			p.Kill()
+		}
+		return
+	}
+}
+
+// handleControl handles the requests of the protocol, other
+// than those concerning listeners, see serveControl
+func (mp *master) handleControl(peer *controlPeer, req listenerRequest) (listenerResponse, bool) {
+	resp := listenerResponse{}
+	switch req.Op {
+	case "hello":
+		resp = mp.hello(peer, req)
+	case "heartbeat":
+		peer.mux.Lock()
+		peer.heartbeat = time.Now()
+		peer.mux.Unlock()
+	case "ready":
+		peer.mux.Lock()
+		if !peer.ready {
+			peer.ready = true
+			close(peer.readied)
+		}
+		peer.mux.Unlock()
+		mp.debugf("program %d is ready", peer.pid)
+	case "released":
+		mp.slaveReleased()
+	case "restart":
+		resp.Status = mp.requestRestart(req.Reason)
+	case "fetch":
+		go mp.triggerFetch()
+	case "usage":
+		u, err := mp.usage(peer)
+		if err != nil {
+			resp.Error = err.Error()
+		}
+		resp.Usage = u
+	case "metrics":
+		resp.Metrics, _ = mp.metrics()
+	case "status":
+		s, _ := mp.status()
+		resp.Report = &s
+	case "history":
+		resp.History, _ = mp.history()
+	case "vars":
+		v := mp.vars()
+		resp.Vars = &v
+	case "save":
+		mp.stateMux.Lock()
+		mp.savedState = req.State
+		mp.stateMux.Unlock()
+		mp.debugf("program saved its state (%d bytes)", len(req.State))
+	case "reload":
+		if err := mp.reload(); err != nil {
+			resp.Error = err.Error()
+		}
+	default:
+		return resp, false
+	}
+	return resp, true
+}
+
+// slaveReleased handles the program's notification that its sockets
+// are released, or bound with ReusePort, by signal or control pipe
+func (mp *master) slaveReleased() {
+	//with ReusePort, the program has bound its sockets
+	if mp.ReusePort {
+		select {
+		case mp.slaveBound <- true:
+		default:
+		}
+		return
+	}
+	//**during a restart** the restart continues
+	mp.released()
+}
+
+// helloMaster introduces the program to the master, which replies
+// with the version of the protocol it speaks, if any
+func (sp *slave) helloMaster() {
+	resp, err := sp.request(listenerRequest{Op: "hello", Version: controlVersion, Pid: os.Getpid()})
+	if err != nil || resp.Error != "" || resp.Version < 1 {
+		//signal the master instead
+		return
+	}
+	sp.controlVersion = resp.Version
+	sp.state.Metadata = resp.Metadata
+	sp.state.SavedState = resp.State
+	go func() {
+		for {
+			time.Sleep(heartbeatInterval)
+			if _, err := sp.request(listenerRequest{Op: "heartbeat"}); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// notifyReady tells the master the program is ready, once it is about
+// to run, or once it calls State.Ready with Config.WaitReady
+func (sp *slave) notifyReady() {
+	sp.readyOnce.Do(func() {
+		if sp.controlVersion >= 1 {
+			sp.request(listenerRequest{Op: "ready"})
+		}
+	})
+}
+
+// saveState passes the state to the master, see State.SaveState
+func (sp *slave) saveState(data []byte) error {
+	if sp.controlVersion < 1 {
+		return errors.New("the master does not speak the control protocol")
+	}
+	if len(data) > maxSavedState {
+		return errors.New("saved state too large")
+	}
+	resp, err := sp.request(listenerRequest{Op: "save", State: data})
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
+
+// requestMaster passes the request to the master, over
+// the control pipe when the master speaks the protocol
+func (sp *slave) requestMaster(r masterRequest) error {
+	if sp.controlVersion < 1 {
+		return sp.signalMaster(r)
+	}
+	resp, err := sp.request(listenerRequest{Op: controlOps[r]})
+	if err != nil {
+		return err
+	}
+	if resp.Error != "" {
+		return errors.New(resp.Error)
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package overseer
+
+import (
+	"os"
+)
+
+// joinJob is only needed on windows, elsewhere the
+// program exits once the master has, see watchParent
+func (mp *master) joinJob(p *os.Process) {}
@@ -0,0 +1,91 @@
+package overseer
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	kernel32                 = syscall.NewLazyDLL("kernel32.dll")
+	createJobObject          = kernel32.NewProc("CreateJobObjectW")
+	setInformationJobObject  = kernel32.NewProc("SetInformationJobObject")
+	assignProcessToJobObject = kernel32.NewProc("AssignProcessToJobObject")
+)
+
+const (
+	jobObjectExtendedLimitInformation = 9
+	jobObjectLimitKillOnJobClose      = 0x2000
+	processSetQuota                   = 0x0100
+	processTerminate                  = 0x0001
+)
+
+type jobBasicLimitInformation struct {
+	perProcessUserTimeLimit int64
+	perJobUserTimeLimit     int64
+	limitFlags              uint32
+	minimumWorkingSetSize   uintptr
+	maximumWorkingSetSize   uintptr
+	activeProcessLimit      uint32
+	affinity                uintptr
+	priorityClass           uint32
+	schedulingClass         uint32
+}
+
+type jobExtendedLimitInformation struct {
+	basicLimitInformation jobBasicLimitInformation
+	ioInfo                [6]uint64
+	processMemoryLimit    uintptr
+	jobMemoryLimit        uintptr
+	peakProcessMemoryUsed uintptr
+	peakJobMemoryUsed     uintptr
+}
+
+// job contains the programs, and the processes they start, which
+// are killed once its handle is closed as the master exits
+var job struct {
+	once   sync.Once
+	handle uintptr
+	err    error
+}
+
+func openJob() (uintptr, error) {
+	job.once.Do(func() {
+		h, _, err := createJobObject.Call(0, 0)
+		if h == 0 {
+			job.err = err
+			return
+		}
+		info := jobExtendedLimitInformation{}
+		info.basicLimitInformation.limitFlags = jobObjectLimitKillOnJobClose
+		if r, _, err := setInformationJobObject.Call(h, jobObjectExtendedLimitInformation, uintptr(unsafe.Pointer(&info)), unsafe.Sizeof(info)); r == 0 {
+			syscall.CloseHandle(syscall.Handle(h))
+			job.err = err
+			return
+		}
+		//the handle is left open until the master exits
+		job.handle = h
+	})
+	return job.handle, job.err
+}
+
+// joinJob places the started program in the master's job object,
+// so that it, and its own children, do not outlive the master
+func (mp *master) joinJob(p *os.Process) {
+	h, err := openJob()
+	if err != nil {
+		mp.debugf("failed to create job object (%s)", err)
+		return
+	}
+	proc, err := syscall.OpenProcess(processSetQuota|processTerminate, false, uint32(p.Pid))
+	if err != nil {
+		mp.debugf("failed to open program (%s)", err)
+		return
+	}
+	defer syscall.CloseHandle(proc)
+	if r, _, err := assignProcessToJobObject.Call(h, uintptr(proc)); r == 0 {
+		//nested jobs are not supported before windows 8
+		mp.debugf("failed to assign program to job object (%s)", err)
+	}
+}
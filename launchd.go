@@ -0,0 +1,31 @@
+package overseer
+
+import (
+	"os"
+)
+
+// Launchd configures the master for launchd on macOS, see Config.Launchd
+type Launchd struct {
+	//Sockets are the names of entries in the Sockets dictionary of the
+	//job's plist, their sockets are used for the addresses they match
+	//(by bound address) instead of binding new sockets, see
+	//launch_activate_socket(3). Requires cgo.
+	Sockets []string
+}
+
+// launchdFiles adds the sockets activated by launchd to files
+func (mp *master) launchdFiles(files map[string]*os.File) {
+	if mp.Config.Launchd == nil {
+		return
+	}
+	for _, name := range mp.Config.Launchd.Sockets {
+		fds, err := launchActivateSocket(name)
+		if err != nil {
+			mp.warnf("failed to activate launchd socket %s (%s)", name, err)
+			continue
+		}
+		for _, fd := range fds {
+			mp.adoptActivated(files, uintptr(fd), name)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+//go:build darwin && cgo
+// +build darwin,cgo
+
+package overseer
+
+/*
+#include <launch.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// launchActivateSocket returns the file descriptors of
+// the named socket entry of the launchd job
+func launchActivateSocket(name string) ([]int, error) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	var fds *C.int
+	var n C.size_t
+	if errno := C.launch_activate_socket(cname, &fds, &n); errno != 0 {
+		return nil, syscall.Errno(errno)
+	}
+	defer C.free(unsafe.Pointer(fds))
+	list := (*[1 << 20]C.int)(unsafe.Pointer(fds))[:n:n]
+	result := make([]int, n)
+	for i, fd := range list {
+		result[i] = int(fd)
+	}
+	return result, nil
+}
@@ -0,0 +1,12 @@
+//go:build !darwin || !cgo
+// +build !darwin !cgo
+
+package overseer
+
+import (
+	"errors"
+)
+
+func launchActivateSocket(name string) ([]int, error) {
+	return nil, errors.New("launchd sockets require macOS and cgo")
+}
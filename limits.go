@@ -0,0 +1,93 @@
+package overseer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Limits are the resource limits of the program, see Config.Limits
+type Limits struct {
+	//NoFile limits the number of files the program may open
+	//(RLIMIT_NOFILE).
+	NoFile uint64
+	//AddressSpace limits the virtual memory of the program in bytes
+	//(RLIMIT_AS). Note, the Go runtime reserves address space well
+	//beyond the memory it uses.
+	AddressSpace uint64
+	//Core limits the size of the program's core dumps in bytes
+	//(RLIMIT_CORE), NoCore disables them.
+	Core   uint64
+	NoCore bool
+	//CGroup is a cgroup v2 directory, created if missing, in which
+	//each program is placed once started (e.g. "/sys/fs/cgroup/myapp").
+	//The controllers of Memory and CPU must be enabled by its parent's
+	//cgroup.subtree_control. During a restart, both programs share the
+	//cgroup's limits. Linux only.
+	CGroup string
+	//Memory is the memory.max of CGroup in bytes.
+	Memory int64
+	//CPU is the cpu.max of CGroup in CPUs (e.g. 1.5).
+	CPU float64
+}
+
+func (l *Limits) rlimits() bool {
+	return l.NoFile > 0 || l.AddressSpace > 0 || l.Core > 0 || l.NoCore
+}
+
+// setupCGroup creates the cgroup of Config.Limits and sets its limits
+func (mp *master) setupCGroup() error {
+	l := mp.Config.Limits
+	if l == nil || l.CGroup == "" {
+		return nil
+	}
+	if err := os.MkdirAll(l.CGroup, 0755); err != nil {
+		return fmt.Errorf("failed to create cgroup (%w)", err)
+	}
+	if l.Memory > 0 {
+		if err := writeCGroup(l.CGroup, "memory.max", strconv.FormatInt(l.Memory, 10)); err != nil {
+			return err
+		}
+	}
+	if l.CPU > 0 {
+		const period = 100000
+		if err := writeCGroup(l.CGroup, "cpu.max", fmt.Sprintf("%d %d", int64(l.CPU*period), period)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinCGroup places the started program in the cgroup of Config.Limits
+func (mp *master) joinCGroup(pid int) {
+	l := mp.Config.Limits
+	if l == nil || l.CGroup == "" {
+		return
+	}
+	if err := writeCGroup(l.CGroup, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+		mp.warnf("%s", err)
+	}
+}
+
+func writeCGroup(dir, name, value string) error {
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("failed to write cgroup %s (%w)", name, err)
+	}
+	return nil
+}
+
+// setLimits applies the rlimits of Config.Limits to the program
+func (sp *slave) setLimits() error {
+	if sp.Config.CoreDumps {
+		if err := allowCoreDumps(); err != nil {
+			sp.warnf("%s", err)
+		}
+	}
+	l := sp.Config.Limits
+	if l == nil || !l.rlimits() {
+		return nil
+	}
+	return setRlimits(l)
+}
@@ -0,0 +1,7 @@
+package overseer
+
+import "syscall"
+
+func rlimit(value uint64) syscall.Rlimit {
+	return syscall.Rlimit{Cur: int64(value), Max: int64(value)}
+}
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package overseer
+
+import "errors"
+
+func allowCoreDumps() error {
+	return nil
+}
+
+func setRlimits(l *Limits) error {
+	return errors.New("resource limits are not supported on this platform")
+}
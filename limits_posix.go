@@ -0,0 +1,51 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package overseer
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func setRlimits(l *Limits) error {
+	if l.NoFile > 0 {
+		if err := setRlimit(syscall.RLIMIT_NOFILE, "NOFILE", l.NoFile); err != nil {
+			return err
+		}
+	}
+	if l.AddressSpace > 0 {
+		if err := setRlimit(syscall.RLIMIT_AS, "AS", l.AddressSpace); err != nil {
+			return err
+		}
+	}
+	if l.Core > 0 || l.NoCore {
+		if err := setRlimit(syscall.RLIMIT_CORE, "CORE", l.Core); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// allowCoreDumps raises the soft RLIMIT_CORE to the hard limit
+func allowCoreDumps() error {
+	r := syscall.Rlimit{}
+	if err := syscall.Getrlimit(syscall.RLIMIT_CORE, &r); err != nil {
+		return fmt.Errorf("failed to get RLIMIT_CORE (%w)", err)
+	}
+	r.Cur = r.Max
+	if err := syscall.Setrlimit(syscall.RLIMIT_CORE, &r); err != nil {
+		return fmt.Errorf("failed to set RLIMIT_CORE (%w)", err)
+	}
+	return nil
+}
+
+// setRlimit sets both the soft and hard limits,
+// so that the program may not raise it again
+func setRlimit(resource int, name string, value uint64) error {
+	r := rlimit(value)
+	if err := syscall.Setrlimit(resource, &r); err != nil {
+		return fmt.Errorf("failed to set RLIMIT_%s (%w)", name, err)
+	}
+	return nil
+}
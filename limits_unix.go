@@ -0,0 +1,10 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package overseer
+
+import "syscall"
+
+func rlimit(value uint64) syscall.Rlimit {
+	return syscall.Rlimit{Cur: value, Max: value}
+}
@@ -0,0 +1,428 @@
+package overseer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// errReleased is returned by reads of released packet connections
+var errReleased = &net.OpError{Op: "read", Net: "udp", Err: errors.New("use of released connection")}
+
+// parseAddress splits a configured address into its network
+// and address, addresses without a network are TCP
+func parseAddress(addr string) (network, address string) {
+	if i := strings.Index(addr, "://"); i > 0 {
+		return addr[:i], addr[i+3:]
+	}
+	return "tcp", addr
+}
+
+// isSCTPNetwork reports whether the network is SCTP, whose
+// sockets are passed to the program as State.SCTPFiles
+func isSCTPNetwork(network string) bool {
+	switch network {
+	case "sctp", "sctp4", "sctp6":
+		return true
+	}
+	return false
+}
+
+// containsAddress reports whether addr is one of the addresses
+func containsAddress(addresses []string, addr string) bool {
+	for _, a := range addresses {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// namedAddress returns the address with the given name,
+// or key itself when it is not the name of an address
+func namedAddress(names map[string]string, key string) string {
+	if addr, ok := names[key]; ok {
+		return addr
+	}
+	return key
+}
+
+// isPacketNetwork reports whether the network
+// is served with a net.PacketConn
+func isPacketNetwork(network string) bool {
+	switch network {
+	case "udp", "udp4", "udp6":
+		return true
+	}
+	return false
+}
+
+// IPStack controls whether IPv6 sockets also accept IPv4, see ListenOptions
+type IPStack int
+
+const (
+	//StackDefault leaves Go's behaviour, in which "tcp://" and "udp://"
+	//addresses with an unspecified host (":8080", "[::]:8080") are bound
+	//dual-stack, while "tcp6://" and "udp6://" addresses are IPv6 only
+	StackDefault IPStack = iota
+	//StackDualStack clears IPV6_V6ONLY, IPv6 sockets also accept IPv4
+	StackDualStack
+	//StackIPv6Only sets IPV6_V6ONLY, IPv6 sockets only accept IPv6
+	StackIPv6Only
+)
+
+// ListenOptions configures the socket of an address, see Config.ListenOptions
+type ListenOptions struct {
+	//KeepAlive is the keep-alive period of accepted TCP connections.
+	//Defaults to 3 minutes, a negative duration disables keep-alives.
+	KeepAlive time.Duration
+	//Delay disables TCP_NODELAY on accepted TCP connections,
+	//allowing the kernel to coalesce small writes (Nagle's algorithm).
+	Delay bool
+	//Backlog is the maximum length of the queue of pending connections.
+	//Defaults to the system maximum.
+	Backlog int
+	//NoReuseAddr clears SO_REUSEADDR, which is otherwise set on
+	//listening sockets.
+	NoReuseAddr bool
+	//Stack controls IPV6_V6ONLY of IPv6 sockets, independently
+	//of the net.ipv6.bindv6only sysctl. Use "tcp4://" or "tcp6://"
+	//to choose the IP version of the socket itself.
+	Stack IPStack
+	//BindDevice restricts the socket to a network interface
+	//(SO_BINDTODEVICE, e.g. "eth0"). Linux only.
+	BindDevice string
+	//ProxyProtocol expects each accepted connection to begin with a
+	//PROXY protocol (v1 or v2) header, as sent by HAProxy or an AWS
+	//NLB, and reports the client address of the header as the
+	//connection's RemoteAddr. Connections without a header fail.
+	ProxyProtocol bool
+	//Control is optionally called after the above options are
+	//applied and before the socket is bound, see net.ListenConfig.
+	//It allows socket options without a field above to be set,
+	//such as TCP_FASTOPEN or IP_FREEBIND.
+	Control func(network, address string, c syscall.RawConn) error
+}
+
+// Listen returns the listener passed by overseer for the address,
+// when it is one of the program's Addresses (e.g. Listen("tcp", ":8080")
+// for ":8080" or "tcp://:8080"), otherwise the address is bound with
+// net.Listen. The same program may then run with or without overseer.
+func Listen(network, address string) (net.Listener, error) {
+	if currentProcess != nil {
+		if l := currentProcess.listener(network, address); l != nil {
+			return l, nil
+		}
+	}
+	return net.Listen(network, address)
+}
+
+func (mp *master) listener(network, address string) net.Listener {
+	return nil
+}
+
+func (sp *slave) listener(network, address string) net.Listener {
+	for i, addr := range sp.state.Addresses {
+		if n, a := parseAddress(addr); n == network && a == address && i < len(sp.state.Listeners) {
+			return sp.state.Listeners[i]
+		}
+	}
+	return nil
+}
+
+// listenConfig creates the net.ListenConfig applying the options,
+// and SO_REUSEPORT when sockets are bound by each program
+func listenConfig(o *ListenOptions, reusePort bool) *net.ListenConfig {
+	if o == nil && !reusePort {
+		return &net.ListenConfig{}
+	}
+	if o == nil {
+		o = &ListenOptions{}
+	}
+	return &net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) {
+				if reusePort {
+					if err = setReusePort(fd); err != nil {
+						err = fmt.Errorf("failed to set SO_REUSEPORT (%w)", err)
+						return
+					}
+				}
+				if o.NoReuseAddr {
+					if err = setReuseAddr(fd, false); err != nil {
+						err = fmt.Errorf("failed to clear SO_REUSEADDR (%w)", err)
+						return
+					}
+				}
+				if o.Stack != StackDefault && strings.HasSuffix(network, "6") {
+					if err = setV6Only(fd, o.Stack == StackIPv6Only); err != nil {
+						err = fmt.Errorf("failed to set IPV6_V6ONLY (%w)", err)
+						return
+					}
+				}
+				if o.BindDevice != "" {
+					if err = bindToDevice(fd, o.BindDevice); err != nil {
+						err = fmt.Errorf("failed to bind to device %s (%w)", o.BindDevice, err)
+					}
+				}
+			}); cerr != nil {
+				return cerr
+			}
+			if err != nil {
+				return err
+			}
+			if o.Control != nil {
+				return o.Control(network, address, c)
+			}
+			return nil
+		},
+	}
+}
+
+// listenStream binds a stream address with the options
+func listenStream(o *ListenOptions, reusePort bool, network, address string) (net.Listener, error) {
+	l, err := listenConfig(o, reusePort).Listen(context.Background(), network, address)
+	if err != nil {
+		return nil, err
+	}
+	if o != nil && o.Backlog > 0 {
+		if err := setListenerBacklog(l, o.Backlog); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// setListenerBacklog updates the backlog of the listener
+func setListenerBacklog(l net.Listener, n int) error {
+	sc, ok := l.(syscall.Conn)
+	if !ok {
+		return errors.New("failed to set backlog (not a socket)")
+	}
+	c, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var serr error
+	if err := c.Control(func(fd uintptr) {
+		serr = setBacklog(fd, n)
+	}); err != nil {
+		return err
+	}
+	if serr != nil {
+		return fmt.Errorf("failed to set backlog (%s)", serr)
+	}
+	return nil
+}
+
+// listen binds the address, returning the file of the socket
+func (mp *master) listen(addr string) (*os.File, error) {
+	network, address := parseAddress(addr)
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+		if _, err := net.ResolveTCPAddr(network, address); err != nil {
+			return nil, fmt.Errorf("Invalid address %s (%w)", addr, err)
+		}
+		l, err := listenStream(mp.Config.ListenOptions[addr], false, network, address)
+		if err != nil {
+			return nil, err
+		}
+		defer l.Close()
+		return l.(*net.TCPListener).File()
+	case "udp", "udp4", "udp6":
+		if _, err := net.ResolveUDPAddr(network, address); err != nil {
+			return nil, fmt.Errorf("Invalid address %s (%w)", addr, err)
+		}
+		c, err := listenConfig(mp.Config.ListenOptions[addr], false).ListenPacket(context.Background(), network, address)
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		return c.(*net.UDPConn).File()
+	case "sctp", "sctp4", "sctp6":
+		backlog := 0
+		if o := mp.Config.ListenOptions[addr]; o != nil {
+			backlog = o.Backlog
+		}
+		return listenSCTP(network, address, backlog)
+	case "unix":
+		if isAbstract(address) {
+			//abstract sockets have no file to clean up
+			l, err := listenStream(mp.Config.ListenOptions[addr], false, network, address)
+			if err != nil {
+				return nil, err
+			}
+			defer l.Close()
+			return l.(*net.UnixListener).File()
+		}
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+		l, err := listenStream(mp.Config.ListenOptions[addr], false, network, address)
+		if err != nil {
+			return nil, err
+		}
+		ul := l.(*net.UnixListener)
+		//the socket file outlives this listener
+		ul.SetUnlinkOnClose(false)
+		defer ul.Close()
+		mp.socketPaths = append(mp.socketPaths, address)
+		if err := os.Chmod(address, mp.Config.SocketMode); err != nil {
+			return nil, err
+		}
+		return ul.File()
+	}
+	return nil, fmt.Errorf("Invalid address %s (unsupported network %s)", addr, network)
+}
+
+// isAbstract reports whether the unix socket
+// address is in the linux abstract namespace
+func isAbstract(address string) bool {
+	return strings.HasPrefix(address, "@")
+}
+
+// removeStaleSocket removes the socket file left by a previous
+// master, sockets which are still being served are left as is
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if err != nil || info.Mode()&os.ModeSocket == 0 {
+		return nil //let listen report conflicts
+	}
+	if c, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		c.Close()
+		return fmt.Errorf("%s is in use", path)
+	}
+	return os.Remove(path)
+}
+
+// removeSockets removes the socket files created by the master
+func (mp *master) removeSockets() {
+	for _, path := range mp.socketPaths {
+		os.Remove(path)
+	}
+}
+
+func newOverseerPacketConn(c net.PacketConn) *overseerPacketConn {
+	return &overseerPacketConn{PacketConn: c, released: make(chan bool)}
+}
+
+// overseerPacketConn stops reading packets once released, leaving
+// them queued on the shared socket for the next program, while
+// replies to packets already read may still be written
+type overseerPacketConn struct {
+	net.PacketConn
+	released chan bool
+}
+
+func (c *overseerPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	if c.isReleased() {
+		return 0, nil, errReleased
+	}
+	n, addr, err := c.PacketConn.ReadFrom(p)
+	if err != nil && c.isReleased() {
+		return 0, nil, errReleased
+	}
+	return n, addr, err
+}
+
+// release interrupts any pending reads
+func (c *overseerPacketConn) release() {
+	close(c.released)
+	c.PacketConn.SetReadDeadline(time.Now())
+}
+
+// isReleased reports whether the connection has been released
+func (c *overseerPacketConn) isReleased() bool {
+	select {
+	case <-c.released:
+		return true
+	default:
+		return false
+	}
+}
+
+// the methods below allow a *net.UDPConn to be used with optimizations
+// such as ECN and GSO, by QUIC implementations like quic-go
+
+func (c *overseerPacketConn) udpConn() (*net.UDPConn, error) {
+	if u, ok := c.PacketConn.(*net.UDPConn); ok {
+		return u, nil
+	}
+	return nil, errors.New("not a UDP connection")
+}
+
+func (c *overseerPacketConn) ReadMsgUDP(b, oob []byte) (n, oobn, flags int, addr *net.UDPAddr, err error) {
+	u, err := c.udpConn()
+	if err != nil {
+		return 0, 0, 0, nil, err
+	}
+	if c.isReleased() {
+		return 0, 0, 0, nil, errReleased
+	}
+	n, oobn, flags, addr, err = u.ReadMsgUDP(b, oob)
+	if err != nil && c.isReleased() {
+		return 0, 0, 0, nil, errReleased
+	}
+	return n, oobn, flags, addr, err
+}
+
+func (c *overseerPacketConn) WriteMsgUDP(b, oob []byte, addr *net.UDPAddr) (n, oobn int, err error) {
+	u, err := c.udpConn()
+	if err != nil {
+		return 0, 0, err
+	}
+	return u.WriteMsgUDP(b, oob, addr)
+}
+
+func (c *overseerPacketConn) SetReadBuffer(bytes int) error {
+	u, err := c.udpConn()
+	if err != nil {
+		return err
+	}
+	return u.SetReadBuffer(bytes)
+}
+
+func (c *overseerPacketConn) SetWriteBuffer(bytes int) error {
+	u, err := c.udpConn()
+	if err != nil {
+		return err
+	}
+	return u.SetWriteBuffer(bytes)
+}
+
+// SyscallConn returns the raw socket, whose reads
+// also stop once the connection is released
+func (c *overseerPacketConn) SyscallConn() (syscall.RawConn, error) {
+	u, err := c.udpConn()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := u.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	return &releasedRawConn{RawConn: rc, c: c}, nil
+}
+
+type releasedRawConn struct {
+	syscall.RawConn
+	c *overseerPacketConn
+}
+
+func (rc *releasedRawConn) Read(f func(fd uintptr) bool) error {
+	if rc.c.isReleased() {
+		return errReleased
+	}
+	err := rc.RawConn.Read(f)
+	if err != nil && rc.c.isReleased() {
+		return errReleased
+	}
+	return err
+}
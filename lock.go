@@ -0,0 +1,69 @@
+package overseer
+
+import "sync"
+
+// Locker limits the number of instances which may upgrade at
+// the same time. Implementations will typically wrap a distributed
+// lock or semaphore (etcd, Consul, Redis) shared by all instances
+// with the same key. Lock should block until this instance is
+// permitted to upgrade, and return a function which releases the
+// lock once the upgrade has completed.
+type Locker interface {
+	Lock(key string) (unlock func(), err error)
+}
+
+// LockerFunc converts a lock function into the Locker interface
+type LockerFunc func(key string) (unlock func(), err error)
+
+// Lock calls f(key)
+func (f LockerFunc) Lock(key string) (func(), error) {
+	return f(key)
+}
+
+// lockUpgrade acquires the upgrade lock, when configured
+func (mp *master) lockUpgrade() (func(), error) {
+	if mp.Config.UpgradeLock == nil {
+		return func() {}, nil
+	}
+	mp.debugf("acquiring upgrade lock (%s)", mp.Config.UpgradeLockKey)
+	unlock, err := mp.Config.UpgradeLock.Lock(mp.Config.UpgradeLockKey)
+	if err != nil {
+		return nil, err
+	}
+	mp.debugf("acquired upgrade lock")
+	once := sync.Once{}
+	return func() {
+		once.Do(func() {
+			if unlock != nil {
+				unlock()
+			}
+			mp.debugf("released upgrade lock")
+		})
+	}, nil
+}
+
+// holdUpgradeLock keeps the upgrade lock until the following
+// restart has completed, see restartLoop
+func (mp *master) holdUpgradeLock(unlock func()) {
+	mp.restartMux.Lock()
+	mp.upgradeUnlock = unlock
+	mp.restartMux.Unlock()
+}
+
+// takeUpgradeLock returns the upgrade lock held until the following
+// restart, if any, which the caller must then release
+func (mp *master) takeUpgradeLock() func() {
+	mp.restartMux.Lock()
+	defer mp.restartMux.Unlock()
+	unlock := mp.upgradeUnlock
+	mp.upgradeUnlock = nil
+	return unlock
+}
+
+// releaseUpgradeLock releases the upgrade lock held
+// until the following restart, which will not happen
+func (mp *master) releaseUpgradeLock() {
+	if unlock := mp.takeUpgradeLock(); unlock != nil {
+		unlock()
+	}
+}
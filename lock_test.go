@@ -0,0 +1,31 @@
+package overseer
+
+import "testing"
+
+func TestUpgradeLockHeld(t *testing.T) {
+	locked, unlocked := 0, 0
+	mp := &master{Config: &Config{UpgradeLock: LockerFunc(func(key string) (func(), error) {
+		locked++
+		return func() { unlocked++ }, nil
+	})}}
+	unlock, err := mp.lockUpgrade()
+	if err != nil {
+		t.Fatal(err)
+	}
+	//held for the deferred restart
+	mp.holdUpgradeLock(unlock)
+	if unlocked != 0 {
+		t.Fatal("released before the restart")
+	}
+	//the next upgrade takes it over, rather than waiting for itself
+	if mp.takeUpgradeLock() == nil {
+		t.Fatal("the held lock was not taken over")
+	}
+	mp.holdUpgradeLock(unlock)
+	mp.releaseUpgradeLock()
+	mp.releaseUpgradeLock()
+	unlock()
+	if locked != 1 || unlocked != 1 {
+		t.Errorf("locked %d and unlocked %d times, expected once", locked, unlocked)
+	}
+}
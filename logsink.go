@@ -0,0 +1,214 @@
+package overseer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LogLevel is the priority of an overseer log
+type LogLevel int
+
+const (
+	//LogTrace logs detail the control protocol between
+	//the master and the programs, see Config.LogLevel
+	LogTrace LogLevel = iota - 1
+	//LogDebug logs explain the steps taken by overseer
+	LogDebug
+	//LogInfo logs record lifecycle events, with Config.JSONLogs
+	LogInfo
+	//LogWarn logs report failures overseer recovers from
+	LogWarn
+	//LogError logs precede overseer failing to run
+	LogError
+)
+
+func (l LogLevel) String() string {
+	switch l {
+	case LogTrace:
+		return "trace"
+	case LogDebug:
+		return "debug"
+	case LogInfo:
+		return "info"
+	case LogWarn:
+		return "warn"
+	case LogError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// ParseLogLevel parses the name of a level, as returned by String
+func ParseLogLevel(s string) (LogLevel, error) {
+	for l := LogTrace; l <= LogError; l++ {
+		if s == l.String() {
+			return l, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+func validateLogLevels(level string, levels map[string]string) error {
+	if level != "" {
+		if _, err := ParseLogLevel(level); err != nil {
+			return fmt.Errorf("overseer.Config.LogLevel: %w", err)
+		}
+	}
+	for subsystem, l := range levels {
+		switch subsystem {
+		case "master", "slave", "fetcher":
+		default:
+			return fmt.Errorf("overseer.Config.LogLevels: unknown subsystem %q", subsystem)
+		}
+		if _, err := ParseLogLevel(l); err != nil && l != "" {
+			return fmt.Errorf("overseer.Config.LogLevels %s: %w", subsystem, err)
+		}
+	}
+	return nil
+}
+
+// logLevel is the least priority logged by the subsystem
+func (c *Config) logLevel(subsystem string) LogLevel {
+	if l, err := ParseLogLevel(c.LogLevels[subsystem]); err == nil {
+		return l
+	}
+	if l, err := ParseLogLevel(c.LogLevel); err == nil {
+		return l
+	}
+	//deprecated
+	if c.Debug {
+		return LogDebug
+	}
+	if c.NoWarn {
+		return LogError
+	}
+	return LogInfo
+}
+
+// logs reports whether the subsystem logs at the level
+func (c *Config) logs(subsystem string, level LogLevel) bool {
+	return level >= c.logLevel(subsystem)
+}
+
+// LogSink receives overseer's logs, see Config.LogSink
+type LogSink interface {
+	Log(level LogLevel, msg string)
+}
+
+// logRecord is a log formatted with Config.JSONLogs
+type logRecord struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Process string    `json:"process,omitempty"`
+	Msg     string    `json:"msg,omitempty"`
+	*Event
+}
+
+// logf sends the log of the process (master or slave#<id>)
+// to Config.LogSink, or the standard logger
+func (c *Config) logf(level LogLevel, process, f string, args ...interface{}) {
+	msg := fmt.Sprintf(f, args...)
+	if c.JSONLogs {
+		c.logJSON(logRecord{Time: time.Now(), Level: level.String(), Process: process, Msg: msg}, level)
+		return
+	}
+	prefix := "[overseer] "
+	if process != "" {
+		prefix = "[overseer " + process + "] "
+	}
+	if c.LogSink == nil {
+		log.Print(prefix + msg)
+		return
+	}
+	c.LogSink.Log(level, prefix+msg)
+}
+
+// logEvent logs the lifecycle event, with Config.JSONLogs
+func (mp *master) logEvent(e Event) {
+	if !mp.Config.JSONLogs {
+		return
+	}
+	level := LogInfo
+	if e.Error != "" {
+		level = LogWarn
+	}
+	if !mp.logs("master", level) {
+		return
+	}
+	mp.Config.logJSON(logRecord{Time: e.Time, Level: level.String(), Process: "master", Event: &e}, level)
+}
+
+func (c *Config) logJSON(r logRecord, level LogLevel) {
+	b, _ := json.Marshal(r)
+	if c.LogSink == nil {
+		//one write per line, without the logger's prefix
+		log.Writer().Write(append(b, '\n'))
+		return
+	}
+	c.LogSink.Log(level, string(b))
+}
+
+// journalSocket is where systemd-journald receives native protocol entries
+const journalSocket = "/run/systemd/journal/socket"
+
+// journalSink writes entries to the systemd journal
+type journalSink struct {
+	conn       net.Conn
+	identifier string
+}
+
+// JournalSink returns a LogSink writing to the systemd journal, with
+// the priority of each log. Entries are identified by the program's
+// name unless an identifier is given.
+func JournalSink(identifier string) (LogSink, error) {
+	if identifier == "" {
+		identifier = filepath.Base(os.Args[0])
+	}
+	conn, err := net.Dial("unixgram", journalSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald (%w)", err)
+	}
+	return &journalSink{conn: conn, identifier: identifier}, nil
+}
+
+func (j *journalSink) Log(level LogLevel, msg string) {
+	b := &bytes.Buffer{}
+	journalField(b, "PRIORITY", syslogPriority(level))
+	journalField(b, "SYSLOG_IDENTIFIER", j.identifier)
+	journalField(b, "MESSAGE", msg)
+	j.conn.Write(b.Bytes())
+}
+
+// journalField appends a field of the native journal protocol,
+// values with newlines are written with their length instead
+func journalField(b *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+// syslogPriority is the syslog(3) priority of the level
+func syslogPriority(level LogLevel) string {
+	switch level {
+	case LogError:
+		return "3"
+	case LogWarn:
+		return "4"
+	case LogInfo:
+		return "6"
+	default:
+		return "7"
+	}
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+// +build !windows
+
+package overseer
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// syslogSink writes logs to the system logger
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// SyslogSink returns a LogSink writing to the local system logger,
+// with the daemon facility and the priority of each log. Logs are
+// tagged with the program's name unless a tag is given.
+func SyslogSink(tag string) (LogSink, error) {
+	w, err := syslog.New(syslog.LOG_DAEMON|syslog.LOG_INFO, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog (%w)", err)
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Log(level LogLevel, msg string) {
+	switch level {
+	case LogError:
+		s.w.Err(msg)
+	case LogWarn:
+		s.w.Warning(msg)
+	case LogInfo:
+		s.w.Info(msg)
+	default:
+		s.w.Debug(msg)
+	}
+}
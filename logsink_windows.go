@@ -0,0 +1,8 @@
+package overseer
+
+import "errors"
+
+// SyslogSink is not supported on windows
+func SyslogSink(tag string) (LogSink, error) {
+	return nil, errors.New("syslog is not supported on windows")
+}
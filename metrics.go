@@ -0,0 +1,249 @@
+package overseer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// metricsPath is where MetricsAddress serves the metrics
+const metricsPath = "/metrics"
+
+// metrics are counted by the master, see Config.MetricsAddress
+type metrics struct {
+	mux             sync.Mutex
+	fetchAttempts   int64
+	fetchFailures   int64
+	fetchBytes      int64
+	upgrades        int64
+	upgradeFailures int64
+	restarts        int64
+	rollbacks       int64
+	lastFetch       time.Time
+	lastFetchError  string
+	lastUpgrade     time.Time
+	lastError       string
+	programStarted  time.Time
+	//restartTime, handover and drainTime time the restarts, see Event.Handover
+	restartTime timing
+	handover    timing
+	drainTime   timing
+	//retries counts the fetches failed since the last success
+	retries int
+	//progress is the download in progress, if any
+	progress *FetchProgress
+	//usage are the last samples of the programs, see Config.UsageInterval
+	usage []Usage
+}
+
+// fetch counts a fetch attempt and its failure
+func (m *metrics) fetch(err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.fetchAttempts++
+	m.lastFetch = time.Now()
+	m.lastFetchError = ""
+	if err != nil {
+		m.fetchFailures++
+		m.lastFetchError = err.Error()
+		m.retries++
+	} else {
+		m.retries = 0
+	}
+}
+
+// fetchRetries returns the number of fetches failed since the last success
+func (m *metrics) fetchRetries() int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.retries
+}
+
+// fetching records the progress of the current download
+func (m *metrics) fetching(p FetchProgress) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.progress = &p
+	if p.Done {
+		m.progress = nil
+	}
+}
+
+// countersEnv passes the start time of the master, and the restarts
+// and upgrades it performed, to the program, see State.Restarts
+func (mp *master) countersEnv(replica int) []string {
+	m := &mp.metricCounts
+	m.mux.Lock()
+	restarts, upgrades := m.restarts, m.upgrades
+	m.mux.Unlock()
+	if replica == 0 && mp.isReplacing() {
+		//the restart is counted once it completes
+		restarts++
+	}
+	return []string{
+		envMasterStarted + "=" + mp.startedAt.Format(time.RFC3339Nano),
+		envRestarts + "=" + strconv.FormatInt(restarts, 10),
+		envUpgrades + "=" + strconv.FormatInt(upgrades, 10),
+	}
+}
+
+// event counts the outcome of each step of the upgrade lifecycle
+func (m *metrics) event(e Event) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if e.Error != "" {
+		m.lastError = e.Error
+	}
+	switch {
+	case e.Type == EventFetch && e.Error == "":
+		m.fetchBytes += e.Size
+	case e.Type == EventUpgrade && e.Error == "":
+		m.upgrades++
+		m.lastUpgrade = e.Time
+	case e.Type == EventFailure:
+		m.upgradeFailures++
+	case e.Type == EventRestart:
+		m.restarts++
+		m.restartTime.observe(e.Duration)
+		if e.Handover > 0 {
+			m.handover.observe(e.Handover)
+		}
+	case e.Type == EventDrain:
+		m.drainTime.observe(e.Duration)
+	case e.Type == EventRollback:
+		m.rollbacks++
+	}
+}
+
+// timing summarises the durations of a step
+type timing struct {
+	count int64
+	sum   time.Duration
+	max   time.Duration
+}
+
+func (t *timing) observe(d time.Duration) {
+	t.count++
+	t.sum += d
+	if d > t.max {
+		t.max = d
+	}
+}
+
+// started records the start of the program
+func (m *metrics) started() {
+	m.mux.Lock()
+	m.programStarted = time.Now()
+	m.mux.Unlock()
+}
+
+// sampled replaces the usage samples of the programs
+func (m *metrics) sampled(usage []Usage) {
+	m.mux.Lock()
+	m.usage = usage
+	m.mux.Unlock()
+}
+
+// serveMetrics serves the metrics on MetricsAddress
+func (mp *master) serveMetrics() error {
+	if mp.Config.MetricsAddress == "" {
+		return nil
+	}
+	l, err := net.Listen("tcp", mp.Config.MetricsAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on metrics address (%w)", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle(metricsPath, MetricsHandler())
+	mp.serving(l)
+	go http.Serve(l, mux)
+	mp.debugf("serving metrics on %s", l.Addr())
+	return nil
+}
+
+// metrics renders the metrics in the Prometheus text format
+func (mp *master) metrics() ([]byte, error) {
+	hash, version := mp.binary()
+	m := &mp.metricCounts
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	b := &bytes.Buffer{}
+	metric := func(name, kind, help string, value interface{}) {
+		fmt.Fprintf(b, "# HELP overseer_%s %s\n# TYPE overseer_%s %s\noverseer_%s %v\n", name, help, name, kind, name, value)
+	}
+	metric("fetch_attempts_total", "counter", "Checks for updates.", m.fetchAttempts)
+	metric("fetch_failures_total", "counter", "Checks for updates which failed.", m.fetchFailures)
+	metric("fetch_bytes_total", "counter", "Bytes of binaries downloaded.", m.fetchBytes)
+	metric("upgrades_total", "counter", "Upgraded binaries installed.", m.upgrades)
+	metric("upgrade_failures_total", "counter", "Upgraded programs which failed during UpgradeProbation.", m.upgradeFailures)
+	metric("restarts_total", "counter", "Restarts of the program.", m.restarts)
+	metric("rollbacks_total", "counter", "Upgrades rolled back.", m.rollbacks)
+	summary := func(name, help string, t timing) {
+		fmt.Fprintf(b, "# HELP overseer_%s %s\n# TYPE overseer_%s summary\n", name, help, name)
+		fmt.Fprintf(b, "overseer_%s_sum %v\noverseer_%s_count %d\n", name, t.sum.Seconds(), name, t.count)
+		metric(name+"_max", "gauge", "Longest of "+name+".", t.max.Seconds())
+	}
+	summary("restart_duration_seconds", "Time taken by the restarts of the program.", m.restartTime)
+	summary("restart_handover_seconds", "Time connections were not accepted during the restarts, from the release of the sockets until the next program was ready.", m.handover)
+	summary("drain_duration_seconds", "Time taken by the replaced programs to exit.", m.drainTime)
+	if !m.lastUpgrade.IsZero() {
+		metric("last_upgrade_timestamp_seconds", "gauge", "Time of the last upgrade.", m.lastUpgrade.Unix())
+	}
+	if p := m.progress; p != nil {
+		metric("fetch_progress_bytes", "gauge", "Bytes of the binary being downloaded read so far.", p.Bytes)
+	}
+	if !m.programStarted.IsZero() {
+		metric("program_uptime_seconds", "gauge", "Time since the program was started.", time.Since(m.programStarted).Seconds())
+	}
+	fmt.Fprintf(b, "# HELP overseer_build_info The binary being run.\n# TYPE overseer_build_info gauge\n")
+	fmt.Fprintf(b, "overseer_build_info{version=%q,hash=%q} 1\n", version, hash)
+	if len(m.usage) > 0 {
+		usage := func(name, kind, help string, value func(u Usage) interface{}) {
+			fmt.Fprintf(b, "# HELP overseer_%s %s\n# TYPE overseer_%s %s\n", name, help, name, kind)
+			for _, u := range m.usage {
+				fmt.Fprintf(b, "overseer_%s{slave_id=\"%d\"} %v\n", name, u.SlaveID, value(u))
+			}
+		}
+		usage("program_resident_memory_bytes", "gauge", "Resident memory of the program.", func(u Usage) interface{} { return u.RSS })
+		usage("program_cpu_seconds_total", "counter", "CPU time of the program.", func(u Usage) interface{} { return u.CPUTime.Seconds() })
+		usage("program_open_fds", "gauge", "Open files of the program.", func(u Usage) interface{} { return u.OpenFiles })
+	}
+	return b.Bytes(), nil
+}
+
+func (sp *slave) metrics() ([]byte, error) {
+	resp, err := sp.request(listenerRequest{Op: "metrics"})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Metrics, nil
+}
+
+// MetricsHandler serves the metrics of the master in the Prometheus
+// text format, such as the fetches, upgrades and restarts performed,
+// the uptime of the program and the version being run. It may be
+// mounted by the program on its own server, the metrics are then
+// fetched from the master, see also Config.MetricsAddress.
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentProcess == nil {
+			http.Error(w, ErrNotRunning.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		b, err := currentProcess.metrics()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(b)
+	})
+}
@@ -0,0 +1,115 @@
+package overseer
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	metricComment = regexp.MustCompile(`^# (HELP|TYPE) (overseer_[a-z_]+) (.+)$`)
+	metricSample  = regexp.MustCompile(`^(overseer_[a-z_]+)(\{[a-z_]+="[^"]*"(,[a-z_]+="[^"]*")*\})? (\S+)$`)
+)
+
+// parseMetrics checks the Prometheus text format of the metrics,
+// returning the value of each sample, keyed by its name and labels
+func parseMetrics(t *testing.T, text string) map[string]float64 {
+	samples := map[string]float64{}
+	family, kind := "", ""
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if m := metricComment.FindStringSubmatch(line); m != nil {
+			if m[1] == "TYPE" {
+				switch m[3] {
+				case "counter", "gauge", "summary":
+				default:
+					t.Errorf("invalid type: %s", line)
+				}
+				family, kind = m[2], m[3]
+			}
+			continue
+		}
+		m := metricSample.FindStringSubmatch(line)
+		if m == nil {
+			t.Errorf("invalid line: %q", line)
+			continue
+		}
+		name := m[1]
+		if kind == "summary" {
+			name = strings.TrimSuffix(strings.TrimSuffix(name, "_sum"), "_count")
+		}
+		if name != family {
+			t.Errorf("sample %s is not of the family %s", m[1], family)
+		}
+		v, err := strconv.ParseFloat(m[4], 64)
+		if err != nil {
+			t.Errorf("invalid value: %q", line)
+		}
+		samples[m[1]+m[2]] = v
+	}
+	return samples
+}
+
+func TestMetrics(t *testing.T) {
+	mp := &master{binSHA256: []byte{0xab, 0xcd}, binVersion: "1.2.3"}
+	m := &mp.metricCounts
+	m.fetch(nil)
+	m.fetch(errors.New("failed"))
+	m.event(Event{Type: EventFetch, Size: 100})
+	m.event(Event{Type: EventUpgrade, Time: time.Unix(1500000000, 0)})
+	m.event(Event{Type: EventRestart, Duration: 1500 * time.Millisecond, Handover: 20 * time.Millisecond})
+	m.event(Event{Type: EventRestart, Duration: 500 * time.Millisecond})
+	m.event(Event{Type: EventRollback})
+	m.started()
+	m.sampled([]Usage{{SlaveID: 1, RSS: 1024, CPUTime: 2 * time.Second, OpenFiles: 7}, {SlaveID: 2, RSS: 2048}})
+	b, err := mp.metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := parseMetrics(t, string(b))
+	for name, want := range map[string]float64{
+		"overseer_fetch_attempts_total":                        2,
+		"overseer_fetch_failures_total":                        1,
+		"overseer_fetch_bytes_total":                           100,
+		"overseer_upgrades_total":                              1,
+		"overseer_restarts_total":                              2,
+		"overseer_rollbacks_total":                             1,
+		"overseer_restart_duration_seconds_sum":                2,
+		"overseer_restart_duration_seconds_count":              2,
+		"overseer_restart_duration_seconds_max":                1.5,
+		"overseer_restart_handover_seconds_count":              1,
+		"overseer_drain_duration_seconds_count":                0,
+		"overseer_last_upgrade_timestamp_seconds":              1500000000,
+		`overseer_build_info{version="1.2.3",hash="abcd"}`:     1,
+		`overseer_program_resident_memory_bytes{slave_id="1"}`: 1024,
+		`overseer_program_resident_memory_bytes{slave_id="2"}`: 2048,
+		`overseer_program_cpu_seconds_total{slave_id="1"}`:     2,
+		`overseer_program_open_fds{slave_id="1"}`:              7,
+	} {
+		if got, ok := samples[name]; !ok || got != want {
+			t.Errorf("%s: got %v, expected %v", name, got, want)
+		}
+	}
+	if _, ok := samples["overseer_program_uptime_seconds"]; !ok {
+		t.Error("missing the uptime of the program")
+	}
+	if _, ok := samples["overseer_fetch_progress_bytes"]; ok {
+		t.Error("reported the progress of a fetch while none is in progress")
+	}
+}
+
+func TestMetricsEmpty(t *testing.T) {
+	mp := &master{}
+	b, err := mp.metrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := parseMetrics(t, string(b))
+	for _, name := range []string{"overseer_last_upgrade_timestamp_seconds", "overseer_program_uptime_seconds", `overseer_program_open_fds{slave_id="0"}`} {
+		if _, ok := samples[name]; ok {
+			t.Errorf("reported %s before it is known", name)
+		}
+	}
+}
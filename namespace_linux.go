@@ -0,0 +1,9 @@
+package overseer
+
+import "syscall"
+
+// unshareMounts runs the slave in a new mount namespace,
+// in which mounts are not propagated back to the host
+func unshareMounts(attr *syscall.SysProcAttr) {
+	attr.Unshareflags |= syscall.CLONE_NEWNS
+}
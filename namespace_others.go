@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package overseer
+
+import "syscall"
+
+func unshareMounts(attr *syscall.SysProcAttr) {}
@@ -0,0 +1,166 @@
+package overseer
+
+import (
+	"github.com/menglh/overseer/fetcher"
+)
+
+// Option configures the Overseer returned by New, see the With functions
+type Option func(c *Config)
+
+// Overseer runs the program configured by the options given to New,
+// as an alternative to filling in a Config
+type Overseer struct {
+	config Config
+}
+
+// New configures overseer with the options, which are applied in
+// order, so that sets of options may be shared with WithOptions
+func New(opts ...Option) *Overseer {
+	o := &Overseer{}
+	WithOptions(opts...)(&o.config)
+	return o
+}
+
+// Config returns the Config built by the options
+func (o *Overseer) Config() Config {
+	return o.config
+}
+
+// Run executes overseer. Unlike Run, which falls back to running the
+// program directly, the error is returned, so that a misconfiguration
+// does not go unnoticed.
+func (o *Overseer) Run() error {
+	return RunErr(o.config)
+}
+
+// WithOptions applies the options in order, as a single option
+func WithOptions(opts ...Option) Option {
+	return func(c *Config) {
+		for _, opt := range opts {
+			opt(c)
+		}
+	}
+}
+
+// WithConfig replaces the Config built so far, so that
+// the options which follow may amend an existing Config
+func WithConfig(config Config) Option {
+	return func(c *Config) {
+		*c = config
+	}
+}
+
+// WithProgram sets Config.Program
+func WithProgram(program func(state State)) Option {
+	return func(c *Config) {
+		c.Program = program
+	}
+}
+
+// WithFetcher sets Config.Fetcher
+func WithFetcher(f fetcher.Interface) Option {
+	return func(c *Config) {
+		c.Fetcher = f
+	}
+}
+
+// WithAddresses adds to Config.Addresses
+func WithAddresses(addresses ...string) Option {
+	return func(c *Config) {
+		if c.Address != "" {
+			//Address is Addresses of one
+			c.Addresses = append(c.Addresses, c.Address)
+			c.Address = ""
+		}
+		c.Addresses = append(c.Addresses, addresses...)
+	}
+}
+
+// WithLogLevel sets Config.LogLevel
+func WithLogLevel(level LogLevel) Option {
+	return func(c *Config) {
+		c.LogLevel = level.String()
+	}
+}
+
+// Hooks are the callbacks of the Config, see WithHooks
+type Hooks struct {
+	PreUpgrade     func(tempBinaryPath string) error
+	PreUpgradeInfo func(u Upgrade) error
+	PostUpgrade    func(u Upgrade)
+	FetchError     func(err error)
+	FetchProgress  func(p FetchProgress)
+	DrainExpired   func(d Drain)
+	ProgramExited  func(e ProgramExit)
+	ProgramCrashed func(e ProgramExit)
+	CrashReporter  func(r CrashReport)
+	UsageSampled   func(u Usage)
+}
+
+// WithHooks adds the hooks which are set to those of the Config.
+// Each hook is called after those added before it, the first error
+// returned by a PreUpgrade or PreUpgradeInfo hook cancels the upgrade.
+func WithHooks(h Hooks) Option {
+	return func(c *Config) {
+		if prev, next := c.PreUpgrade, h.PreUpgrade; prev != nil && next != nil {
+			c.PreUpgrade = func(path string) error {
+				if err := prev(path); err != nil {
+					return err
+				}
+				return next(path)
+			}
+		} else if next != nil {
+			c.PreUpgrade = next
+		}
+		if prev, next := c.PreUpgradeInfo, h.PreUpgradeInfo; prev != nil && next != nil {
+			c.PreUpgradeInfo = func(u Upgrade) error {
+				if err := prev(u); err != nil {
+					return err
+				}
+				return next(u)
+			}
+		} else if next != nil {
+			c.PreUpgradeInfo = next
+		}
+		if prev, next := c.PostUpgrade, h.PostUpgrade; prev != nil && next != nil {
+			c.PostUpgrade = func(u Upgrade) { prev(u); next(u) }
+		} else if next != nil {
+			c.PostUpgrade = next
+		}
+		if prev, next := c.FetchError, h.FetchError; prev != nil && next != nil {
+			c.FetchError = func(err error) { prev(err); next(err) }
+		} else if next != nil {
+			c.FetchError = next
+		}
+		if prev, next := c.FetchProgress, h.FetchProgress; prev != nil && next != nil {
+			c.FetchProgress = func(p FetchProgress) { prev(p); next(p) }
+		} else if next != nil {
+			c.FetchProgress = next
+		}
+		if prev, next := c.DrainExpired, h.DrainExpired; prev != nil && next != nil {
+			c.DrainExpired = func(d Drain) { prev(d); next(d) }
+		} else if next != nil {
+			c.DrainExpired = next
+		}
+		if prev, next := c.ProgramExited, h.ProgramExited; prev != nil && next != nil {
+			c.ProgramExited = func(e ProgramExit) { prev(e); next(e) }
+		} else if next != nil {
+			c.ProgramExited = next
+		}
+		if prev, next := c.ProgramCrashed, h.ProgramCrashed; prev != nil && next != nil {
+			c.ProgramCrashed = func(e ProgramExit) { prev(e); next(e) }
+		} else if next != nil {
+			c.ProgramCrashed = next
+		}
+		if prev, next := c.CrashReporter, h.CrashReporter; prev != nil && next != nil {
+			c.CrashReporter = func(r CrashReport) { prev(r); next(r) }
+		} else if next != nil {
+			c.CrashReporter = next
+		}
+		if prev, next := c.UsageSampled, h.UsageSampled; prev != nil && next != nil {
+			c.UsageSampled = func(u Usage) { prev(u); next(u) }
+		} else if next != nil {
+			c.UsageSampled = next
+		}
+	}
+}
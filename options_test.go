@@ -0,0 +1,73 @@
+package overseer
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/menglh/overseer/fetcher"
+)
+
+func TestNew(t *testing.T) {
+	program := func(state State) {}
+	f := &fetcher.File{Path: "app"}
+	c := New(WithProgram(program), WithFetcher(f), WithLogLevel(LogWarn)).Config()
+	if c.Program == nil || c.Fetcher != f || c.LogLevel != "warn" {
+		t.Fatalf("options not applied: %+v", c)
+	}
+}
+
+func TestWithOptions(t *testing.T) {
+	defaults := WithOptions(WithAddresses(":3000"), WithLogLevel(LogDebug))
+	c := New(defaults, WithLogLevel(LogError)).Config()
+	if c.LogLevel != "error" {
+		t.Errorf("options not applied in order, got level %s", c.LogLevel)
+	}
+	if !equalStrings(c.Addresses, []string{":3000"}) {
+		t.Errorf("got addresses %q", c.Addresses)
+	}
+}
+
+func TestWithConfig(t *testing.T) {
+	c := New(WithAddresses(":1"), WithConfig(Config{Address: ":3000"}), WithAddresses(":3001")).Config()
+	if c.Address != "" || !equalStrings(c.Addresses, []string{":3000", ":3001"}) {
+		t.Errorf("got address %q and addresses %q", c.Address, c.Addresses)
+	}
+}
+
+func TestWithHooks(t *testing.T) {
+	calls := []string{}
+	hook := func(name string, err error) Hooks {
+		return Hooks{
+			PreUpgrade: func(string) error {
+				calls = append(calls, name)
+				return err
+			},
+			PostUpgrade: func(Upgrade) {
+				calls = append(calls, name)
+			},
+		}
+	}
+	c := New(WithHooks(hook("first", nil)), WithHooks(hook("second", nil))).Config()
+	if err := c.PreUpgrade("bin"); err != nil {
+		t.Fatal(err)
+	}
+	c.PostUpgrade(Upgrade{})
+	if want := []string{"first", "second", "first", "second"}; !equalStrings(calls, want) {
+		t.Errorf("hooks called as %q, expected %q", calls, want)
+	}
+	//the first error cancels the upgrade
+	calls = calls[:0]
+	failed := errors.New("failed")
+	c = New(WithHooks(hook("first", failed)), WithHooks(hook("second", nil))).Config()
+	if err := c.PreUpgrade("bin"); err != failed {
+		t.Errorf("got %v, expected the error of the first hook", err)
+	}
+	if want := []string{"first"}; !equalStrings(calls, want) {
+		t.Errorf("hooks called as %q, expected %q", calls, want)
+	}
+	//hooks not given are left alone
+	c = New(WithHooks(hook("first", nil)), WithHooks(Hooks{})).Config()
+	if c.PreUpgrade == nil || c.FetchError != nil {
+		t.Error("hooks not given were changed")
+	}
+}
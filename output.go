@@ -0,0 +1,243 @@
+package overseer
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StdinMode controls the program's stdin, see Config.Stdin
+type StdinMode int
+
+const (
+	//StdinInherit passes the master's stdin through to the program,
+	//as the programs overlap during a restart, both may read from it
+	StdinInherit StdinMode = iota
+	//StdinNull connects the program's stdin to the null device
+	StdinNull
+	//StdinClosed connects the program's stdin to a pipe which is
+	//already closed, so that reads return io.EOF immediately
+	StdinClosed
+)
+
+// RotatingFile is an io.Writer appending to the file at Path, which is
+// renamed with the time of its rotation (e.g. "app.log.20060102-150405.000")
+// once either limit is reached, for example to capture the program's
+// output with Config.Stdout. It is safe for concurrent use.
+type RotatingFile struct {
+	//Path of the file, created if missing
+	Path string
+	//MaxSize rotates the file before it exceeds this many bytes
+	MaxSize int64
+	//MaxAge rotates the file once it is this old
+	MaxAge time.Duration
+	//MaxBackups is how many rotated files are kept, all when zero
+	MaxBackups int
+	mux        sync.Mutex
+	file       *os.File
+	size       int64
+	openedAt   time.Time
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.file != nil && r.full(int64(len(p))) {
+		r.rotate()
+	}
+	if r.file == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Close closes the file, it is reopened by the next Write
+func (r *RotatingFile) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *RotatingFile) full(n int64) bool {
+	if r.MaxSize > 0 && r.size > 0 && r.size+n > r.MaxSize {
+		return true
+	}
+	return r.MaxAge > 0 && time.Since(r.openedAt) >= r.MaxAge
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	r.file = f
+	r.size = 0
+	r.openedAt = time.Now()
+	if info, err := f.Stat(); err == nil {
+		r.size = info.Size()
+		//the age of an existing file is unknown,
+		//its previous rotation is the best guess
+		if t := info.ModTime(); info.Size() > 0 && t.Before(r.openedAt) {
+			r.openedAt = t
+		}
+	}
+	return nil
+}
+
+// rotatedFormat is the time suffix of the rotated files
+const rotatedFormat = "20060102-150405.000"
+
+// rotate renames the file, and removes the oldest backups
+func (r *RotatingFile) rotate() {
+	r.file.Close()
+	r.file = nil
+	os.Rename(r.Path, r.Path+"."+time.Now().Format(rotatedFormat))
+	if r.MaxBackups <= 0 {
+		return
+	}
+	matches, _ := filepath.Glob(r.Path + ".*")
+	backups := []string{}
+	for _, m := range matches {
+		//only the files renamed by rotate
+		if _, err := time.Parse(rotatedFormat, strings.TrimPrefix(m, r.Path+".")); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	//named by time, oldest first
+	sort.Strings(backups)
+	for len(backups) > r.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+// lockedWriter serializes the writes of successive programs
+type lockedWriter struct {
+	mux sync.Mutex
+	w   io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.w.Write(p)
+}
+
+// setupOutput prepares the writers of the program's output,
+// the master's own stdout and stderr by default
+func (mp *master) setupOutput() {
+	mp.stdout = outputWriter(mp.Config.Stdout, os.Stdout)
+	mp.stderr = mp.stdout
+	if mp.Config.Stderr == nil || mp.Config.Stderr != mp.Config.Stdout {
+		mp.stderr = outputWriter(mp.Config.Stderr, os.Stderr)
+	}
+}
+
+// openStdin opens the stdin of the next program, see Config.Stdin
+func (mp *master) openStdin() (*os.File, error) {
+	switch mp.Config.Stdin {
+	case StdinNull:
+		return os.Open(os.DevNull)
+	case StdinClosed:
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		w.Close()
+		return r, nil
+	}
+	return os.Stdin, nil
+}
+
+// outputFile returns the file passed to the program to write to w.
+// Writers which are not files are passed a pipe copied to w by the
+// master, rather than by os/exec, whose copy would delay the program's
+// exit until any process it started, holding the pipe, also exited.
+// copied is closed once the copy ends, when every writer has closed
+// the pipe, and the pipe is closed by closeChild after the start.
+func outputFile(w io.Writer) (f *os.File, copied chan bool, err error) {
+	if f, ok := w.(*os.File); ok {
+		return f, nil, nil
+	}
+	r, f, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	copied = make(chan bool)
+	go func() {
+		defer close(copied)
+		defer r.Close()
+		b := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(b)
+			if n > 0 {
+				//keep reading if w fails, or the program would block
+				w.Write(b[:n])
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return f, copied, nil
+}
+
+// programOutput opens the stdout and stderr of the next program,
+// the returned func closes the master's copies of any pipes
+func (mp *master) programOutput(slaveID int) (stdout, stderr *os.File, closeChild func(), err error) {
+	var pipes []*os.File
+	closeChild = func() {
+		for _, f := range pipes {
+			f.Close()
+		}
+	}
+	stdout, copied, err := outputFile(mp.stdout)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if copied != nil {
+		pipes = append(pipes, stdout)
+	}
+	w, tail := mp.captureStderr(slaveID)
+	if w == mp.stdout {
+		//the same writer, and the same file
+		return stdout, stdout, closeChild, nil
+	}
+	stderr, copied, err = outputFile(w)
+	if err != nil {
+		closeChild()
+		return nil, nil, nil, err
+	}
+	if copied != nil {
+		pipes = append(pipes, stderr)
+	}
+	if tail != nil {
+		tail.copied = copied
+	}
+	return stdout, stderr, closeChild, nil
+}
+
+func outputWriter(w io.Writer, std *os.File) io.Writer {
+	switch w := w.(type) {
+	case nil:
+		return std
+	case *os.File, *RotatingFile:
+		return w
+	default:
+		//the previous program may still be writing
+		return &lockedWriter{w: w}
+	}
+}
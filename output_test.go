@@ -0,0 +1,128 @@
+package overseer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func tempLog(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "overseer-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "app.log"), func() { os.RemoveAll(dir) }
+}
+
+// backups returns the contents of the rotated files, oldest first
+func backups(t *testing.T, path string) []string {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(matches)
+	contents := []string{}
+	for _, m := range matches {
+		if _, err := time.Parse(rotatedFormat, m[len(path)+1:]); err != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(b))
+	}
+	return contents
+}
+
+func writeLog(t *testing.T, r *RotatingFile, s string) {
+	if _, err := r.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	//rotated files are named by the millisecond
+	time.Sleep(2 * time.Millisecond)
+}
+
+func readLog(t *testing.T, path string) string {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+	r := &RotatingFile{Path: path, MaxSize: 10}
+	defer r.Close()
+	writeLog(t, r, "first\n")
+	writeLog(t, r, "more\n")
+	writeLog(t, r, "longer than MaxSize\n")
+	writeLog(t, r, "last\n")
+	if got := readLog(t, path); got != "last\n" {
+		t.Errorf("got %q", got)
+	}
+	want := []string{"first\n", "more\n", "longer than MaxSize\n"}
+	if got := backups(t, path); !equalStrings(got, want) {
+		t.Errorf("got backups %q, expected %q", got, want)
+	}
+}
+
+func TestRotatingFileAppends(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+	if err := ioutil.WriteFile(path, []byte("existing\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := &RotatingFile{Path: path, MaxSize: 15}
+	defer r.Close()
+	writeLog(t, r, "new\n")
+	if got := readLog(t, path); got != "existing\nnew\n" {
+		t.Errorf("got %q", got)
+	}
+	//the existing content counts towards MaxSize
+	writeLog(t, r, "rotated\n")
+	if got := readLog(t, path); got != "rotated\n" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestRotatingFileAge(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+	r := &RotatingFile{Path: path, MaxAge: 20 * time.Millisecond}
+	defer r.Close()
+	writeLog(t, r, "old\n")
+	time.Sleep(30 * time.Millisecond)
+	writeLog(t, r, "new\n")
+	if got := readLog(t, path); got != "new\n" {
+		t.Errorf("got %q", got)
+	}
+	if got, want := backups(t, path), []string{"old\n"}; !equalStrings(got, want) {
+		t.Errorf("got backups %q, expected %q", got, want)
+	}
+}
+
+func TestRotatingFileMaxBackups(t *testing.T) {
+	path, cleanup := tempLog(t)
+	defer cleanup()
+	other := path + ".other"
+	if err := ioutil.WriteFile(other, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := &RotatingFile{Path: path, MaxSize: 1, MaxBackups: 2}
+	defer r.Close()
+	for _, s := range []string{"1", "2", "3", "4", "5"} {
+		writeLog(t, r, s)
+	}
+	if got, want := backups(t, path), []string{"3", "4"}; !equalStrings(got, want) {
+		t.Errorf("got backups %q, expected %q", got, want)
+	}
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("removed a file it did not rotate (%s)", err)
+	}
+}
@@ -3,13 +3,17 @@
 package overseer
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net"
 	"os"
 	"runtime"
+	"sort"
 	"time"
 
+	"github.com/menglh/overseer/coordinator"
 	"github.com/menglh/overseer/fetcher"
 )
 
@@ -21,6 +25,9 @@ const (
 	envBinPath        = "OVERSEER_BIN_PATH"
 	envBinCheck       = "OVERSEER_BIN_CHECK"
 	envBinCheckLegacy = "GO_UPGRADE_BIN_CHECK"
+	envMasterStarted  = "OVERSEER_MASTER_STARTED"
+	envRestarts       = "OVERSEER_RESTARTS"
+	envUpgrades       = "OVERSEER_UPGRADES"
 )
 
 // Config defines overseer's run-time configuration
@@ -32,27 +39,449 @@ type Config struct {
 	//程序的零停机套接字侦听地址（设置此地址或地址）
 	Address string
 	//程序的零停机套接字侦听地址（设置此地址或地址）
+	//Addresses may be prefixed with their network, "tcp://" is the
+	//default, "tcp4://" and "tcp6://" restrict the IP version and
+	//"udp://" (udp4, udp6) sockets are passed as State.PacketConns.
+	//SCTP sockets ("sctp://", "sctp4://" and "sctp6://") are passed as
+	//State.SCTPFiles, linux only.
+	//Unix sockets ("unix:///var/run/app.sock") are created by the master
+	//and removed when it exits. On linux, "unix://@name" addresses are
+	//bound in the abstract namespace instead, without a socket file.
+	//When started by systemd socket activation (LISTEN_FDS), the passed
+	//sockets are used for the addresses they match, by FileDescriptorName
+	//or by their bound address, instead of binding new sockets.
 	Addresses []string
+	//NamedAddresses are additional addresses with logical names (e.g.
+	//"admin": ":9090"), their sockets are retrieved by name with
+	//State.NamedListener and State.NamedPacketConn, so they do not
+	//depend on the order of Addresses. They are appended to Addresses
+	//in the order of their names.
+	NamedAddresses map[string]string
+	//Files are opened by the master and passed to the program as
+	//State.Files, by name. For example, a pre-opened log file or a
+	//raw socket which requires privileges the program lacks. Each
+	//function is only called once, in the master.
+	Files map[string]func() (*os.File, error)
+	//SocketMode is the file mode of unix sockets. Defaults to 0660.
+	SocketMode os.FileMode
+	//TLS optionally serves addresses over TLS, keyed by their entry in
+	//Addresses or their name in NamedAddresses. The program receives the listeners already wrapped by
+	//crypto/tls, and certificate files are reloaded when they change.
+	TLS map[string]*TLS
+	//ListenOptions optionally configures the sockets of addresses,
+	//keyed by their entry in Addresses or their name in NamedAddresses.
+	//See ListenOptions.
+	ListenOptions map[string]*ListenOptions
 	//RestartSignal 将手动触发正常重启。默认值为 SIGUSR2。
 	RestartSignal os.Signal
+	//FetchSignal will trigger an immediate fetch, outside of the regular
+	//fetch interval. Disabled by default. SIGUSR1 is reserved by overseer.
+	FetchSignal os.Signal
+	//StopSignal is sent to the program when the master is asked to stop
+	//(SIGTERM or SIGINT), for example SIGQUIT, for programs which only
+	//handle a particular signal. By default, the received signal is sent.
+	StopSignal os.Signal
+	//ReloadSignal calls Reload in the master, as does the Reload func.
+	//Disabled by default.
+	ReloadSignal os.Signal
+	//Reload returns the parts of the Config to change while the master
+	//is running, for example read from a config file.
+	Reload func() (Reloaded, error)
+	//UsageInterval is how often the master samples the resident memory,
+	//CPU time and open files of the programs, passing each sample to
+	//UsageSampled, for example to watch for regressions following an
+	//upgrade, see also State.Usage. Only supported on linux.
+	UsageInterval time.Duration
+	//UsageSampled is called with each sample, see UsageInterval
+	UsageSampled func(u Usage)
+	//Metadata is called by the master as it starts each program, the
+	//returned map is passed to the program in State.Metadata, for
+	//example the resolved release version or feature flags, rather
+	//than through environment variables.
+	Metadata func() map[string]string
+	//ForwardSignals, when set, are the only signals the master forwards
+	//to the program, such as SIGHUP for the program to reload its config,
+	//others are discarded. SIGTERM and SIGINT are always forwarded, to
+	//stop the program. RestartSignal, FetchSignal, ReloadSignal and
+	//SIGUSR1 are handled by the master, and can not be forwarded. By
+	//default, all other signals are forwarded.
+	ForwardSignals []os.Signal
+	//IgnoreSignals are discarded by the master, instead of forwarded.
+	IgnoreSignals []os.Signal
 	//TerminateTimeout 控制监督程序应等待程序自行终止的时间。在此超时之后，监督者将发出 SIGKILL。
 	TerminateTimeout time.Duration
+	//Escalation replaces the SIGKILL sent after TerminateTimeout, for
+	//programs with multi-phase shutdowns, with signals sent each After
+	//the program was asked to terminate and has not yet exited, for
+	//example SIGINT after 10s then os.Kill after 30s. The durations must
+	//increase and the last signal must be os.Kill, the program exits
+	//itself by then.
+	Escalation []Escalation
 	//MinFetchInterval 定义 Fetch（） 之间的最小持续时间。
 	//这有助于防止难以提取。占用太多资源的接口。默认值为 1 秒。
 	MinFetchInterval time.Duration
+	//MinRestartInterval is the minimum duration between the end of a
+	//restart and the start of the next, however they were triggered,
+	//so that a burst of fetches, signals and requests restarts the
+	//program once. Restarts triggered meanwhile are coalesced into one.
+	//Rollbacks are not held down. Disabled by default.
+	MinRestartInterval time.Duration
+	//HeartbeatTimeout kills the program when it has not sent a heartbeat
+	//to the master for this long, for example once it is stopped or its
+	//runtime has stalled, its exit is then handled as any other. The
+	//program sends a heartbeat every second. Disabled by default.
+	HeartbeatTimeout time.Duration
+	//DrainTimeout is how long the connections of the previous program
+	//may take to close after a restart, before they are closed by
+	//force. It must be shorter than the time the program has to exit,
+	//TerminateTimeout or the last step of Escalation, so that it has
+	//time to finish after its connections are closed, and defaults to
+	//nine tenths of it.
+	DrainTimeout time.Duration
+	//DrainExpired is called by the previous program when DrainTimeout
+	//passes, with the connections still open, just before they are
+	//closed by force.
+	DrainExpired func(d Drain)
 	//PreUpgrade 在检索到二进制文件后运行，可以在此处运行用户定义的检查，返回错误将取消升级。
 	PreUpgrade func(tempBinaryPath string) error
-	//Debug enables all [overseer] logs.
+	//PreUpgradeInfo runs alongside PreUpgrade and is passed what is
+	//known about the fetched binary, returning an error will cancel
+	//the upgrade.
+	PreUpgradeInfo func(u Upgrade) error
+	//PostUpgrade is optionally called once the fetched binary replaced
+	//the current binary, before the program is restarted, for example
+	//to announce the upgrade along with the Notes of the release.
+	PostUpgrade func(u Upgrade)
+	//FetchError is called whenever a fetch or upgrade fails. The cause may be
+	//inspected using errors.As, for example, a *DiskSpaceError is returned when
+	//the fetched binary will not fit in the staging directory, and a
+	//*SanityCheckError with its output when it fails the sanity check.
+	//Its cause may be told apart with errors.Is, see ErrFetchFailed.
+	FetchError func(err error)
+	//LogLevel is the least priority of the [overseer] logs: "error",
+	//"warn", "info", "debug" or "trace". Defaults to "info", which logs
+	//the warnings, and the lifecycle events with JSONLogs.
+	LogLevel string
+	//LogLevels optionally overrides LogLevel for the logs of the "master",
+	//the "slave" (the program) or the "fetcher", which checks for and
+	//downloads updates, for example {"fetcher": "debug"}.
+	LogLevels map[string]string
+	//Debug enables the debug [overseer] logs.
+	//
+	//Deprecated: use LogLevel "debug", it is used when LogLevel is empty.
 	Debug bool
-	//NoWarn disables warning [overseer] logs.
+	//NoWarn disables the warning [overseer] logs.
+	//
+	//Deprecated: use LogLevel "error", it is used when LogLevel is empty.
 	NoWarn bool
+	//LogSink receives [overseer] logs with their priority, instead of
+	//the standard logger, for example SyslogSink or JournalSink.
+	LogSink LogSink
+	//JSONLogs formats each [overseer] log as a JSON object, with the
+	//fields time, level, process and msg. Each lifecycle Event is also
+	//logged, with its fields, so that alerts may match on them.
+	JSONLogs bool
 	//NoRestart 禁用所有重启，此选项实质上是将 RestartSignal 转换为“ShutdownSignal”。
 	NoRestart bool
+	//SelfUpgrade re-executes the master process with the upgraded binary,
+	//instead of only restarting the program, so that fixes to overseer
+	//itself reach long-lived deployments. The sockets and the running
+	//program are handed over to the new master, which then restarts the
+	//program. Any UpgradeLock is released before re-executing. Not
+	//supported on windows or with InMemory.
+	SelfUpgrade bool
+	//ReusePort binds a socket for each address in every program with
+	//SO_REUSEPORT, instead of passing the master's sockets to them. On
+	//restart, the next program is started and binds its sockets before
+	//the previous program is asked to stop accepting. Only "tcp://" and
+	//"udp://" addresses are supported. Note, on linux, connections which
+	//are still queued on the previous program's socket are reset when
+	//it is closed. Not supported on windows or with SelfUpgrade.
+	ReusePort bool
+	//Replicas runs this many copies of the program, sharing the same
+	//sockets (or each binding its own with ReusePort), to make use of
+	//multi-core hosts. On restart, the first program is restarted, then
+	//the copies one at a time, each replaced before it is asked to stop,
+	//so that capacity never drops to zero. A copy which exits is started
+	//again, see State.Replica. Not supported on windows, or with
+	//SelfUpgrade, ConnectionHandoff or Terminal.
+	Replicas int
+	//Programs are other programs of this binary which the master
+	//supervises alongside Program, each with its own addresses, for a
+	//suite of related agents. They are upgraded with the binary, by the
+	//Fetcher, and restarted, one at a time, once Program has been, see
+	//Replicas, which they follow. As they are functions of the same
+	//binary, they share the Fetcher, a fetcher per program is not
+	//supported. Not supported on windows, or with SelfUpgrade,
+	//ConnectionHandoff or Terminal.
+	Programs []Program
+	//ConnectionHandoff allows the program to hand off established
+	//connections to the next program on restart, with State.HandOff,
+	//for protocols which can resume mid-stream, rather than waiting
+	//for long-lived connections to drain. The next program must
+	//receive them from State.HandedOff. Not supported on windows or
+	//with SelfUpgrade.
+	ConnectionHandoff bool
+	//User runs the program as this user, by name or uid, while the
+	//master keeps its privileges, so that it may bind privileged ports
+	//as root for a program which runs unprivileged. The program has the
+	//user's primary group and groups, unless Group or Groups are set.
+	//With ReusePort, the program must be able to bind its sockets
+	//itself. Not supported on windows.
+	User string
+	//Group runs the program with this group, by name or gid.
+	Group string
+	//Groups are the supplementary groups of the program, by name or gid.
+	Groups []string
+	//Subreaper makes the master the parent of the processes which the
+	//program leaves behind, such as helpers still running as it exits
+	//on a restart, so that the master reaps them once they exit rather
+	//than leaving zombies. Only supported on linux.
+	Subreaper bool
+	//Dir is the working directory of the program, within Chroot when
+	//set. By default, the program inherits the master's.
+	Dir string
+	//Args returns the command line of the program, given the master's
+	//os.Args, for example to append arguments. Note, args[0] is the
+	//program's os.Args[0]. By default, the program inherits the master's.
+	Args func(args []string) []string
+	//Chroot changes the root directory of the program, limiting the
+	//files it can reach, for programs processing untrusted input. The
+	//program's binary, and anything it loads, must be within Chroot,
+	//which excludes InMemory. Its working directory defaults to the new
+	//root. Requires the master to run as root, not supported on windows.
+	Chroot string
+	//MountNamespace runs the program in a private mount namespace, so
+	//that mounts made by or for the program are not visible to the host.
+	//Requires the master to run as root, linux only.
+	MountNamespace bool
+	//Daemonize detaches the master from the terminal it is started from
+	//(with setsid, twice forked), so that it may be started by a shell or
+	//cron without nohup. The starting process exits once the master has
+	//been started, use PIDFile to find it. Not supported on windows.
+	Daemonize bool
+	//DaemonLog is appended with the output of the daemon, including the
+	//program's unless Stdout or Stderr are set. Discarded by default.
+	DaemonLog string
+	//ProgramExited is called whenever the program exits, with its exit
+	//code. Note, the master exits with the program's exit code when it
+	//exits without being restarted (see NoRestart), or as it stops.
+	ProgramExited func(e ProgramExit)
+	//CoreDumps allows the program to dump core when it crashes, raising
+	//RLIMIT_CORE to its hard limit, and setting GOTRACEBACK=crash so that
+	//panics dump core, unless set otherwise. Crashes are passed to
+	//ProgramCrashed, along with the path of the core dump when known.
+	CoreDumps bool
+	//ProgramCrashed is called when the program is killed by a fault, or
+	//aborts, for example to keep the core dump of a bad release.
+	ProgramCrashed func(e ProgramExit)
+	//CrashReporter is optionally passed a CrashReport each time the
+	//program exits unexpectedly, such as with an error or killed by a
+	//signal, to send it on to Sentry or a similar service. It is called
+	//before the master exits, so that it may flush its reports. The
+	//end of the program's stderr is then captured for the report,
+	//which is passed through a pipe rather than inherited, so it is
+	//not supported with SelfUpgrade.
+	CrashReporter func(r CrashReport)
+	//PIDFile is written with the pid of the master, for init scripts
+	//and monitoring, and removed when it exits. The master fails to
+	//start while the pid of an existing PIDFile is still running.
+	PIDFile string
+	//ProgramPIDFile is written with the pid of the running program,
+	//as it changes on each restart.
+	ProgramPIDFile string
+	//Stdin controls the program's stdin, which is the master's by
+	//default (StdinInherit). Programs which are not to read from the
+	//terminal, or which read until EOF, may be given StdinNull or
+	//StdinClosed instead.
+	Stdin StdinMode
+	//Stdout and Stderr receive the program's output, instead of the
+	//master's stdout and stderr, for example a *RotatingFile. They may
+	//be the same writer. As programs overlap during a restart, other
+	//writers are locked by overseer, and passed to the program through
+	//pipes. Not supported with SelfUpgrade.
+	Stdout, Stderr io.Writer
+	//Terminal runs the program on a pseudo-terminal, in its own session,
+	//proxying the master's terminal to it, so that interactive programs
+	//may also be supervised. The master's terminal is put in raw mode,
+	//so keys such as CTRL+c are handled by the program. On a restart,
+	//input is passed to the new program. Only supported on linux and
+	//macOS, and not with Stdin, Stdout, Stderr, Daemonize or SelfUpgrade,
+	//as the master's end of the terminal is lost when it re-executes.
+	Terminal bool
+	//Environment controls which of the master's environment variables
+	//are passed to the program, and adds others, so that secrets meant
+	//for the master are not leaked to the program. By default, the
+	//program inherits the master's entire environment. Note, the
+	//program runs main and this Config again, so the variables they
+	//read must be passed.
+	Environment *Environment
+	//Limits restricts the resources of the program, so that a runaway
+	//upgrade may not take down the host. Not supported on windows.
+	Limits *Limits
+	//Launchd adapts the master to launchd on macOS. The sockets of the
+	//job's plist may be used for Addresses, see Launchd, and when
+	//launchd stops the job the master exits successfully, however the
+	//program exited, so that KeepAlive jobs are not respawned.
+	Launchd *Launchd
+	//WindowsService reports the master's status to the service control
+	//manager when it is started as a windows service (sc.exe create).
+	//Stop and shutdown requests terminate the program gracefully,
+	//"sc control <name> paramchange" and the control code 128 restart
+	//it and the control code 129 fetches immediately. Otherwise, the
+	//master runs as usual. Windows only.
+	WindowsService bool
 	//NoRestartAfterFetch disables automatic restarts after each upgrade.
 	//Though manual restarts using the RestartSignal can still be performed.
 	NoRestartAfterFetch bool
 	//Fetcher will be used to fetch binaries.
 	Fetcher fetcher.Interface
+	//StagingDir is where fetched binaries are written while they are verified,
+	//it is created if missing and must permit execution. Defaults to os.TempDir().
+	StagingDir string
+	//InMemory stages and runs fetched binaries from anonymous memory
+	//(memfd_create) rather than replacing the binary on disk, allowing
+	//upgrades on read-only filesystems. Upgrades last until the master
+	//process exits. Linux only.
+	InMemory bool
+	//BinaryMode sets the file mode of upgraded binaries.
+	//Defaults to the mode of the current binary.
+	BinaryMode os.FileMode
+	//BinaryUID and BinaryGID set the owner of upgraded binaries.
+	//Default to the current user and group.
+	BinaryUID, BinaryGID int
+	//Retention controls the cleanup of old fetched binaries, which is
+	//performed on start and after each upgrade. See Retention.
+	Retention Retention
+	//PeerAddress optionally serves the current binary to other overseer
+	//instances (e.g. ":7070"), reducing the load on the origin server.
+	//See fetcher.Peers.
+	PeerAddress string
+	//MetricsAddress optionally serves the metrics of the master in the
+	//Prometheus text format on /metrics (e.g. ":9090"), see
+	//MetricsHandler to serve them from the program instead.
+	MetricsAddress string
+	//AdminAddress optionally serves /healthz and /readyz from the master
+	//(e.g. "127.0.0.1:7071"), for load balancers and orchestrators. The
+	//former succeeds while the program is alive, including while it is
+	//being replaced by a restart, the latter while it is ready, see
+	//WaitReady. The Status is served on /status, and the History on
+	///history, see HistoryHandler.
+	AdminAddress string
+	//AdminPprof serves the profiles of the master for go tool pprof on
+	///debug/pprof/ of AdminAddress, such as its goroutines or CPU usage,
+	//to diagnose the master itself rather than the program.
+	AdminPprof bool
+	//AdminSocket optionally serves the admin commands over HTTP, on
+	//this unix socket path, or TCP address (e.g. "127.0.0.1:7072").
+	//It serves the Status on "GET /status" and the History on
+	//"GET /history", while "POST /restart",
+	//"POST /fetch", "POST /pause" and "POST /resume" act as Restart,
+	//FetchNow, Pause and Resume, and "POST /rollback" restores the
+	//binary replaced by the last upgrade, e.g.
+	//  curl --unix-socket /run/app.sock -X POST http://_/restart
+	//The unix socket is only accessible to its owner. See also the
+	//overseerctl command in cmd/overseerctl.
+	AdminSocket string
+	//AdminToken authenticates the admin commands, which must then
+	//carry an "Authorization: Bearer <AdminToken>" header. Required
+	//when AdminSocket is a TCP address.
+	AdminToken string
+	//StatsdAddress optionally emits a counter and a timer for each step
+	//of the upgrade lifecycle (see Event) to the StatsD agent listening
+	//on this UDP address (e.g. "127.0.0.1:8125"), such as "upgrade",
+	//"restart" or "fetch.failed".
+	StatsdAddress string
+	//StatsdPrefix prefixes the StatsD metrics. Defaults to "overseer.".
+	StatsdPrefix string
+	//StatsdTags are appended to each StatsD metric in the DogStatsD
+	//format, for example "env:prod".
+	StatsdTags []string
+	//FetchProgress is optionally called as a binary is downloaded, every
+	//second and once it completes, with its progress such as the bytes
+	//read and the throughput, for example to show it in a UI. It is
+	//called from the download, and so should return quickly.
+	FetchProgress func(p FetchProgress)
+	//Webhooks are optionally notified of upgrades, failures and
+	//rollbacks with a JSON POST, for example to alert a Slack
+	//channel of a bad release with SlackTemplate. See Webhook.
+	Webhooks []Webhook
+	//Email optionally notifies of failed upgrades and crashing
+	//programs by email, in batches. See Email.
+	Email *Email
+	//Tracer optionally traces the stages of each upgrade and restart,
+	//for example by adapting an OpenTelemetry tracer. See Tracer.
+	Tracer Tracer
+	//PeerAnnounce optionally announces the binary served on PeerAddress
+	//to this UDP multicast address (e.g. "239.7.7.7:7071"), allowing
+	//discovery by fetcher.Peers.
+	PeerAnnounce string
+	//UpgradeLock optionally limits how many instances sharing the same
+	//UpgradeLockKey may upgrade at once, preventing a whole fleet from
+	//restarting simultaneously. The lock is held from just before the
+	//binary is replaced until the following restart has completed,
+	//including one deferred by Pause or NoRestartAfterFetch.
+	UpgradeLock Locker
+	//UpgradeLockKey identifies the instances sharing the UpgradeLock.
+	//Defaults to the name of the binary.
+	UpgradeLockKey string
+	//Coordinator optionally coordinates upgrades across a fleet. Each
+	//upgrade must be permitted by the coordinator, and its outcome is
+	//reported back, allowing a bad build to halt the rollout. See the
+	//coordinator package.
+	Coordinator coordinator.Interface
+	//UpgradeProbation is how long an upgraded program must run before
+	//the upgrade is considered successful. Defaults to 30 seconds.
+	UpgradeProbation time.Duration
+	//StartupTimeout is how long a started program may take to become
+	//ready, once overseer is about to run it. A program which is not
+	//ready in time is killed. When it was started from an upgraded
+	//binary, the previous binary is restored and started in its place,
+	//and the upgrade is not retried, otherwise its exit is handled as a
+	//crash. Requires programs built with this version of overseer. Not
+	//supported with SelfUpgrade. Disabled by default.
+	StartupTimeout time.Duration
+	//WaitReady has the master wait for the program to call State.Ready
+	//once it has initialized, instead of considering it ready as soon
+	//as it is run. The readiness of the next program then gates the
+	//termination of the previous one with ReusePort or Replicas, the
+	//READY=1 notification to systemd, and the start of the program's
+	//UpgradeProbation. The master stops waiting for a program which is
+	//not ready within StartupTimeout, or TerminateTimeout when disabled.
+	WaitReady bool
+	//AuditLog is an optional path to an append-only log, where every
+	//fetch, verification, upgrade, restart, rollback and failure is
+	//recorded as a line of JSON. See Event.
+	AuditLog string
+	//HistorySize is the number of recent starts, upgrades, restarts,
+	//rollbacks and failures retained in the History. Defaults to 100.
+	HistorySize int
+	//HistoryFile is an optional path where the History is persisted,
+	//so it survives restarts of the master and may be read by the
+	//program. See UpgradeHistory.
+	HistoryFile string
+	//StatusFile is an optional path where the Status is written as
+	//JSON, replaced atomically as it changes and every 10 seconds,
+	//so that monitoring agents may read it. It is removed on exit.
+	StatusFile string
+}
+
+// Upgrade describes a fetched binary, see Config.PreUpgradeInfo
+// and Config.PostUpgrade
+type Upgrade struct {
+	//Path of the fetched binary
+	Path string
+	//Version of the binary, if reported by the fetcher
+	Version string
+	//Size of the binary in bytes
+	Size int64
+	//SHA256 is the hex encoded checksum of the binary
+	SHA256 string
+	//Source the binary was fetched from, such as its URL
+	Source string
+	//Notes describing the changes in the binary, such as
+	//the release notes of a fetcher.Manifest release
+	Notes string
 }
 
 func validate(c *Config) error {
@@ -65,37 +494,229 @@ func validate(c *Config) error {
 			return errors.New("overseer.Config.Address and Addresses cant both be set")
 		}
 		c.Addresses = []string{c.Address}
-	} else if len(c.Addresses) > 0 {
+	}
+	if len(c.NamedAddresses) > 0 {
+		names := make([]string, 0, len(c.NamedAddresses))
+		for name := range c.NamedAddresses {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		addresses := append([]string{}, c.Addresses...)
+		for _, name := range names {
+			if addr := c.NamedAddresses[name]; !containsAddress(addresses, addr) {
+				addresses = append(addresses, addr)
+			}
+		}
+		c.Addresses = addresses
+		//allow addresses to be configured by name
+		if c.TLS != nil {
+			m := map[string]*TLS{}
+			for k, t := range c.TLS {
+				m[namedAddress(c.NamedAddresses, k)] = t
+			}
+			c.TLS = m
+		}
+		if c.ListenOptions != nil {
+			m := map[string]*ListenOptions{}
+			for k, o := range c.ListenOptions {
+				m[namedAddress(c.NamedAddresses, k)] = o
+			}
+			c.ListenOptions = m
+		}
+	}
+	if c.Address == "" && len(c.Addresses) > 0 {
 		c.Address = c.Addresses[0]
 	}
 	if c.RestartSignal == nil {
 		c.RestartSignal = SIGUSR2
 	}
+	if c.FetchSignal != nil && (c.FetchSignal == SIGUSR1 || c.FetchSignal == c.RestartSignal) {
+		return errors.New("overseer.Config.FetchSignal must differ from SIGUSR1 and RestartSignal")
+	}
+	if c.ReloadSignal != nil && (c.ReloadSignal == SIGUSR1 || c.ReloadSignal == c.RestartSignal || c.ReloadSignal == c.FetchSignal) {
+		return errors.New("overseer.Config.ReloadSignal must differ from SIGUSR1, RestartSignal and FetchSignal")
+	}
+	if c.ReloadSignal != nil && c.Reload == nil {
+		return errors.New("overseer.Config.ReloadSignal requires Reload")
+	}
+	for _, s := range c.ForwardSignals {
+		if s == SIGUSR1 || s == c.RestartSignal || s == c.FetchSignal || s == c.ReloadSignal {
+			return fmt.Errorf("overseer.Config.ForwardSignals can not forward %s, it is handled by the master", s)
+		}
+	}
 	if c.TerminateTimeout <= 0 {
 		c.TerminateTimeout = 30 * time.Second
 	}
+	if err := validateEscalation(c.Escalation); err != nil {
+		return err
+	}
+	if c.DrainTimeout <= 0 {
+		//leaves the program time to finish once its connections are closed
+		c.DrainTimeout = c.killAfter() / 10 * 9
+	}
+	if c.DrainTimeout >= c.killAfter() {
+		return errors.New("overseer.Config.DrainTimeout must be shorter than TerminateTimeout, or the last step of Escalation")
+	}
 	if c.MinFetchInterval <= 0 {
 		c.MinFetchInterval = 1 * time.Second
 	}
+	if c.InMemory && runtime.GOOS != "linux" {
+		return errors.New("overseer.Config.InMemory is only supported on linux")
+	}
+	if c.SelfUpgrade && (runtime.GOOS == "windows" || c.InMemory) {
+		return errors.New("overseer.Config.SelfUpgrade is not supported on windows or with InMemory")
+	}
+	if c.AdminSocket != "" && !isSocketPath(c.AdminSocket) && c.AdminToken == "" {
+		return errors.New("overseer.Config.AdminToken is required with a TCP AdminSocket")
+	}
+	if c.AdminPprof && c.AdminAddress == "" {
+		return errors.New("overseer.Config.AdminPprof requires AdminAddress")
+	}
+	if err := validateLogLevels(c.LogLevel, c.LogLevels); err != nil {
+		return err
+	}
+	if err := validateWebhooks(c.Webhooks); err != nil {
+		return err
+	}
+	if c.Email != nil {
+		if err := c.Email.validate(); err != nil {
+			return err
+		}
+	}
+	if c.CrashReporter != nil && c.SelfUpgrade {
+		return errors.New("overseer.Config.CrashReporter is not supported with SelfUpgrade")
+	}
+	if (c.Stdout != nil || c.Stderr != nil) && c.SelfUpgrade {
+		//the pipes copied by the master are lost when it re-executes
+		return errors.New("overseer.Config.Stdout and Stderr are not supported with SelfUpgrade")
+	}
+	if c.StartupTimeout > 0 && c.SelfUpgrade {
+		return errors.New("overseer.Config.StartupTimeout is not supported with SelfUpgrade")
+	}
+	for _, addr := range c.Addresses {
+		if network, address := parseAddress(addr); network == "unix" && isAbstract(address) && runtime.GOOS != "linux" {
+			return fmt.Errorf("overseer.Config.Addresses %s: abstract unix sockets are only supported on linux", addr)
+		}
+	}
+	for addr, t := range c.TLS {
+		if err := t.validate(addr, c.Addresses); err != nil {
+			return err
+		}
+	}
+	for addr, o := range c.ListenOptions {
+		if o == nil {
+			continue
+		}
+		if !containsAddress(c.Addresses, addr) {
+			return fmt.Errorf("overseer.Config.ListenOptions %s is not in Addresses", addr)
+		}
+		if network, _ := parseAddress(addr); o.ProxyProtocol && isPacketNetwork(network) {
+			return fmt.Errorf("overseer.Config.ListenOptions %s: ProxyProtocol requires a stream address", addr)
+		}
+		if o.BindDevice != "" && runtime.GOOS != "linux" {
+			return errors.New("overseer.Config.ListenOptions BindDevice is only supported on linux")
+		}
+	}
+	for _, addr := range c.Addresses {
+		if network, _ := parseAddress(addr); isSCTPNetwork(network) && (runtime.GOOS != "linux" || c.ReusePort) {
+			return fmt.Errorf("overseer.Config.Addresses %s: sctp is only supported on linux, without ReusePort", addr)
+		}
+	}
+	if c.ReusePort {
+		if runtime.GOOS == "windows" || c.SelfUpgrade {
+			return errors.New("overseer.Config.ReusePort is not supported on windows or with SelfUpgrade")
+		}
+		for _, addr := range c.Addresses {
+			if network, _ := parseAddress(addr); network == "unix" {
+				return fmt.Errorf("overseer.Config.ReusePort does not support %s", addr)
+			}
+		}
+	}
+	if c.Daemonize && runtime.GOOS == "windows" {
+		return errors.New("overseer.Config.Daemonize is not supported on windows")
+	}
+	if c.Launchd != nil && runtime.GOOS != "darwin" {
+		return errors.New("overseer.Config.Launchd is only supported on macOS")
+	}
+	if c.WindowsService && runtime.GOOS != "windows" {
+		return errors.New("overseer.Config.WindowsService is only supported on windows")
+	}
+	if c.ConnectionHandoff && (runtime.GOOS == "windows" || c.SelfUpgrade) {
+		return errors.New("overseer.Config.ConnectionHandoff is not supported on windows or with SelfUpgrade")
+	}
+	if (c.User != "" || c.Group != "" || len(c.Groups) > 0) && runtime.GOOS == "windows" {
+		return errors.New("overseer.Config.User is not supported on windows")
+	}
+	if c.Chroot != "" && (runtime.GOOS == "windows" || c.InMemory) {
+		return errors.New("overseer.Config.Chroot is not supported on windows or with InMemory")
+	}
+	if c.Replicas > 1 && (runtime.GOOS == "windows" || c.SelfUpgrade || c.ConnectionHandoff || c.Terminal) {
+		return errors.New("overseer.Config.Replicas is not supported on windows, or with SelfUpgrade, ConnectionHandoff or Terminal")
+	}
+	if c.UsageInterval > 0 && runtime.GOOS != "linux" {
+		return errors.New("overseer.Config.UsageInterval is only supported on linux")
+	}
+	if c.Terminal && runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		return errors.New("overseer.Config.Terminal is only supported on linux and macOS")
+	}
+	if c.Terminal && (c.Stdin != StdinInherit || c.Stdout != nil || c.Stderr != nil || c.Daemonize || c.SelfUpgrade) {
+		return errors.New("overseer.Config.Terminal conflicts with Stdin, Stdout, Stderr, Daemonize and SelfUpgrade")
+	}
+	if c.Subreaper && runtime.GOOS != "linux" {
+		return errors.New("overseer.Config.Subreaper is only supported on linux")
+	}
+	if c.MountNamespace && runtime.GOOS != "linux" {
+		return errors.New("overseer.Config.MountNamespace is only supported on linux")
+	}
+	if err := c.validatePrograms(); err != nil {
+		return err
+	}
+	if c.Environment != nil {
+		if err := c.Environment.validate(); err != nil {
+			return err
+		}
+	}
+	if l := c.Limits; l != nil {
+		if c.CoreDumps && l.NoCore {
+			return errors.New("overseer.Config.CoreDumps conflicts with Limits NoCore")
+		}
+		if runtime.GOOS == "windows" {
+			return errors.New("overseer.Config.Limits is not supported on windows")
+		}
+		if l.CGroup != "" && runtime.GOOS != "linux" {
+			return errors.New("overseer.Config.Limits CGroup is only supported on linux")
+		}
+		if (l.Memory > 0 || l.CPU > 0) && l.CGroup == "" {
+			return errors.New("overseer.Config.Limits Memory and CPU require a CGroup")
+		}
+	}
+	if c.SocketMode == 0 {
+		c.SocketMode = 0660
+	}
+	if c.UpgradeProbation <= 0 {
+		c.UpgradeProbation = 30 * time.Second
+	}
 	return nil
 }
 
 // RunErr allows manual handling of any
-// overseer errors.
+// overseer errors. Their cause may be told
+// apart with errors.Is, see ErrBindFailed.
 func RunErr(c Config) error {
-	return runErr(&c)
+	return runErr(nil, &c)
 }
 
 // Run executes overseer, if an error is
 // encountered, overseer fallsback to running
 // the program directly (unless Required is set).
 func Run(c Config) {
-	err := runErr(&c)
+	err := runErr(nil, &c)
 	if err != nil {
 		if c.Required {
-			log.Fatalf("[overseer] %s", err)
-		} else if c.Debug || !c.NoWarn {
-			log.Printf("[overseer] disabled. run failed: %s", err)
+			c.logf(LogError, "", "%s", err)
+			os.Exit(1)
+		} else if c.logs("master", LogWarn) {
+			c.logf(LogWarn, "", "disabled. run failed: %s", err)
 		}
 		c.Program(DisabledState)
 		return
@@ -132,14 +753,23 @@ func SanityCheck() {
 
 // abstraction over master/slave
 var currentProcess interface {
-	triggerRestart()
+	triggerRestart() RestartStatus
+	fetchNow(ctx context.Context) error
+	setPaused(paused bool) error
+	isPaused() bool
+	history() (History, error)
+	metrics() ([]byte, error)
+	status() (Status, error)
+	changeListener(op, addr string) error
+	reload() error
+	listener(network, address string) net.Listener
 	run() error
 }
 
-func runErr(c *Config) error {
+func runErr(ctx context.Context, c *Config) error {
 	//os not supported
 	if !supported {
-		return fmt.Errorf("os (%s) not supported", runtime.GOOS)
+		return fmt.Errorf("%w (%s)", ErrUnsupportedOS, runtime.GOOS)
 	}
 	if err := validate(c); err != nil {
 		return err
@@ -149,19 +779,118 @@ func runErr(c *Config) error {
 	}
 	//run either in master or slave mode
 	if os.Getenv(envIsSlave) == "1" {
-		currentProcess = &slave{Config: c}
+		currentProcess = &slave{Config: c, ctx: ctx}
 	} else {
-		currentProcess = &master{Config: c}
+		currentProcess = &master{Config: c, ctx: ctx}
 	}
 	return currentProcess.run()
 }
 
+// RestartStatus describes how a restart request was handled.
+type RestartStatus int
+
+const (
+	//RestartIgnored indicates there was no program to restart
+	RestartIgnored RestartStatus = iota
+	//RestartStarted indicates a graceful restart has begun
+	RestartStarted
+	//RestartQueued indicates a restart was already in progress,
+	//so another will be performed once it completes
+	RestartQueued
+	//RestartCoalesced indicates a restart was already queued,
+	//and this request has been merged into it
+	RestartCoalesced
+	//RestartRequested indicates the request was forwarded to
+	//the master process, which will handle it as above
+	RestartRequested
+)
+
+func (s RestartStatus) String() string {
+	switch s {
+	case RestartStarted:
+		return "started"
+	case RestartQueued:
+		return "queued"
+	case RestartCoalesced:
+		return "coalesced"
+	case RestartRequested:
+		return "requested"
+	}
+	return "ignored"
+}
+
 // Restart programmatically triggers a graceful restart. If NoRestart
 // is enabled, then this will essentially be a graceful shutdown.
+// Restarts requested while another is in progress are coalesced
+// into a single follow-up restart, see RestartWithStatus.
 func Restart() {
+	RestartWithStatus()
+}
+
+// RestartWithStatus triggers a graceful restart as Restart does,
+// and returns whether the restart was started, queued behind the
+// one in progress, or coalesced into the one already queued.
+func RestartWithStatus() RestartStatus {
+	if currentProcess != nil {
+		return currentProcess.triggerRestart()
+	}
+	return RestartIgnored
+}
+
+// FetchNow forces an immediate check for updates, outside of the
+// regular fetch interval, and returns the result of that check.
+// When called from the program, the request is forwarded to the
+// master process, using FetchSignal (which must then be set) when
+// the master predates the control protocol, and the result is not
+// awaited. ErrFetchSkipped is returned when a restart was in progress.
+func FetchNow() error {
+	return FetchNowContext(context.Background())
+}
+
+// FetchNowContext is FetchNow, though it stops waiting for the result
+// once ctx is done, returning its error, for example while the upgrade
+// awaits the Coordinator or the UpgradeLock. The check continues.
+func FetchNowContext(ctx context.Context) error {
+	if currentProcess != nil {
+		return currentProcess.fetchNow(ctx)
+	}
+	return ErrNotRunning
+}
+
+// Pause suspends automatic fetches and restarts, for example during
+// an incident freeze. Manual restarts may still be performed. If the
+// binary was upgraded as the pause began, the restart is deferred
+// until Resume.
+func Pause() error {
+	if currentProcess != nil {
+		return currentProcess.setPaused(true)
+	}
+	return ErrNotRunning
+}
+
+// Resume re-enables automatic fetches and restarts after a Pause.
+func Resume() error {
 	if currentProcess != nil {
-		currentProcess.triggerRestart()
+		return currentProcess.setPaused(false)
 	}
+	return ErrNotRunning
+}
+
+// UpgradeHistory returns the recent starts, upgrades, restarts,
+// rollbacks and failures of the program, oldest first. Use
+// History.At to find the version running at a given time.
+// When called from the program, the history is read from
+// HistoryFile when set, and asked of the master otherwise.
+func UpgradeHistory() (History, error) {
+	if currentProcess != nil {
+		return currentProcess.history()
+	}
+	return nil, ErrNotRunning
+}
+
+// Paused returns whether automatic upgrades are currently paused.
+func Paused() bool {
+	return currentProcess != nil && currentProcess.isPaused()
 }
 
 // IsSupported returns whether overseer is supported on the current OS.
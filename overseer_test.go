@@ -0,0 +1,127 @@
+package overseer
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func program(state State) {}
+
+func TestValidateDefaults(t *testing.T) {
+	c := &Config{Program: program, Address: ":3000"}
+	if err := validate(c); err != nil {
+		t.Fatal(err)
+	}
+	if !equalStrings(c.Addresses, []string{":3000"}) {
+		t.Errorf("got addresses %q", c.Addresses)
+	}
+	if c.RestartSignal != SIGUSR2 {
+		t.Errorf("got restart signal %v", c.RestartSignal)
+	}
+	if c.TerminateTimeout != 30*time.Second || c.DrainTimeout != 27*time.Second {
+		t.Errorf("got terminate timeout %s and drain timeout %s", c.TerminateTimeout, c.DrainTimeout)
+	}
+	if c.MinFetchInterval != time.Second || c.UpgradeProbation != 30*time.Second {
+		t.Errorf("got min fetch interval %s and upgrade probation %s", c.MinFetchInterval, c.UpgradeProbation)
+	}
+	if c.SocketMode != 0660 {
+		t.Errorf("got socket mode %v", c.SocketMode)
+	}
+}
+
+func TestValidateNamedAddresses(t *testing.T) {
+	options := &ListenOptions{KeepAlive: time.Minute}
+	c := &Config{
+		Program:        program,
+		Addresses:      []string{":3000"},
+		NamedAddresses: map[string]string{"public": ":3001", "admin": ":3002", "same": ":3000"},
+		ListenOptions:  map[string]*ListenOptions{"public": options},
+	}
+	if err := validate(c); err != nil {
+		t.Fatal(err)
+	}
+	//sorted by name after the addresses, without duplicates
+	if want := []string{":3000", ":3002", ":3001"}; !equalStrings(c.Addresses, want) {
+		t.Errorf("got addresses %q, expected %q", c.Addresses, want)
+	}
+	if c.Address != ":3000" {
+		t.Errorf("got address %q", c.Address)
+	}
+	if c.ListenOptions[":3001"] != options {
+		t.Errorf("options not keyed by address: %v", c.ListenOptions)
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{"program", Config{}, "Program required"},
+		{"address", Config{Address: ":1", Addresses: []string{":2"}}, "cant both be set"},
+		{"fetch signal", Config{FetchSignal: SIGUSR1}, "FetchSignal"},
+		{"reload signal", Config{ReloadSignal: os.Interrupt}, "ReloadSignal requires Reload"},
+		{"forward signals", Config{ForwardSignals: []os.Signal{SIGUSR2}}, "ForwardSignals"},
+		{"drain timeout", Config{TerminateTimeout: time.Second, DrainTimeout: time.Second}, "DrainTimeout"},
+		{"drain escalation", Config{DrainTimeout: 20 * time.Second, Escalation: []Escalation{{os.Interrupt, 5 * time.Second}, {os.Kill, 10 * time.Second}}}, "DrainTimeout"},
+		{"admin token", Config{AdminSocket: "127.0.0.1:9000"}, "AdminToken"},
+		{"admin pprof", Config{AdminPprof: true}, "AdminPprof"},
+		{"listen options", Config{Addresses: []string{":1"}, ListenOptions: map[string]*ListenOptions{":2": {}}}, "not in Addresses"},
+		{"proxy protocol", Config{Addresses: []string{"udp://:1"}, ListenOptions: map[string]*ListenOptions{"udp://:1": {ProxyProtocol: true}}}, "ProxyProtocol"},
+		{"crash reporter", Config{SelfUpgrade: true, CrashReporter: func(CrashReport) {}}, "SelfUpgrade"},
+		{"output", Config{SelfUpgrade: true, Stdout: os.Stdout}, "SelfUpgrade"},
+		{"terminal", Config{Terminal: true, Stdin: StdinNull}, "Terminal"},
+		{"reuse port", Config{ReusePort: true, Addresses: []string{"unix:///tmp/app.sock"}}, "ReusePort"},
+		{"limits", Config{Limits: &Limits{Memory: 1 << 30}}, "Limits"},
+	} {
+		c := test.config
+		if test.name != "program" {
+			c.Program = program
+		}
+		err := validate(&c)
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: got %q, expected it to mention %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestValidateDrainTimeout(t *testing.T) {
+	//the drain deadline passes before the program is killed
+	c := &Config{Program: program, TerminateTimeout: time.Minute, Escalation: []Escalation{{os.Interrupt, 5 * time.Second}, {os.Kill, 10 * time.Second}}}
+	if err := validate(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.DrainTimeout != 9*time.Second {
+		t.Errorf("got drain timeout %s, expected 9s", c.DrainTimeout)
+	}
+}
+
+func TestValidatePlatform(t *testing.T) {
+	c := &Config{Program: program, InMemory: true}
+	err := validate(c)
+	if runtime.GOOS == "linux" && err != nil {
+		t.Errorf("InMemory rejected on linux (%s)", err)
+	} else if runtime.GOOS != "linux" && err == nil {
+		t.Errorf("InMemory accepted on %s", runtime.GOOS)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,79 @@
+package overseer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/menglh/overseer/fetcher"
+)
+
+// peerAnnounceInterval is the delay between peer announcements
+const peerAnnounceInterval = 5 * time.Second
+
+// servePeers serves the current binary to other overseer instances
+// and optionally announces it on the network
+func (mp *master) servePeers() error {
+	if mp.Config.PeerAddress == "" {
+		return nil
+	}
+	l, err := net.Listen("tcp", mp.Config.PeerAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on peer address (%w)", err)
+	}
+	mp.serving(l)
+	go http.Serve(l, http.HandlerFunc(mp.servePeer))
+	if mp.Config.PeerAnnounce != "" {
+		addr, err := net.ResolveUDPAddr("udp", mp.Config.PeerAnnounce)
+		if err != nil {
+			return fmt.Errorf("invalid peer announce address (%w)", err)
+		}
+		go mp.announcePeer(addr, l.Addr().(*net.TCPAddr).Port)
+	}
+	mp.debugf("serving binary to peers on %s", l.Addr())
+	return nil
+}
+
+func (mp *master) servePeer(w http.ResponseWriter, r *http.Request) {
+	sum := strings.TrimPrefix(r.URL.Path, fetcher.PeerPath)
+	if r.Method != http.MethodGet || sum != mp.peerSum() {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(mp.execPath())
+	if err != nil {
+		http.Error(w, "binary unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	defer f.Close()
+	mp.debugf("serving binary to peer %s", r.RemoteAddr)
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+func (mp *master) announcePeer(addr *net.UDPAddr, port int) {
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		mp.warnf("failed to announce to peers: %s", err)
+		return
+	}
+	for {
+		b, _ := json.Marshal(fetcher.PeerAnnouncement{
+			SHA256: mp.peerSum(),
+			Port:   port,
+		})
+		conn.Write(b)
+		time.Sleep(peerAnnounceInterval)
+	}
+}
+
+// peerSum is the hex encoded SHA-256 of the current binary
+func (mp *master) peerSum() string {
+	mp.binMux.Lock()
+	defer mp.binMux.Unlock()
+	return hex.EncodeToString(mp.binSHA256)
+}
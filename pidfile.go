@@ -0,0 +1,66 @@
+package overseer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// writePIDFiles writes the master's PIDFile, failing
+// when it belongs to another running master
+func (mp *master) writePIDFiles() error {
+	if mp.Config.PIDFile == "" {
+		return nil
+	}
+	if pid, ok := readPIDFile(mp.Config.PIDFile); ok && pid != os.Getpid() {
+		if processAlive(pid) {
+			return fmt.Errorf("overseer.Config.PIDFile %s: already running (pid %d)", mp.Config.PIDFile, pid)
+		}
+		mp.debugf("replacing stale pid file %s (pid %d)", mp.Config.PIDFile, pid)
+	}
+	if err := writePIDFile(mp.Config.PIDFile, os.Getpid()); err != nil {
+		return fmt.Errorf("failed to write pid file (%w)", err)
+	}
+	return nil
+}
+
+// programStarted writes the program's ProgramPIDFile
+func (mp *master) programStarted(pid int) {
+	if mp.Config.ProgramPIDFile == "" {
+		return
+	}
+	if err := writePIDFile(mp.Config.ProgramPIDFile, pid); err != nil {
+		mp.warnf("failed to write program pid file (%s)", err)
+	}
+}
+
+// removePIDFiles removes the pid files as the master exits
+func (mp *master) removePIDFiles() {
+	for _, path := range []string{mp.Config.PIDFile, mp.Config.ProgramPIDFile} {
+		if path != "" {
+			os.Remove(path)
+		}
+	}
+}
+
+func readPIDFile(path string) (int, bool) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	return pid, err == nil && pid > 0
+}
+
+// writePIDFile replaces the file, so that it
+// is never read partially written
+func writePIDFile(path string, pid int) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+// +build !windows
+
+package overseer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = proc.Signal(syscall.Signal(0))
+	//running as another user
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
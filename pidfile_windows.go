@@ -0,0 +1,14 @@
+package overseer
+
+import (
+	"os"
+)
+
+func processAlive(pid int) bool {
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	proc.Release()
+	return true
+}
@@ -0,0 +1,85 @@
+package overseer
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pprofPath is where AdminPprof serves the profiles of the master
+const pprofPath = "/debug/pprof/"
+
+// servePprof serves the profiles of the master in the format of
+// net/http/pprof, which is not imported as it would register its
+// handlers on http.DefaultServeMux, and so expose them in the program
+func servePprof(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, pprofPath)
+	seconds, _ := strconv.Atoi(r.URL.Query().Get("seconds"))
+	if seconds <= 0 {
+		seconds = 30
+	}
+	duration := time.Duration(seconds) * time.Second
+	switch name {
+	case "":
+		profiles := pprof.Profiles()
+		sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name() < profiles[j].Name() })
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "profiles of the overseer master (pid %d), see go tool pprof:\n\n", os.Getpid())
+		for _, p := range profiles {
+			fmt.Fprintf(w, "%s%s?debug=1\t%d\n", pprofPath, p.Name(), p.Count())
+		}
+		fmt.Fprintf(w, "%sprofile?seconds=30\tCPU profile\n%strace?seconds=5\texecution trace\n", pprofPath, pprofPath)
+	case "profile":
+		attachment(w, "profile")
+		if err := pprof.StartCPUProfile(w); err != nil {
+			http.Error(w, fmt.Sprintf("failed to profile (%s)", err), http.StatusInternalServerError)
+			return
+		}
+		sleep(r, duration)
+		pprof.StopCPUProfile()
+	case "trace":
+		attachment(w, "trace")
+		if err := trace.Start(w); err != nil {
+			http.Error(w, fmt.Sprintf("failed to trace (%s)", err), http.StatusInternalServerError)
+			return
+		}
+		sleep(r, duration)
+		trace.Stop()
+	default:
+		p := pprof.Lookup(name)
+		if p == nil {
+			http.Error(w, "unknown profile", http.StatusNotFound)
+			return
+		}
+		debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+		if name == "heap" && r.URL.Query().Get("gc") != "" {
+			runtime.GC()
+		}
+		if debug > 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			attachment(w, name)
+		}
+		p.WriteTo(w, debug)
+	}
+}
+
+func attachment(w http.ResponseWriter, name string) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+}
+
+// sleep waits for the duration, or until the client went away
+func sleep(r *http.Request, d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-r.Context().Done():
+	}
+}
@@ -2,12 +2,14 @@ package overseer
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net"
 	"os"
 	"os/exec"
@@ -15,52 +17,208 @@ import (
 	"path/filepath"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
+
+	"github.com/menglh/overseer/fetcher"
 )
 
-var tmpBinPath = filepath.Join(os.TempDir(), "overseer-"+token()+extension())
+// restartSettle is the delay before a queued restart is performed
+const restartSettle = 1 * time.Second
 
 //a overseer master process
 type master struct {
 	*Config
 	slaveID             int
-	slaveCmd            *exec.Cmd
+	slaveProc           atomic.Value //*os.Process, see activeProc
+	activeSlaveID       int32 //atomic, see activeID
+	slaveSignals        *os.File
 	slaveExtraFiles     []*os.File
 	binPath, tmpBinPath string
+	binName             string
 	binPerms            os.FileMode
+	binUID, binGID      int
 	binHash             []byte
+	binSHA256           []byte
+	binVersion          string
+	binMux              sync.Mutex
+	memBin              *os.File
+	prevBin             *previousBinary
+	peerMux             sync.Mutex
+	peers               map[int]*controlPeer
+	rejectedHash        []byte
 	restartMux          sync.Mutex
 	restarting          bool
+	restartActive       bool
+	restartQueued       bool
+	restartReason       string
+	restartKill         bool
+	restartSpan         Span
+	drainSpans          map[int]drain
+	restartIdle         *sync.Cond
+	reexecPending       bool
+	reexecUnlock        func()
+	upgradeUnlock       func()
+	startedAt           time.Time
 	restartedAt         time.Time
 	restarted           chan bool
 	awaitingUSR1        bool
 	descriptorsReleased chan bool
+	slaveBound          chan bool
 	signalledAt         time.Time
+	releasedAt          time.Time
 	printCheckUpdate    bool
+	stopping            int32 //atomic, see isStopping
+	fetchMux            sync.Mutex
+	fetchWaiters        []chan error
+	fetchWake           chan bool
+	pauseMux            sync.Mutex
+	paused              bool
+	pausedRestart       bool
+	probationMux        sync.Mutex
+	probationHash       []byte
+	probationSlaveID    int
+	probationStart      time.Time
+	metricCounts        metrics
+	statsd              *statsd
+	notifier            *notifier
+	mailer              *mailer
+	crashMux            sync.Mutex
+	stderrTails         map[int]*stderrTail
+	statusChanges       chan bool
+	statusFileMux       sync.Mutex
+	statusFileRemoved   bool
+	eventMux            sync.Mutex
+	auditLog            *os.File
+	eventHistory        History
+	socketPaths         []string
+	listenerMux         sync.Mutex
+	dynamicAddrs        []string
+	dynamicFiles        []*os.File
+	files               map[string]*os.File
+	notifySocket        string
+	watchdogUSEC        int64
+	handoffMux          sync.Mutex
+	handoffConn         *net.UnixConn
+	handoffQueue        []queuedHandoff
+	forwardMux          sync.Mutex
+	credential          *credential
+	reloadMux           sync.Mutex
+	logLevels           atomic.Value //*Config of the levels, see logs
+	stdout, stderr      io.Writer
+	terminal            *terminal
+	replicaMux          sync.Mutex
+	replicas            []*replica
+	replicaStarts       map[int]*sync.Mutex
+	programFiles        [][]*os.File
+	stateMux            sync.Mutex
+	savedState          []byte
+	reapNudge           chan bool
+	//ctx is that of RunContext, nil for Run
+	ctx      context.Context
+	signals  chan os.Signal
+	stopped  chan bool
+	exitOnce sync.Once
+	exitCode int
+	servers  []io.Closer
 }
 
 func (mp *master) run() error {
+	if exit, err := mp.daemonize(); err != nil {
+		return err
+	} else if exit {
+		os.Exit(0)
+	}
 	mp.debugf("run")
+	mp.startedAt = time.Now()
 	if err := mp.checkBinary(); err != nil {
 		return err
 	}
+	if err := mp.writePIDFiles(); err != nil {
+		return err
+	}
+	if err := mp.lookupCredential(); err != nil {
+		return err
+	}
+	if err := mp.setupCGroup(); err != nil {
+		return err
+	}
+	if err := mp.openAuditLog(); err != nil {
+		return err
+	}
+	mp.loadHistory()
 	if mp.Config.Fetcher != nil {
+		if l, ok := mp.Config.Fetcher.(fetcher.Logger); ok {
+			l.SetLogf(mp.fetchWarnf)
+		}
 		if err := mp.Config.Fetcher.Init(); err != nil {
 			mp.warnf("fetcher init failed (%s). fetcher disabled.", err)
 			mp.Config.Fetcher = nil
 		}
 	}
+	if err := mp.setupSubreaper(); err != nil {
+		return err
+	}
+	mp.setupOutput()
+	mp.setupTerminal()
 	mp.setupSignalling()
+	mp.setupConsole()
+	mp.watchContext()
+	mp.setupNotify()
+	mp.setupService()
 	if err := mp.retreiveFileDescriptors(); err != nil {
 		return err
 	}
+	if err := mp.openFiles(); err != nil {
+		return err
+	}
+	mp.cleanup()
+	if err := mp.servePeers(); err != nil {
+		mp.warnf("%s. peer sharing disabled.", err)
+	}
+	if err := mp.serveMetrics(); err != nil {
+		mp.warnf("%s. metrics disabled.", err)
+	}
+	if err := mp.serveAdmin(); err != nil {
+		mp.warnf("%s. admin endpoints disabled.", err)
+	}
+	if err := mp.serveAdminSocket(); err != nil {
+		mp.warnf("%s. admin commands disabled.", err)
+	}
+	if err := mp.setupStatsd(); err != nil {
+		mp.warnf("%s. statsd disabled.", err)
+	}
+	mp.setupWebhooks()
+	mp.setupEmail()
+	publishVars(func() interface{} {
+		return mp.vars()
+	})
+	mp.writeStatusFiles()
+	mp.registerInstance()
+	if mp.Config.UsageInterval > 0 {
+		go mp.usageLoop()
+	}
 	if mp.Config.Fetcher != nil {
 		mp.printCheckUpdate = true
-		mp.fetch()
+		mp.fetched(mp.fetch())
 		go mp.fetchLoop()
 	}
+	if mp.adoptSlave() {
+		//restart the adopted slave with our binary
+		mp.triggerRestart()
+		if err := mp.awaitAdopted(); err != nil {
+			return err
+		}
+	} else {
+		event := Event{Type: EventStart, SlaveID: mp.slaveID + 1}
+		event.Hash, event.Version = mp.binary()
+		mp.emit(event)
+	}
+	if mp.ctx != nil {
+		return mp.forkLoopContext()
+	}
 	return mp.forkLoop()
 }
 
@@ -68,33 +226,56 @@ func (mp *master) checkBinary() error {
 	//get path to binary and confirm its writable
 	binPath, err := os.Executable()
 	if err != nil {
-		return fmt.Errorf("failed to find binary path (%s)", err)
+		return fmt.Errorf("failed to find binary path (%w)", err)
 	}
 	mp.binPath = binPath
+	mp.binName = strings.TrimSuffix(filepath.Base(binPath), extension())
+	stagingDir := mp.Config.StagingDir
+	if stagingDir == "" {
+		stagingDir = os.TempDir()
+	} else if err := os.MkdirAll(stagingDir, 0700); err != nil {
+		return fmt.Errorf("failed to create staging directory (%w)", err)
+	}
+	mp.tmpBinPath = filepath.Join(stagingDir, tmpBinPrefix+mp.binName+"-"+token()+extension())
+	if mp.Config.UpgradeLockKey == "" {
+		mp.Config.UpgradeLockKey = mp.binName
+	}
 	if info, err := os.Stat(binPath); err != nil {
-		return fmt.Errorf("failed to stat binary (%s)", err)
+		return fmt.Errorf("failed to stat binary (%w)", err)
 	} else if info.Size() == 0 {
 		return fmt.Errorf("binary file is empty")
 	} else {
 		//copy permissions
 		mp.binPerms = info.Mode()
 	}
+	if mp.Config.BinaryMode != 0 {
+		mp.binPerms = mp.Config.BinaryMode
+	}
+	mp.binUID, mp.binGID = uid, gid
+	if mp.Config.BinaryUID != 0 {
+		mp.binUID = mp.Config.BinaryUID
+	}
+	if mp.Config.BinaryGID != 0 {
+		mp.binGID = mp.Config.BinaryGID
+	}
 	f, err := os.Open(binPath)
 	if err != nil {
-		return fmt.Errorf("cannot read binary (%s)", err)
+		return fmt.Errorf("cannot read binary (%w)", err)
 	}
 	//initial hash of file
 	hash := sha1.New()
-	io.Copy(hash, f)
+	hash256 := sha256.New()
+	io.Copy(io.MultiWriter(hash, hash256), f)
 	mp.binHash = hash.Sum(nil)
+	mp.binSHA256 = hash256.Sum(nil)
 	f.Close()
 	//test bin<->tmpbin moves
-	if mp.Config.Fetcher != nil {
-		if err := move(tmpBinPath, mp.binPath); err != nil {
-			return fmt.Errorf("cannot move binary (%s)", err)
+	if mp.Config.Fetcher != nil && !mp.Config.InMemory {
+		if err := move(mp.tmpBinPath, mp.binPath); err != nil {
+			return fmt.Errorf("cannot move binary (%w)", err)
 		}
-		if err := move(mp.binPath, tmpBinPath); err != nil {
-			return fmt.Errorf("cannot move binary back (%s)", err)
+		if err := move(mp.binPath, mp.tmpBinPath); err != nil {
+			return fmt.Errorf("cannot move binary back (%w)", err)
 		}
 	}
 	return nil
@@ -104,12 +285,25 @@ func (mp *master) setupSignalling() {
 	//updater-forker comms
 	mp.restarted = make(chan bool)
 	mp.descriptorsReleased = make(chan bool)
+	mp.slaveBound = make(chan bool, 1)
+	mp.fetchWake = make(chan bool, 1)
+	mp.restartIdle = sync.NewCond(&mp.restartMux)
+	if mp.ctx != nil {
+		//closed once the master exits, see returnExit
+		mp.stopped = make(chan bool)
+	}
 	//read all master process signals
-	signals := make(chan os.Signal)
+	signals := make(chan os.Signal, 1)
 	signal.Notify(signals)
+	mp.signals = signals
 	go func() {
-		for s := range signals {
-			mp.handleSignal(s)
+		for {
+			select {
+			case s := <-signals:
+				mp.handleSignal(s)
+			case <-mp.stopped:
+				return //see returnExit
+			}
 		}
 	}()
 }
@@ -117,218 +311,801 @@ func (mp *master) setupSignalling() {
 func (mp *master) handleSignal(s os.Signal) {
 	if s == mp.RestartSignal {
 		//user initiated manual restart
-		go mp.triggerRestart()
+		mp.triggerRestart()
+	} else if mp.FetchSignal != nil && s == mp.FetchSignal {
+		//user initiated immediate fetch
+		go mp.triggerFetch()
+	} else if mp.ReloadSignal != nil && s == mp.ReloadSignal {
+		go mp.reload()
+	} else if mp.terminal != nil && isResize(s) {
+		//the program's terminal is resized instead
+		mp.resizeTerminal()
 	} else if s.String() == "child exited" {
 		// will occur on every restart, ignore it
+		mp.childExited()
+	} else
+	//with ReusePort, a SIGUSR1 signals that
+	//the program has bound its sockets
+	if s == SIGUSR1 && mp.ReusePort {
+		select {
+		case mp.slaveBound <- true:
+		default:
+		}
 	} else
 	//**during a restart** a SIGUSR1 signals
 	//to the master process that, the file
 	//descriptors have been released
-	if mp.awaitingUSR1 && s == SIGUSR1 {
-		mp.debugf("signaled, sockets ready")
-		mp.awaitingUSR1 = false
-		mp.descriptorsReleased <- true
+	if s == SIGUSR1 && mp.released() {
+		//the restart continues
+	} else
+	//signals which the program should not receive
+	if !mp.forwards(s) {
+		mp.debugf("signal discarded (%s)", s)
 	} else
 	//while the slave process is running, proxy
 	//all signals through
-	if mp.slaveCmd != nil && mp.slaveCmd.Process != nil {
+	if mp.activeProc() != nil {
 		mp.debugf("proxy signal (%s)", s)
+		if s == SIGTERM || s == os.Interrupt {
+			mp.setStopping()
+			if mp.StopSignal != nil {
+				s = mp.StopSignal
+			}
+		}
 		mp.sendSignal(s)
+		mp.signalReplicas(s)
 	} else
 	//otherwise if not running, kill on CTRL+c
 	if s == os.Interrupt {
 		mp.debugf("interupt with no slave")
-		os.Exit(1)
+		mp.exit(1)
 	} else {
 		mp.debugf("signal discarded (%s), no slave process", s)
 	}
 }
 
+// releaseAwaited reports whether a SIGUSR1 was expected,
+// consuming the expectation if so
+func (mp *master) releaseAwaited() bool {
+	mp.restartMux.Lock()
+	defer mp.restartMux.Unlock()
+	if !mp.awaitingUSR1 {
+		return false
+	}
+	mp.awaitingUSR1 = false
+	mp.releasedAt = time.Now()
+	return true
+}
+
+// exitReleased records the exit of the program being
+// replaced before it released its sockets, see Event.Handover
+func (mp *master) exitReleased() {
+	mp.restartMux.Lock()
+	defer mp.restartMux.Unlock()
+	if mp.releasedAt.IsZero() {
+		mp.releasedAt = time.Now()
+	}
+}
+
+// released notifies the restart that the sockets are ready,
+// reporting false when no release was expected
+func (mp *master) released() bool {
+	if !mp.releaseAwaited() {
+		return false
+	}
+	mp.debugf("signaled, sockets ready")
+	mp.descriptorsReleased <- true
+	return true
+}
+
+// forwards reports whether the signal is forwarded
+// to the program, see Config.ForwardSignals
+func (mp *master) forwards(s os.Signal) bool {
+	if s == SIGTERM || s == os.Interrupt {
+		return true
+	}
+	if containsSignal(mp.Config.IgnoreSignals, s) {
+		return false
+	}
+	return mp.Config.ForwardSignals == nil || containsSignal(mp.Config.ForwardSignals, s)
+}
+
+func containsSignal(signals []os.Signal, s os.Signal) bool {
+	for _, t := range signals {
+		if t == s {
+			return true
+		}
+	}
+	return false
+}
+
+// activeID returns the id of the active program, it is read
+// by the admin, metrics and sampling goroutines
+func (mp *master) activeID() int {
+	return int(atomic.LoadInt32(&mp.activeSlaveID))
+}
+
+// activeProc returns the process of the active program, if any,
+// it is read by the admin, metrics and sampling goroutines
+func (mp *master) activeProc() *os.Process {
+	p, _ := mp.slaveProc.Load().(*os.Process)
+	return p
+}
+
+// isStopping reports whether the master has been asked to stop, it is
+// read by the goroutines supervising the program and its replicas
+func (mp *master) isStopping() bool {
+	return atomic.LoadInt32(&mp.stopping) == 1
+}
+
+// setStopping marks the master as stopping,
+// reporting whether it was not already
+func (mp *master) setStopping() bool {
+	return atomic.CompareAndSwapInt32(&mp.stopping, 0, 1)
+}
+
 func (mp *master) sendSignal(s os.Signal) {
-	if mp.slaveCmd != nil && mp.slaveCmd.Process != nil {
-		if err := mp.slaveCmd.Process.Signal(s); err != nil {
+	if mp.activeProc() != nil {
+		if err := mp.signalSlave(s); err != nil {
+			if mp.isReplacing() {
+				//exited as asked, the next is yet to start
+				mp.debugf("signal failed (%s), program is being replaced", err)
+				return
+			}
 			mp.debugf("signal failed (%s), assuming slave process died unexpectedly", err)
-			os.Exit(1)
+			mp.exit(1)
 		}
 	}
 }
 
+// signalEscalation sends the escalated signal to the slave process
+func (mp *master) signalEscalation(s os.Signal) error {
+	mp.sendSignal(s)
+	return nil
+}
+
 func (mp *master) retreiveFileDescriptors() error {
+	inherited := mp.inheritedFiles()
+	activated := mp.activatedFiles()
+	mp.launchdFiles(activated)
+	defer func() {
+		//close sockets which are no longer in use
+		for _, f := range inherited {
+			f.Close()
+		}
+		for _, f := range activated {
+			f.Close()
+		}
+	}()
+	if mp.Config.ReusePort {
+		//each program binds its own sockets
+		return nil
+	}
 	mp.slaveExtraFiles = make([]*os.File, len(mp.Config.Addresses))
 	for i, addr := range mp.Config.Addresses {
-		a, err := net.ResolveTCPAddr("tcp", addr)
-		if err != nil {
-			return fmt.Errorf("Invalid address %s (%s)", addr, err)
-		}
-		l, err := net.ListenTCP("tcp", a)
+		f, err := mp.socketFile(addr, inherited, activated)
 		if err != nil {
 			return err
 		}
-		f, err := l.File()
-		if err != nil {
-			return fmt.Errorf("Failed to retreive fd for: %s (%s)", addr, err)
-		}
-		if err := l.Close(); err != nil {
-			return fmt.Errorf("Failed to close listener for: %s (%s)", addr, err)
-		}
 		mp.slaveExtraFiles[i] = f
 	}
+	if err := mp.bindPrograms(inherited, activated); err != nil {
+		return err
+	}
+	mp.inheritDynamic(inherited)
 	return nil
 }
 
+// socketFile returns the inherited or activated socket
+// of the address, binding it otherwise
+func (mp *master) socketFile(addr string, inherited, activated map[string]*os.File) (*os.File, error) {
+	if f, ok := inherited[addr]; ok {
+		delete(inherited, addr)
+		if network, address := parseAddress(addr); network == "unix" && !isAbstract(address) {
+			mp.socketPaths = append(mp.socketPaths, address)
+		}
+		return f, nil
+	}
+	if f, ok := activated[addr]; ok {
+		//systemd manages the socket
+		delete(activated, addr)
+		return f, nil
+	}
+	f, err := mp.listen(addr)
+	if err != nil {
+		return nil, failed(ErrBindFailed, "Failed to retreive fd for: %s (%w)", addr, err)
+	}
+	return f, nil
+}
+
 //fetchLoop is run in a goroutine
 func (mp *master) fetchLoop() {
-	min := mp.Config.MinFetchInterval
-	time.Sleep(min)
-	for {
+	mp.fetchDelay(mp.minFetchInterval())
+	for mp.cancelled() == nil {
+		min := mp.minFetchInterval()
 		t0 := time.Now()
-		mp.fetch()
+		err := mp.fetch()
+		mp.fetched(err)
 		//duration fetch of fetch
 		diff := time.Now().Sub(t0)
 		if diff < min {
 			delay := min - diff
 			//ensures at least MinFetchInterval delay.
 			//should be throttled by the fetcher!
-			time.Sleep(delay)
+			mp.fetchDelay(delay)
 		}
 	}
 }
 
-func (mp *master) fetch() {
-	if mp.restarting {
-		return //skip if restarting
+// fetchDelay sleeps for d, unless an immediate fetch is requested
+func (mp *master) fetchDelay(d time.Duration) {
+	select {
+	case <-time.After(d):
+	case <-mp.fetchWake:
+	case <-mp.done():
+	}
+}
+
+// triggerFetch wakes the fetch loop, see fetchNow
+func (mp *master) triggerFetch() error {
+	return mp.fetchNow(context.Background())
+}
+
+// fetchNow wakes the fetch loop and waits for the result of the
+// next completed fetch, or until ctx is done, while the fetch
+// continues regardless
+func (mp *master) fetchNow(ctx context.Context) error {
+	if mp.Config.Fetcher == nil {
+		return ErrFetcherDisabled
+	}
+	if mp.isPaused() {
+		return ErrUpgradesPaused
+	}
+	done := make(chan error, 1)
+	mp.fetchMux.Lock()
+	mp.fetchWaiters = append(mp.fetchWaiters, done)
+	mp.fetchMux.Unlock()
+	mp.fetchDebugf("fetch requested")
+	//skip the fetcher's own polling delay
+	if w, ok := mp.Config.Fetcher.(fetcher.Waker); ok {
+		w.Wake()
+	}
+	//skip the minimum fetch interval
+	select {
+	case mp.fetchWake <- true:
+	default:
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-mp.done():
+		return mp.cancelled()
+	}
+}
+
+// fetched notifies any triggerFetch callers and
+// the FetchError hook of the fetch result
+func (mp *master) fetched(err error) {
+	skipped := err == ErrFetchSkipped || err == ErrUpgradesPaused
+	if err != nil && !skipped && mp.Config.FetchError != nil {
+		mp.Config.FetchError(err)
+	}
+	mp.fetchMux.Lock()
+	waiters := mp.fetchWaiters
+	mp.fetchWaiters = nil
+	mp.fetchMux.Unlock()
+	for _, w := range waiters {
+		w <- err
+	}
+}
+
+// fetch streams the binary of the fetcher to the temp binary, hashing it
+// as it is written, so that it is never held in memory. Delta patches are
+// spooled to a temp file by the Manifest fetcher for the same reason. Once
+// verified, the temp binary replaces the current binary.
+func (mp *master) fetch() (err error) {
+	if mp.isRestarting() {
+		return ErrFetchSkipped //skip if restarting
+	}
+	if mp.isPaused() {
+		return ErrUpgradesPaused //skip if paused
 	}
+	defer func() {
+		mp.metricCounts.fetch(err)
+	}()
 	if mp.printCheckUpdate {
-		mp.debugf("checking for updates...")
+		mp.fetchDebugf("checking for updates...")
+	}
+	started := time.Now()
+	reader, err := mp.fetchNext()
+	if err != nil && mp.cancelled() != nil {
+		return nil //see RunContext
 	}
-	reader, err := mp.Fetcher.Fetch()
 	if err != nil {
-		mp.debugf("failed to get latest version: %s", err)
-		return
+		mp.fetchDebugf("failed to get latest version: %s", err)
+		err = failed(ErrFetchFailed, "failed to get latest version: %w", err)
+		mp.emit(Event{Type: EventFetch, Error: err.Error(), Duration: time.Since(started)})
+		return err
 	}
 	if reader == nil {
 		if mp.printCheckUpdate {
-			mp.debugf("no updates")
+			mp.fetchDebugf("no updates")
 		}
 		mp.printCheckUpdate = false
-		return //fetcher has explicitly said there are no updates
+		return nil //fetcher has explicitly said there are no updates
 	}
 	mp.printCheckUpdate = true
-	mp.debugf("streaming update...")
+	mp.fetchDebugf("streaming update...")
+	//record the outcome of each stage
+	event := Event{Type: EventFetch}
+	event.PrevHash, _ = mp.binary()
+	var size int64
+	if d, ok := reader.(fetcher.Describer); ok {
+		event.Version = d.Info().Version
+		event.Source = d.Info().Source
+		event.Notes = d.Info().Notes
+		size = d.Info().Size
+	}
+	upgrade := mp.startSpan(nil, "overseer.upgrade")
+	if event.Version != "" {
+		upgrade.SetAttribute("overseer.version", event.Version)
+	}
+	upgrade.SetAttribute("overseer.source", event.Source)
+	span := mp.startSpan(upgrade, "overseer.fetch")
+	//ends the current stage, starting the next
+	stage := func(name string) {
+		span.End(nil)
+		span = mp.startSpan(upgrade, name)
+	}
+	defer func() {
+		if err != nil {
+			event.Error = err.Error()
+			event.Duration = time.Since(started)
+			mp.emit(event)
+		}
+		span.End(err)
+		upgrade.End(err)
+	}()
 	//optional closer
 	if closer, ok := reader.(io.Closer); ok {
 		defer closer.Close()
 	}
-	tmpBin, err := os.OpenFile(tmpBinPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	//preflight
+	if err := mp.checkDiskSpace(reader); err != nil {
+		mp.warnf("%s", err)
+		return err
+	}
+	tmpPath := mp.tmpBinPath
+	var tmpBin, memBin *os.File
+	if mp.Config.InMemory {
+		tmpBin, err = memfd(filepath.Base(mp.tmpBinPath))
+	} else {
+		tmpBin, err = os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+	}
 	if err != nil {
-		mp.warnf("failed to open temp binary: %s", err)
-		return
+		return mp.warnFailed(ErrFetchFailed, "failed to open temp binary: %w", err)
 	}
 	defer func() {
 		tmpBin.Close()
-		os.Remove(tmpBinPath)
+		if memBin != nil {
+			memBin.Close()
+		} else {
+			os.Remove(tmpPath)
+		}
 	}()
-	//tee off to sha1
+	//tee off to sha1 and sha256
 	hash := sha1.New()
-	reader = io.TeeReader(reader, hash)
+	hash256 := sha256.New()
+	progress := mp.trackFetch(reader, event, size)
+	reader = io.TeeReader(progress, io.MultiWriter(hash, hash256))
 	//write to a temp file
-	_, err = io.Copy(tmpBin, reader)
+	n, err := io.Copy(tmpBin, reader)
+	progress.finish(err)
 	if err != nil {
-		mp.warnf("failed to write temp binary: %s", err)
-		return
+		return mp.warnFailed(ErrFetchFailed, "failed to write temp binary: %w", err)
 	}
 	//compare hash
 	newHash := hash.Sum(nil)
-	if bytes.Equal(mp.binHash, newHash) {
-		mp.debugf("hash match - skip")
-		return
+	span.SetAttribute("overseer.bytes", n)
+	if bytes.Equal(mp.currentHash(), newHash) {
+		mp.fetchDebugf("hash match - skip")
+		span.SetAttribute("overseer.skipped", "hash match")
+		return nil
+	}
+	if mp.isRejected(newHash) {
+		mp.fetchDebugf("hash rejected - skip")
+		span.SetAttribute("overseer.skipped", "rolled back")
+		return nil
 	}
+	event.Hash = hex.EncodeToString(hash256.Sum(nil))
+	event.Size = n
+	event.Duration = time.Since(started)
+	mp.emit(event)
+	upgrade.SetAttribute("overseer.hash", event.Hash)
+	event.Type = EventVerify
+	stage("overseer.verify")
+	started = time.Now()
 	//copy permissions
 	if err := chmod(tmpBin, mp.binPerms); err != nil {
-		mp.warnf("failed to make temp binary executable: %s", err)
-		return
+		return mp.warnErr("failed to make temp binary executable: %w", err)
 	}
-	if err := chown(tmpBin, uid, gid); err != nil {
-		mp.warnf("failed to change owner of binary: %s", err)
-		return
+	if err := chown(tmpBin, mp.binUID, mp.binGID); err != nil {
+		return mp.warnErr("failed to change owner of binary: %w", err)
 	}
 	if _, err := tmpBin.Stat(); err != nil {
-		mp.warnf("failed to stat temp binary: %s", err)
-		return
+		return mp.warnErr("failed to stat temp binary: %w", err)
+	}
+	if mp.Config.InMemory {
+		//executables cannot be open for writing, so
+		//swap to a read-only descriptor of the memfd
+		if memBin, err = os.Open(memfdPath(tmpBin)); err != nil {
+			return mp.warnErr("failed to reopen temp binary: %w", err)
+		}
+		tmpPath = memfdPath(memBin)
 	}
 	tmpBin.Close()
-	if _, err := os.Stat(tmpBinPath); err != nil {
-		mp.warnf("failed to stat temp binary by path: %s", err)
-		return
+	if _, err := os.Stat(tmpPath); err != nil {
+		return mp.warnErr("failed to stat temp binary by path: %w", err)
 	}
 	if mp.Config.PreUpgrade != nil {
-		if err := mp.Config.PreUpgrade(tmpBinPath); err != nil {
-			mp.warnf("user cancelled upgrade: %s", err)
-			return
+		if err := mp.Config.PreUpgrade(tmpPath); err != nil {
+			return mp.warnFailed(ErrUpgradeRejected, "user cancelled upgrade: %w", err)
+		}
+	}
+	if mp.Config.PreUpgradeInfo != nil {
+		if err := mp.Config.PreUpgradeInfo(event.upgrade(tmpPath)); err != nil {
+			return mp.warnFailed(ErrUpgradeRejected, "user cancelled upgrade: %w", err)
 		}
 	}
 	//overseer sanity check, dont replace our good binary with a non-executable file
-	tokenIn := token()
-	cmd := exec.Command(tmpBinPath)
-	cmd.Env = append(os.Environ(), []string{envBinCheck + "=" + tokenIn}...)
-	cmd.Args = os.Args
-	returned := false
-	go func() {
-		time.Sleep(5 * time.Second)
-		if !returned {
-			mp.warnf("sanity check against fetched executable timed-out, check overseer is running")
-			if cmd.Process != nil {
-				cmd.Process.Kill()
-			}
+	stage("overseer.sanity_check")
+	if err := mp.runSanityCheck(tmpPath); err != nil {
+		mp.warnf("%s", err)
+		return err
+	}
+	event.Duration = time.Since(started)
+	mp.emit(event)
+	event.Type = EventUpgrade
+	stage("overseer.install")
+	started = time.Now()
+	//wait for the fleet
+	if err := mp.permitUpgrade(newHash); err != nil {
+		return mp.warnFailed(ErrUpgradeRejected, "upgrade not permitted: %w", err)
+	}
+	//wait our turn, unless the lock is still held for a deferred restart
+	unlock := mp.takeUpgradeLock()
+	if unlock == nil {
+		if unlock, err = mp.lockUpgrade(); err != nil {
+			return mp.warnErr("failed to acquire upgrade lock: %w", err)
 		}
-	}()
-	tokenOut, err := cmd.CombinedOutput()
-	returned = true
-	if err != nil {
-		mp.warnf("failed to run temp binary: %s (%s) output \"%s\"", err, tmpBinPath, tokenOut)
-		return
 	}
-	if tokenIn != string(tokenOut) {
-		mp.warnf("sanity check failed")
-		return
+	defer func() {
+		if unlock != nil {
+			unlock()
+		}
+	}()
+	if err := mp.backupBinary(); err != nil {
+		return mp.warnErr("failed to back up binary: %w", err)
 	}
 	//overwrite!
-	if err := overwrite(mp.binPath, tmpBinPath); err != nil {
-		mp.warnf("failed to overwrite binary: %s", err)
-		return
+	if mp.Config.InMemory {
+		mp.binMux.Lock()
+		if mp.memBin != nil && !mp.prevBin.runs(mp.memBin) {
+			mp.memBin.Close()
+		}
+		mp.memBin, memBin = memBin, nil
+		mp.binMux.Unlock()
+	} else if err := overwrite(mp.binPath, tmpPath); err != nil {
+		return mp.warnErr("failed to overwrite binary: %w", err)
 	}
-	mp.debugf("upgraded binary (%x -> %x)", mp.binHash[:12], newHash[:12])
+	mp.debugf("upgraded binary (%x -> %x)", mp.currentHash()[:12], newHash[:12])
+	defer mp.cleanup()
+	mp.binMux.Lock()
 	mp.binHash = newHash
+	mp.binSHA256 = hash256.Sum(nil)
+	mp.binVersion = event.Version
+	mp.binMux.Unlock()
+	event.Duration = time.Since(started)
+	mp.emit(event)
+	if mp.Config.PostUpgrade != nil {
+		mp.Config.PostUpgrade(event.upgrade(mp.binPath))
+	}
+	mp.probationMux.Lock()
+	mp.probationHash = newHash
+	mp.probationMux.Unlock()
+	if mp.Config.SelfUpgrade {
+		//the next restart will re-execute the master
+		mp.restartMux.Lock()
+		mp.reexecPending = true
+		mp.reexecUnlock = unlock
+		mp.restartMux.Unlock()
+	}
 	//binary successfully replaced
-	if !mp.Config.NoRestartAfterFetch {
-		mp.triggerRestart()
+	span.End(nil)
+	span = noSpan{}
+	//the following restart releases the upgrade lock,
+	//though it is deferred by Pause or NoRestartAfterFetch
+	mp.holdUpgradeLock(unlock)
+	unlock = nil
+	if !mp.noRestartAfterFetch() && !mp.deferRestart() {
+		mp.restartMux.Lock()
+		mp.restartSpan = upgrade
+		mp.restartMux.Unlock()
+		if mp.triggerRestart() == RestartIgnored {
+			mp.releaseUpgradeLock()
+		}
+		//hold the upgrade lock until restarted
+		mp.awaitRestart()
 	}
 	//and keep fetching...
-	return
+	return nil
+}
+
+// setPaused pauses or resumes automatic fetches and restarts.
+// An upgrade applied while pausing is restarted on resume.
+func (mp *master) setPaused(paused bool) error {
+	mp.pauseMux.Lock()
+	if mp.paused == paused {
+		mp.pauseMux.Unlock()
+		return nil
+	}
+	mp.paused = paused
+	restart := !paused && mp.pausedRestart
+	mp.pausedRestart = false
+	mp.pauseMux.Unlock()
+	mp.statusChanged()
+	if paused {
+		mp.debugf("upgrades paused")
+	} else {
+		mp.debugf("upgrades resumed")
+	}
+	if restart {
+		mp.debugf("performing deferred restart")
+		mp.triggerRestart()
+	}
+	return nil
+}
+
+func (mp *master) isPaused() bool {
+	mp.pauseMux.Lock()
+	defer mp.pauseMux.Unlock()
+	return mp.paused
+}
+
+// deferRestart returns true if upgrades are paused,
+// marking a restart to be performed on resume
+func (mp *master) deferRestart() bool {
+	mp.pauseMux.Lock()
+	defer mp.pauseMux.Unlock()
+	if mp.paused {
+		mp.debugf("upgrades paused, restart deferred")
+		mp.pausedRestart = true
+	}
+	return mp.paused
 }
 
-func (mp *master) triggerRestart() {
-	if mp.restarting {
-		mp.debugf("already graceful restarting")
-		return //skip
-	} else if mp.slaveCmd == nil || mp.restarting {
+// triggerRestart requests a graceful restart. Requests which
+// arrive while a restart is in progress are coalesced into a
+// single follow-up restart, performed once the current one has
+// completed.
+func (mp *master) triggerRestart() RestartStatus {
+	mp.restartMux.Lock()
+	defer mp.restartMux.Unlock()
+	if mp.restartActive {
+		if mp.restartQueued {
+			mp.debugf("restart already queued")
+			return RestartCoalesced
+		}
+		mp.debugf("already graceful restarting, queued another")
+		mp.restartQueued = true
+		return RestartQueued
+	} else if mp.activeProc() == nil {
 		mp.debugf("no slave process")
-		return //skip
+		return RestartIgnored
+	}
+	mp.restartActive = true
+	go mp.restartLoop()
+	return RestartStarted
+}
+
+// requestRestart is a restart requested by the program, see State.RequestRestart
+func (mp *master) requestRestart(reason string) RestartStatus {
+	if reason != "" {
+		mp.warnf("restart requested by program (%s)", reason)
+	} else {
+		mp.debugf("restart requested by program")
+	}
+	mp.restartMux.Lock()
+	if mp.restartReason == "" {
+		mp.restartReason = reason
+	}
+	mp.restartMux.Unlock()
+	return mp.triggerRestart()
+}
+
+// takeRestartReason returns the reason of the requested restart, if any
+func (mp *master) takeRestartReason() string {
+	mp.restartMux.Lock()
+	defer mp.restartMux.Unlock()
+	reason := mp.restartReason
+	mp.restartReason = ""
+	return reason
+}
+
+// restartLoop performs restarts until the queue is empty
+func (mp *master) restartLoop() {
+	for {
+		unlock := mp.takeUpgradeLock()
+		mp.holdDown()
+		mp.reexec()
+		mp.notify("RELOADING=1")
+		mp.restart()
+		mp.rollReplicas()
+		mp.notify("READY=1")
+		if unlock != nil {
+			unlock()
+		}
+		mp.restartMux.Lock()
+		if !mp.restartQueued {
+			mp.restartActive = false
+			mp.restartIdle.Broadcast()
+			mp.restartMux.Unlock()
+			return
+		}
+		mp.restartQueued = false
+		mp.restartMux.Unlock()
+		//give the new slave time to install its
+		//signal handlers before asking it to exit
+		time.Sleep(restartSettle)
+	}
+}
+
+// holdDown delays the restart until MinRestartInterval
+// has passed since the previous restart completed
+func (mp *master) holdDown() {
+	mp.restartMux.Lock()
+	wait := mp.Config.MinRestartInterval - time.Since(mp.restartedAt)
+	if mp.restartedAt.IsZero() || mp.restartKill {
+		wait = 0
+	}
+	mp.restartMux.Unlock()
+	if wait > 0 {
+		mp.debugf("restart held down for %s", wait.Round(time.Millisecond))
+		time.Sleep(wait)
+		//the held restart covers those triggered meanwhile
+		mp.restartMux.Lock()
+		mp.restartQueued = false
+		mp.restartMux.Unlock()
+	}
+}
+
+func (mp *master) restart() {
+	if mp.Config.ReusePort {
+		mp.restartReusePort()
+		return
 	}
 	mp.debugf("graceful restart triggered")
+	mp.restartMux.Lock()
 	mp.restarting = true
 	mp.awaitingUSR1 = true
 	mp.signalledAt = time.Now()
-	mp.sendSignal(mp.Config.RestartSignal) //ask nicely to terminate
-	select {
-	case <-mp.restarted:
+	mp.releasedAt = time.Time{}
+	kill := mp.takeRestartKill()
+	parent := mp.restartSpan
+	mp.restartSpan = nil
+	mp.restartMux.Unlock()
+	span := mp.startSpan(parent, "overseer.restart")
+	mp.startDrain(parent, mp.activeID())
+	started := time.Now()
+	if kill {
+		mp.sendSignal(os.Kill)
+	} else {
+		mp.sendSignal(mp.Config.RestartSignal) //ask nicely to terminate
+	}
+	event := Event{Type: EventRestart, Reason: mp.takeRestartReason()}
+	//times up mr. process, we did ask nicely! the replacement
+	//is started once the escalated process has been reaped
+	if sig := mp.escalate(mp.signalEscalation, mp.restarted); sig != nil {
+		event.Error = escalated(sig)
+	} else {
 		//success
 		mp.debugf("restart success")
+	}
+	mp.awaitReady(mp.activeID())
+	event.SlaveID = mp.slaveID
+	event.Hash, event.Version = mp.binary()
+	event.Duration = time.Since(started)
+	mp.restartMux.Lock()
+	if released := mp.releasedAt; !released.IsZero() {
+		//connections queued unaccepted since
+		event.Handover = time.Since(released)
+	}
+	mp.restartMux.Unlock()
+	mp.emit(event)
+	mp.endRestartSpan(span, event)
+}
+
+// restartReusePort starts the next program alongside the previous
+// one, which is asked to terminate once the next has bound its sockets
+func (mp *master) restartReusePort() {
+	mp.debugf("graceful restart triggered")
+	prev := mp.activeProc()
+	select {
+	case <-mp.slaveBound: //discard previous notifications
+	default:
+	}
+	mp.restartMux.Lock()
+	mp.restarting = true
+	mp.signalledAt = time.Now()
+	signal := mp.Config.RestartSignal
+	if mp.takeRestartKill() {
+		signal = os.Kill
+	}
+	parent := mp.restartSpan
+	mp.restartSpan = nil
+	mp.restartMux.Unlock()
+	span := mp.startSpan(parent, "overseer.restart")
+	prevID := mp.activeID()
+	//start the next program now, the previous
+	//program's exit will be discarded
+	mp.descriptorsReleased <- true
+	started := time.Now()
+	<-mp.restarted
+	event := Event{Type: EventRestart, Reason: mp.takeRestartReason()}
+	select {
+	case <-mp.slaveBound:
+		mp.debugf("restart success")
 	case <-time.After(mp.TerminateTimeout):
-		//times up mr. process, we did ask nicely!
-		mp.debugf("graceful timeout, forcing exit")
-		mp.sendSignal(os.Kill)
+		mp.debugf("program did not bind its sockets in time")
+		event.Error = "program did not bind its sockets in time"
+	}
+	mp.awaitReady(mp.activeID())
+	mp.startDrain(parent, prevID)
+	if err := prev.Signal(signal); err != nil {
+		mp.debugf("signal failed (%s), assuming previous program exited", err)
+	}
+	//the signals fail once the program has exited
+	go mp.escalate(prev.Signal, nil)
+	event.SlaveID = mp.slaveID
+	event.Hash, event.Version = mp.binary()
+	event.Duration = time.Since(started)
+	mp.emit(event)
+	mp.endRestartSpan(span, event)
+}
+
+// isRestarting reports whether a restart is in progress or queued
+func (mp *master) isRestarting() bool {
+	mp.restartMux.Lock()
+	defer mp.restartMux.Unlock()
+	return mp.restartActive
+}
+
+// awaitRestart blocks until any restarts in progress have completed
+func (mp *master) awaitRestart() {
+	mp.restartMux.Lock()
+	defer mp.restartMux.Unlock()
+	for mp.restartActive {
+		mp.restartIdle.Wait()
+	}
+}
+
+// isReplacing reports whether the slave has been asked to exit
+// and a replacement should be started
+func (mp *master) isReplacing() bool {
+	mp.restartMux.Lock()
+	defer mp.restartMux.Unlock()
+	return mp.restarting
+}
+
+// args is the command line of the program, see Config.Args
+func (mp *master) args() []string {
+	args := append([]string{}, os.Args...)
+	if mp.Config.Args != nil {
+		args = mp.Config.Args(args)
 	}
+	return args
 }
 
 //not a real fork
@@ -342,61 +1119,216 @@ func (mp *master) forkLoop() error {
 }
 
 func (mp *master) fork() error {
-	mp.debugf("starting %s", mp.binPath)
-	cmd := exec.Command(mp.binPath)
+	if mp.hasExited() {
+		return errExited
+	}
+	if mp.cancelled() != nil {
+		//stopped between programs
+		mp.exit(0)
+		return errExited
+	}
+	cmd, slaveID, handoff, err := mp.start(0)
+	if err != nil {
+		return err
+	}
 	//mark this new process as the "active" slave process.
 	//this process is assumed to be holding the socket files.
-	mp.slaveCmd = cmd
-	mp.slaveID++
+	mp.slaveProc.Store(cmd.Process)
+	atomic.StoreInt32(&mp.activeSlaveID, int32(slaveID))
+	mp.metricCounts.started()
+	mp.programStarted(cmd.Process.Pid)
+	if handoff != nil {
+		mp.handoffTo(handoff)
+	}
+	return mp.supervise(slaveID, cmd.Wait)
+}
+
+// start starts the program, one of its replicas, or one
+// of Config.Programs, by their index among the replicas
+func (mp *master) start(replica int) (*exec.Cmd, int, *masterHandoff, error) {
+	execPath := mp.execPath()
+	mp.debugf("starting %s", execPath)
+	cmd := exec.Command(execPath)
+	slaveID := mp.nextSlaveID()
 	//provide the slave process with some state
-	e := os.Environ()
-	e = append(e, envBinID+"="+hex.EncodeToString(mp.binHash))
-	e = append(e, envBinPath+"="+mp.binPath)
-	e = append(e, envSlaveID+"="+strconv.Itoa(mp.slaveID))
+	e := mp.environ()
+	e = append(e, envBinID+"="+hex.EncodeToString(mp.currentHash()))
+	e = append(e, envBinPath+"="+execPath)
+	e = append(e, envSlaveID+"="+strconv.Itoa(slaveID))
 	e = append(e, envIsSlave+"=1")
-	e = append(e, envNumFDs+"="+strconv.Itoa(len(mp.slaveExtraFiles)))
+	e = append(e, mp.countersEnv(replica)...)
+	if mp.Config.CoreDumps && !hasEnv(e, "GOTRACEBACK") {
+		//panics abort, dumping core
+		e = append(e, "GOTRACEBACK=crash")
+	}
+	addrs, sockets, err := mp.sockets()
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("Failed to duplicate sockets: %w", err)
+	}
+	//the program has its own once started
+	defer closeFiles(sockets)
+	files := sockets
+	program := mp.replicaProgram(replica)
+	if program >= 0 {
+		e = append(e, envProgram+"="+mp.Config.Programs[program].Name)
+		files = nil
+		if mp.programFiles != nil {
+			files = append(files, mp.programFiles[program]...)
+		}
+	} else if replica > 0 {
+		e = append(e, envReplica+"="+strconv.Itoa(replica))
+	}
+	e = append(e, envNumFDs+"="+strconv.Itoa(len(files)))
+	if addrs := addrs[len(mp.Config.Addresses):]; len(addrs) > 0 && program < 0 {
+		b, _ := json.Marshal(addrs)
+		e = append(e, envDynamicAddrs+"="+string(b))
+	}
+	if len(mp.files) > 0 {
+		fds := map[string]int{}
+		for _, name := range mp.fileNames() {
+			fds[name] = int(childFD(len(files), mp.files[name]))
+			files = append(files, mp.files[name])
+		}
+		b, _ := json.Marshal(fds)
+		e = append(e, envFiles+"="+string(b))
+	}
+	peer := &controlPeer{slaveID: slaveID, readied: make(chan bool), closed: make(chan bool), metadata: mp.metadata()}
+	control, err := mp.controlPipes(peer)
+	if err != nil {
+		mp.warnf("failed to create control pipes, dynamic listeners disabled: %s", err)
+	} else if len(control) > 0 {
+		mp.addPeer(peer)
+		e = append(e, fmt.Sprintf("%s=%d,%d", envControlFDs, childFD(len(files), control[0]), childFD(len(files)+1, control[1])))
+		files = append(files, control...)
+	}
+	sigEnv, signals, err := mp.signalPipes(len(files))
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("Failed to create signal pipes: %w", err)
+	}
+	e = append(e, sigEnv...)
+	files = append(files, signals...)
+	handoff, err := mp.handoffSocket()
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("Failed to create handoff socket: %w", err)
+	}
+	if handoff != nil {
+		e = append(e, fmt.Sprintf("%s=%d", envHandoffFD, childFD(len(files), handoff.child)))
+		files = append(files, handoff.child)
+	}
 	cmd.Env = e
 	//inherit master args/stdfiles
-	cmd.Args = os.Args
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Args = mp.args()
+	cmd.Dir = mp.Config.Dir
+	stdin, err := mp.openStdin()
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("Failed to open stdin: %w", err)
+	}
+	cmd.Stdin = stdin
+	stdout, stderr, closeOutput, err := mp.programOutput(slaveID)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("Failed to create output pipes: %w", err)
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	//include socket files
-	cmd.ExtraFiles = mp.slaveExtraFiles
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("Failed to start slave process: %s", err)
+	restore := passFiles(cmd, files)
+	mp.confine(cmd)
+	attached, err := mp.attachTerminal(cmd)
+	if err != nil {
+		restore()
+		closeOutput()
+		return nil, 0, nil, fmt.Errorf("Failed to create terminal: %w", err)
+	}
+	err = cmd.Start()
+	restore()
+	attached(err)
+	closeOutput()
+	if stdin != os.Stdin {
+		stdin.Close()
 	}
+	//the slave's ends of the pipes
+	for _, f := range append(control, signals...) {
+		f.Close()
+	}
+	if err != nil {
+		mp.takeStderr(slaveID)
+		return nil, 0, nil, fmt.Errorf("Failed to start slave process: %w", err)
+	}
+	mp.joinCGroup(cmd.Process.Pid)
+	mp.joinJob(cmd.Process)
+	if replica == 0 && len(control) > 0 {
+		go mp.startupDeadline(peer)
+	}
+	return cmd, slaveID, handoff, nil
+}
+
+// nextSlaveID numbers the next program started
+func (mp *master) nextSlaveID() int {
+	mp.replicaMux.Lock()
+	defer mp.replicaMux.Unlock()
+	mp.slaveID++
+	return mp.slaveID
+}
+
+// supervise waits for the active slave process to
+// either exit or release its socket files
+func (mp *master) supervise(slaveID int, wait func() error) error {
+	pid := mp.activeProc().Pid
+	mp.startProbation(slaveID)
 	//was scheduled to restart, notify success
-	if mp.restarting {
+	mp.restartMux.Lock()
+	replaced := mp.restarting
+	if replaced {
 		mp.restartedAt = time.Now()
 		mp.restarting = false
+		mp.awaitingUSR1 = false
+	}
+	mp.restartMux.Unlock()
+	if replaced {
 		mp.restarted <- true
+	} else {
+		//the sockets are bound and the first program is running,
+		//restarts notify once they have completed
+		mp.startReplicas()
+		go func() {
+			if mp.awaitReady(slaveID) {
+				mp.notify("READY=1")
+			}
+		}()
 	}
 	//convert wait into channel
-	cmdwait := make(chan error)
+	cmdwait := make(chan error, 1)
 	go func() {
-		cmdwait <- cmd.Wait()
+		cmdwait <- wait()
 	}()
 	//wait....
 	select {
 	case err := <-cmdwait:
 		//program exited before releasing descriptors
 		//proxy exit code out to master
-		code := 0
-		if err != nil {
-			code = 1
-			if exiterr, ok := err.(*exec.ExitError); ok {
-				if status, ok := exiterr.Sys().(syscall.WaitStatus); ok {
-					code = status.ExitStatus()
-				}
-			}
-		}
+		exit := mp.exitStatus(slaveID, pid, err)
+		code := exit.Code
 		mp.debugf("prog exited with %d", code)
 		//if a restarts are disabled or if it was an
 		//unexpected crash, proxy this exit straight
 		//through to the main process
-		if mp.NoRestart || !mp.restarting {
-			os.Exit(code)
+		exiting := mp.NoRestart || !mp.isReplacing()
+		if exiting && code != 0 && !mp.isStopping() {
+			mp.slaveCrashed(slaveID, code)
+		}
+		if !exiting {
+			mp.exitReleased()
+		}
+		exit.Replaced, exit.Exiting = !exiting, exiting
+		mp.programExited(exit)
+		if exiting {
+			if mp.isStopping() && mp.Config.Launchd != nil {
+				//stopped by launchd, which would otherwise
+				//respawn a KeepAlive job exiting unsuccessfully
+				code = 0
+			}
+			mp.exit(code)
+			return errExited
 		}
 	case <-mp.descriptorsReleased:
 		//if descriptors are released, the program
@@ -406,22 +1338,82 @@ func (mp *master) fork() error {
 		//to ensure downtime is kept at <1sec. The previous
 		//cmd.Wait() will still be consumed though the
 		//result will be discarded.
+		go func() {
+			exit := mp.exitStatus(slaveID, pid, <-cmdwait)
+			exit.Replaced = true
+			mp.programExited(exit)
+		}()
 	}
 	return nil
 }
 
+// execPath is the path of the binary which slaves are started from
+func (mp *master) execPath() string {
+	mp.binMux.Lock()
+	defer mp.binMux.Unlock()
+	if mp.memBin != nil {
+		return memfdPath(mp.memBin)
+	}
+	return mp.binPath
+}
+
+// exit removes the socket files before exiting
+func (mp *master) exit(code int) {
+	mp.stopReplicas()
+	mp.closeHandoffs()
+	mp.removeSockets()
+	mp.removePIDFiles()
+	mp.removeStatusFile()
+	mp.releaseUpgradeLock()
+	mp.serviceStopped(code)
+	mp.restoreTerminal()
+	mp.flushEmail()
+	if mp.ctx != nil {
+		mp.returnExit(code)
+		return
+	}
+	os.Exit(code)
+}
+
+func (mp *master) tracef(f string, args ...interface{}) {
+	if mp.logs("master", LogTrace) {
+		mp.Config.logf(LogTrace, "master", f, args...)
+	}
+}
+
 func (mp *master) debugf(f string, args ...interface{}) {
-	if mp.Config.Debug {
-		log.Printf("[overseer master] "+f, args...)
+	if mp.logs("master", LogDebug) {
+		mp.Config.logf(LogDebug, "master", f, args...)
 	}
 }
 
 func (mp *master) warnf(f string, args ...interface{}) {
-	if mp.Config.Debug || !mp.Config.NoWarn {
-		log.Printf("[overseer master] "+f, args...)
+	if mp.logs("master", LogWarn) {
+		mp.Config.logf(LogWarn, "master", f, args...)
+	}
+}
+
+// fetchDebugf and fetchWarnf log the checks for updates
+// and their download, along with the logs of the Fetcher
+func (mp *master) fetchDebugf(f string, args ...interface{}) {
+	if mp.logs("fetcher", LogDebug) {
+		mp.Config.logf(LogDebug, "fetcher", f, args...)
+	}
+}
+
+func (mp *master) fetchWarnf(f string, args ...interface{}) {
+	if mp.logs("fetcher", LogWarn) {
+		mp.Config.logf(LogWarn, "fetcher", f, args...)
 	}
 }
 
+// warnErr logs the formatted error as a warning and returns it
+func (mp *master) warnErr(f string, args ...interface{}) error {
+	err := fmt.Errorf(f, args...)
+	mp.warnf("%s", err)
+	return err
+}
+
 func token() string {
 	buff := make([]byte, 8)
 	rand.Read(buff)
@@ -436,3 +1428,19 @@ func extension() string {
 
 	return ""
 }
+
+// cappedBuffer keeps the first max bytes written to it
+type cappedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (c *cappedBuffer) Write(p []byte) (int, error) {
+	if n := c.max - c.Len(); n > 0 {
+		if len(p) < n {
+			n = len(p)
+		}
+		c.Buffer.Write(p[:n])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,129 @@
+package overseer
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	envMasterFDs      = "OVERSEER_MASTER_FDS"
+	envMasterSlavePID = "OVERSEER_MASTER_SLAVE_PID"
+	envMasterSlaveID  = "OVERSEER_MASTER_SLAVE_ID"
+)
+
+// reexec replaces the master process with the upgraded binary, when
+// requested by SelfUpgrade. The socket files and the running slave are
+// handed over to the new master, which then restarts the slave. Only
+// returns if the exec failed.
+func (mp *master) reexec() {
+	mp.restartMux.Lock()
+	pending, unlock := mp.reexecPending, mp.reexecUnlock
+	mp.reexecPending, mp.reexecUnlock = false, nil
+	mp.restartMux.Unlock()
+	if !pending || mp.activeProc() == nil {
+		return
+	}
+	fds := map[string]int{}
+	addrs, sockets, err := mp.sockets()
+	if err != nil {
+		mp.warnf("failed to re-execute master, cannot pass sockets: %s", err)
+		return
+	}
+	defer closeFiles(sockets)
+	for i, f := range sockets {
+		if err := inheritable(f); err != nil {
+			mp.warnf("failed to re-execute master, cannot pass sockets: %s", err)
+			return
+		}
+		fds[addrs[i]] = int(f.Fd())
+	}
+	b, _ := json.Marshal(fds)
+	dynamic, _ := json.Marshal(addrs[len(mp.Config.Addresses):])
+	files := map[string]int{}
+	for name, f := range mp.files {
+		if err := inheritable(f); err != nil {
+			mp.warnf("failed to re-execute master, cannot pass files: %s", err)
+			return
+		}
+		files[name] = int(f.Fd())
+	}
+	fb, _ := json.Marshal(files)
+	e := []string{}
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "OVERSEER_MASTER_") {
+			e = append(e, kv)
+		}
+	}
+	e = append(e, mp.notifyEnv()...)
+	e = append(e, envMasterFDs+"="+string(b))
+	e = append(e, envMasterDynamic+"="+string(dynamic))
+	e = append(e, envMasterFiles+"="+string(fb))
+	e = append(e, envMasterSlavePID+"="+strconv.Itoa(mp.activeProc().Pid))
+	e = append(e, envMasterSlaveID+"="+strconv.Itoa(mp.slaveID))
+	//the exec never returns, so release the lock now
+	if unlock != nil {
+		unlock()
+	}
+	mp.debugf("re-executing master %s", mp.binPath)
+	err = execSelf(mp.binPath, os.Args, e)
+	mp.warnf("failed to re-execute master, restarting program instead: %s", err)
+}
+
+// inheritedFiles returns the socket files handed
+// over by the previous master, keyed by address
+func (mp *master) inheritedFiles() map[string]*os.File {
+	files := map[string]*os.File{}
+	v := os.Getenv(envMasterFDs)
+	if v == "" {
+		return files
+	}
+	os.Unsetenv(envMasterFDs)
+	fds := map[string]int{}
+	if err := json.Unmarshal([]byte(v), &fds); err != nil {
+		mp.warnf("invalid %s: %s", envMasterFDs, err)
+		return files
+	}
+	for addr, fd := range fds {
+		files[addr] = os.NewFile(uintptr(fd), addr)
+	}
+	return files
+}
+
+// adoptSlave takes ownership of the slave process
+// started by the previous master
+func (mp *master) adoptSlave() bool {
+	pid, _ := strconv.Atoi(os.Getenv(envMasterSlavePID))
+	id, _ := strconv.Atoi(os.Getenv(envMasterSlaveID))
+	os.Unsetenv(envMasterSlavePID)
+	os.Unsetenv(envMasterSlaveID)
+	if pid <= 0 {
+		return false
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		mp.warnf("failed to adopt slave process: %s", err)
+		return false
+	}
+	mp.debugf("adopted slave process (pid %d)", pid)
+	mp.slaveID = id
+	mp.slaveProc.Store(proc)
+	return true
+}
+
+// awaitAdopted supervises the adopted slave until it is replaced
+func (mp *master) awaitAdopted() error {
+	proc := mp.activeProc()
+	return mp.supervise(mp.slaveID, func() error {
+		state, err := proc.Wait()
+		if err != nil {
+			return err
+		}
+		if !state.Success() {
+			return &exec.ExitError{ProcessState: state}
+		}
+		return nil
+	})
+}
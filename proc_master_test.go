@@ -0,0 +1,45 @@
+package overseer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/menglh/overseer/fetcher"
+)
+
+func TestFetchNowSkipped(t *testing.T) {
+	hooked := false
+	mp := &master{Config: &Config{Fetcher: &fetcher.File{Path: "app"}, FetchError: func(error) { hooked = true }}}
+	mp.restartActive = true
+	errs := make(chan error, 1)
+	go func() {
+		errs <- mp.fetchNow(context.Background())
+	}()
+	//await the waiter, as the fetch loop would
+	for {
+		mp.fetchMux.Lock()
+		n := len(mp.fetchWaiters)
+		mp.fetchMux.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	mp.fetched(mp.fetch())
+	if err := <-errs; err != ErrFetchSkipped {
+		t.Errorf("got %v, expected ErrFetchSkipped", err)
+	}
+	if hooked {
+		t.Error("FetchError called for a skipped fetch")
+	}
+}
+
+func TestFetchNowTimeout(t *testing.T) {
+	mp := &master{Config: &Config{Fetcher: &fetcher.File{Path: "app"}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := mp.fetchNow(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v, expected the error of the context", err)
+	}
+}
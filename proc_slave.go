@@ -1,12 +1,15 @@
 package overseer
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"net"
 	"os"
 	"os/signal"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -32,7 +35,25 @@ type State struct {
 	//Listeners are the set of acquired sockets by the master
 	//process. These are all passed into this program in the
 	//same order they are specified in Config.Addresses.
+	//Packet addresses are nil, see PacketConns.
 	Listeners []net.Listener
+	//PacketConn is the first net.PacketConn in PacketConns
+	PacketConn net.PacketConn
+	//PacketConns are the acquired packet (UDP) sockets, in the
+	//same order they are specified in Config.Addresses. Once
+	//released for a restart, reads fail and queued packets are
+	//left for the next program. Stream addresses are nil.
+	//UDP connections provide the methods of *net.UDPConn used by
+	//QUIC implementations (ReadMsgUDP, WriteMsgUDP, SyscallConn,
+	//SetReadBuffer), so HTTP/3 servers such as quic-go may serve
+	//them alongside a "tcp://" address on the same port.
+	PacketConns []net.PacketConn
+	//SCTPFiles are the acquired SCTP ("sctp://", "sctp4://" and
+	//"sctp6://") sockets, listening one-to-one style (SOCK_STREAM),
+	//in the same order they are specified in Config.Addresses. The
+	//program must accept(2) connections itself, and stop once
+	//GracefulShutdown is closed. Other addresses are nil.
+	SCTPFiles []*os.File
 	//Program's first listening address
 	Address string
 	//Program's listening addresses
@@ -42,6 +63,143 @@ type State struct {
 	GracefulShutdown chan bool
 	//Path of the binary currently being executed
 	BinPath string
+	//Files are the files opened by the master, by their
+	//name in Config.Files
+	Files map[string]*os.File
+	//Generation is the number of this program among those started
+	//by the master, it increases with every restart
+	Generation int
+	//MasterStartedAt records the start time of the master
+	MasterStartedAt time.Time
+	//Restarts and Upgrades count the restarts of the program and the
+	//upgrades of its binary since the master started, including the
+	//restart which started this program
+	Restarts int
+	Upgrades int
+	//Metadata is passed by the master, see Config.Metadata
+	Metadata map[string]string
+	//SavedState is the state last saved by a previous program with
+	//SaveState, if any
+	SavedState []byte
+	//Replica is the index of this program among Config.Replicas,
+	//zero for the first program
+	Replica int
+	//Program is the Name of this program among Config.Programs,
+	//empty for Config.Program
+	Program string
+	//names of Config.NamedAddresses
+	names map[string]string
+	//tracks the connections accepted from Listeners
+	listeners []*overseerListener
+	//HandedOff receives the connections handed off by the previous
+	//program, with Config.ConnectionHandoff, it must then be consumed.
+	//They are counted by Connections like accepted connections.
+	//See HandOff.
+	HandedOff <-chan HandedOffConn
+	//registered by OnDrain
+	drainHooks *drainHooks
+	//the program's end of its handoff socket
+	handoff *handoff
+	//Config.DrainTimeout
+	drainTimeout time.Duration
+}
+
+// OnDrain registers fn to be called when a graceful shutdown begins,
+// before the listeners are closed, so that long-lived connections
+// can be asked to finish politely, for example by sending an HTTP/2
+// GOAWAY or closing WebSockets. Callbacks run in the order they were
+// registered and should return promptly, sockets are only released
+// to the next program once all of them have returned.
+func (s State) OnDrain(fn func()) {
+	if s.drainHooks != nil {
+		s.drainHooks.add(fn)
+	}
+}
+
+// Connections returns the number of connections accepted
+// from Listeners which are still open
+func (s State) Connections() int {
+	n := 0
+	for _, l := range s.listeners {
+		n += l.connections()
+	}
+	return n
+}
+
+// ConnectionsByAddress returns the number of connections accepted
+// from Listeners which are still open, keyed by their address.
+// After GracefulShutdown is closed, it reports the drain progress.
+func (s State) ConnectionsByAddress() map[string]int {
+	m := map[string]int{}
+	for _, l := range s.listeners {
+		m[l.addr] += l.connections()
+	}
+	return m
+}
+
+// RequestRestart asks the master for a graceful restart of the program,
+// as Restart does, for example once the program has detected that its
+// state can not be recovered otherwise. The reason is logged by the
+// master and recorded with the restart's Event.
+func (s State) RequestRestart(reason string) RestartStatus {
+	if sp, ok := currentProcess.(*slave); ok && s.Enabled {
+		return sp.requestRestart(reason)
+	}
+	return RestartIgnored
+}
+
+// SaveState passes a small blob of the program's state, such as
+// session keys or counters, to the master, which hands it to the
+// programs started next in State.SavedState, so that their state
+// is warm after an upgrade. It is typically called from OnDrain, as
+// the sockets are only released to the next program once the drain
+// callbacks have returned. The state is kept in the master's memory,
+// up to 32KB, and replaced by each call. With ReusePort, the next
+// program is started before the previous one drains.
+func (s State) SaveState(data []byte) error {
+	if sp, ok := currentProcess.(*slave); ok && s.Enabled {
+		return sp.saveState(data)
+	}
+	return ErrNotRunning
+}
+
+// Ready tells the master the program has initialized and is ready to
+// serve, see Config.WaitReady. Only the first call has an effect.
+func (s State) Ready() {
+	if sp, ok := currentProcess.(*slave); ok && s.Enabled {
+		sp.notifyReady()
+	}
+}
+
+// NamedListener returns the listener of the address with the
+// given name in Config.NamedAddresses, or nil if there is none
+func (s State) NamedListener(name string) net.Listener {
+	if i := s.namedIndex(name); i >= 0 && i < len(s.Listeners) {
+		return s.Listeners[i]
+	}
+	return nil
+}
+
+// NamedPacketConn returns the packet connection of the address with
+// the given name in Config.NamedAddresses, or nil if there is none
+func (s State) NamedPacketConn(name string) net.PacketConn {
+	if i := s.namedIndex(name); i >= 0 && i < len(s.PacketConns) {
+		return s.PacketConns[i]
+	}
+	return nil
+}
+
+func (s State) namedIndex(name string) int {
+	addr, ok := s.names[name]
+	if !ok {
+		return -1
+	}
+	for i, a := range s.Addresses {
+		if a == addr {
+			return i
+		}
+	}
+	return -1
 }
 
 //a overseer slave process
@@ -50,28 +208,68 @@ type slave struct {
 	*Config
 	id         string
 	listeners  []*overseerListener
+	packets    []*overseerPacketConn
+	sctp       []*os.File
 	masterPid  int
 	masterProc *os.Process
 	state      State
+	controlMux sync.Mutex
+	controlW   *os.File
+	controlR   *bufio.Reader
+	requests   *os.File
+	//ctx is that of RunContext, nil for Run
+	ctx context.Context
+	//controlVersion is spoken by the master, see helloMaster
+	controlVersion int
+	readyOnce      sync.Once
 }
 
 func (sp *slave) run() error {
 	sp.id = os.Getenv(envSlaveID)
+	sp.state.Generation, _ = strconv.Atoi(sp.id)
+	sp.state.Replica, _ = strconv.Atoi(os.Getenv(envReplica))
+	if err := sp.selectProgram(); err != nil {
+		return err
+	}
 	sp.debugf("run")
 	sp.state.Enabled = true
 	sp.state.ID = os.Getenv(envBinID)
 	sp.state.StartedAt = time.Now()
+	sp.state.MasterStartedAt, _ = time.Parse(time.RFC3339Nano, os.Getenv(envMasterStarted))
+	sp.state.Restarts, _ = strconv.Atoi(os.Getenv(envRestarts))
+	sp.state.Upgrades, _ = strconv.Atoi(os.Getenv(envUpgrades))
 	sp.state.Address = sp.Config.Address
-	sp.state.Addresses = sp.Config.Addresses
+	sp.state.Addresses = append(append([]string{}, sp.Config.Addresses...), dynamicAddresses()...)
+	sp.state.names = sp.Config.NamedAddresses
 	sp.state.GracefulShutdown = make(chan bool, 1)
+	sp.state.drainHooks = &drainHooks{}
+	sp.state.drainTimeout = sp.Config.DrainTimeout
 	sp.state.BinPath = os.Getenv(envBinPath)
 	if err := sp.watchParent(); err != nil {
 		return err
 	}
+	if err := sp.setLimits(); err != nil {
+		return err
+	}
+	sp.openControl()
+	publishVars(sp.vars)
 	if err := sp.initFileDescriptors(); err != nil {
 		return err
 	}
+	if err := sp.openHandoff(); err != nil {
+		return err
+	}
+	if err := sp.inheritFiles(); err != nil {
+		return err
+	}
+	if err := sp.wrapTLS(); err != nil {
+		return err
+	}
+	sp.state.listeners = sp.listeners
 	sp.watchSignal()
+	if !sp.Config.WaitReady {
+		sp.notifyReady()
+	}
 	//run program with state
 	sp.debugf("start program")
 	sp.Config.Program(sp.state)
@@ -79,75 +277,291 @@ func (sp *slave) run() error {
 }
 
 func (sp *slave) initFileDescriptors() error {
+	if sp.Config.ReusePort {
+		return sp.bindSockets()
+	}
 	//inspect file descriptors
 	numFDs, err := strconv.Atoi(os.Getenv(envNumFDs))
 	if err != nil {
 		return fmt.Errorf("invalid %s integer", envNumFDs)
 	}
-	sp.listeners = make([]*overseerListener, numFDs)
 	sp.state.Listeners = make([]net.Listener, numFDs)
+	sp.state.PacketConns = make([]net.PacketConn, numFDs)
+	sp.state.SCTPFiles = make([]*os.File, numFDs)
 	for i := 0; i < numFDs; i++ {
-		f := os.NewFile(uintptr(3+i), "")
+		f := os.NewFile(inheritedFD(i), "")
+		network := "tcp"
+		var options *ListenOptions
+		if i < len(sp.state.Addresses) {
+			network, _ = parseAddress(sp.state.Addresses[i])
+			options = sp.Config.ListenOptions[sp.state.Addresses[i]]
+		}
+		if isSCTPNetwork(network) {
+			//not supported by package net
+			closeOnExec(f)
+			sp.sctp = append(sp.sctp, f)
+			sp.state.SCTPFiles[i] = f
+			continue
+		}
+		if isPacketNetwork(network) {
+			c, err := net.FilePacketConn(f)
+			if err != nil {
+				return fmt.Errorf("failed to inherit file descriptor: %d", i)
+			}
+			sp.addPacketConn(i, c)
+			continue
+		}
 		l, err := net.FileListener(f)
 		if err != nil {
 			return fmt.Errorf("failed to inherit file descriptor: %d", i)
 		}
-		u := newOverseerListener(l)
-		sp.listeners[i] = u
-		sp.state.Listeners[i] = u
+		sp.addListener(i, l, options)
+	}
+	return nil
+}
+
+// bindSockets binds each address with SO_REUSEPORT alongside the
+// previous program, then notifies the master it may be stopped
+func (sp *slave) bindSockets() error {
+	n := len(sp.Config.Addresses)
+	sp.state.Listeners = make([]net.Listener, n)
+	sp.state.PacketConns = make([]net.PacketConn, n)
+	for i, addr := range sp.Config.Addresses {
+		network, address := parseAddress(addr)
+		options := sp.Config.ListenOptions[addr]
+		if isPacketNetwork(network) {
+			c, err := listenConfig(options, true).ListenPacket(context.Background(), network, address)
+			if err != nil {
+				return failed(ErrBindFailed, "failed to bind: %s (%w)", addr, err)
+			}
+			sp.addPacketConn(i, c)
+			continue
+		}
+		l, err := listenStream(options, true, network, address)
+		if err != nil {
+			return failed(ErrBindFailed, "failed to bind: %s (%w)", addr, err)
+		}
+		sp.addListener(i, l, options)
+	}
+	if !sp.isPrimary() {
+		//replicas are replaced one at a time
+		return nil
 	}
-	if len(sp.state.Listeners) > 0 {
-		sp.state.Listener = sp.state.Listeners[0]
+	if err := sp.requestMaster(requestReleased); err != nil {
+		return fmt.Errorf("failed to signal master (%w)", err)
 	}
 	return nil
 }
 
+func (sp *slave) addListener(i int, l net.Listener, options *ListenOptions) {
+	u := newOverseerListener(l, options)
+	if i < len(sp.state.Addresses) {
+		u.addr = sp.state.Addresses[i]
+	}
+	sp.listeners = append(sp.listeners, u)
+	var pl net.Listener = u
+	if options != nil && options.ProxyProtocol {
+		pl = &proxyListener{Listener: u}
+	}
+	sp.state.Listeners[i] = pl
+	if sp.state.Listener == nil {
+		sp.state.Listener = pl
+	}
+}
+
+func (sp *slave) addPacketConn(i int, c net.PacketConn) {
+	u := newOverseerPacketConn(c)
+	sp.packets = append(sp.packets, u)
+	sp.state.PacketConns[i] = u
+	if sp.state.PacketConn == nil {
+		sp.state.PacketConn = u
+	}
+}
+
+// masterRequest is what the slave signals
+// to the master, see requestMaster
+type masterRequest byte
+
+const (
+	requestReleased masterRequest = 'u'
+	requestRestart  masterRequest = 'r'
+	requestFetch    masterRequest = 'f'
+)
+
 func (sp *slave) watchSignal() {
-	signals := make(chan os.Signal)
+	signals := make(chan os.Signal, 1)
 	signal.Notify(signals, sp.Config.RestartSignal)
+	sp.watchMaster(signals)
+	var done <-chan struct{}
+	if sp.ctx != nil {
+		done = sp.ctx.Done()
+	}
 	go func() {
-		<-signals
+		select {
+		case <-signals:
+		case <-done:
+			sp.debugf("context done (%s)", sp.ctx.Err())
+		}
 		signal.Stop(signals)
 		sp.debugf("graceful shutdown requested")
 		//master wants to restart,
 		close(sp.state.GracefulShutdown)
+		sp.state.drainHooks.run()
 		//release any sockets and notify master
-		if len(sp.listeners) > 0 {
+		if len(sp.listeners) > 0 || len(sp.packets) > 0 || len(sp.sctp) > 0 {
 			//perform graceful shutdown
 			for _, l := range sp.listeners {
-				l.release(sp.Config.TerminateTimeout)
+				l.release()
+			}
+			go sp.drain()
+			for _, c := range sp.packets {
+				c.release()
 			}
 			//signal release of held sockets, allows master to start
 			//a new process before this child has actually exited.
-			//early restarts not supported with restarts disabled.
-			if !sp.NoRestart {
-				sp.masterProc.Signal(SIGUSR1)
+			//early restarts not supported with restarts disabled,
+			//nor needed when the next program binds its own sockets,
+			//or by replicas.
+			if !sp.NoRestart && !sp.ReusePort && sp.isPrimary() {
+				sp.requestMaster(requestReleased)
 			}
 			//listeners should be waiting on connections to close...
 		}
 		//start death-timer
 		go func() {
-			time.Sleep(sp.Config.TerminateTimeout)
+			time.Sleep(sp.Config.killAfter())
 			sp.debugf("timeout. forceful shutdown")
 			os.Exit(1)
 		}()
 	}()
 }
 
-func (sp *slave) triggerRestart() {
-	if err := sp.masterProc.Signal(sp.Config.RestartSignal); err != nil {
+// drain waits for the connections of the released listeners to be
+// closed, until the DrainTimeout deadline closes the rest by force
+func (sp *slave) drain() {
+	drained := make(chan bool)
+	go func() {
+		for _, l := range sp.listeners {
+			l.wg.Wait()
+		}
+		close(drained)
+	}()
+	deadline := time.After(sp.Config.DrainTimeout)
+	progress := time.NewTicker(time.Second)
+	defer progress.Stop()
+	for {
+		select {
+		case <-drained:
+			sp.debugf("connections drained")
+			return
+		case <-progress.C:
+			sp.debugf("draining %d connections %v", sp.state.Connections(), sp.state.ConnectionsByAddress())
+		case <-deadline:
+			sp.drainExpired()
+			return
+		}
+	}
+}
+
+// drainExpired reports and closes the remaining connections
+func (sp *slave) drainExpired() {
+	d := Drain{
+		Generation: sp.state.Generation,
+		Addresses:  map[string]int{},
+	}
+	for _, l := range sp.listeners {
+		if n := l.connections(); n > 0 {
+			d.Open += n
+			d.Addresses[l.addr] += n
+		}
+	}
+	sp.warnf("drain deadline passed, closing %d connections", d.Open)
+	if sp.Config.DrainExpired != nil {
+		sp.Config.DrainExpired(d)
+	}
+	for _, l := range sp.listeners {
+		l.forceClose()
+	}
+}
+
+// requestRestart asks the master for a graceful restart, passing the
+// reason over the control pipe, or by signal when it does not speak
+// the control protocol
+func (sp *slave) requestRestart(reason string) RestartStatus {
+	if sp.controlVersion >= 1 {
+		resp, err := sp.request(listenerRequest{Op: "restart", Reason: reason})
+		if err != nil {
+			os.Exit(1)
+		}
+		return resp.Status
+	}
+	if reason != "" {
+		sp.debugf("restart requested (%s)", reason)
+	}
+	if err := sp.signalMaster(requestRestart); err != nil {
 		os.Exit(1)
 	}
+	return RestartRequested
+}
+
+func (sp *slave) triggerRestart() RestartStatus {
+	return sp.requestRestart("")
+}
+
+func (sp *slave) fetchNow(ctx context.Context) error {
+	if sp.Config.FetchSignal == nil && sp.controlVersion < 1 {
+		return errors.New("overseer.Config.FetchSignal required")
+	}
+	if err := sp.requestMaster(requestFetch); err != nil {
+		return fmt.Errorf("failed to signal master (%w)", err)
+	}
+	return nil
+}
+
+func (sp *slave) setPaused(paused bool) error {
+	return errors.New("pause and resume are only available in the master process")
+}
+
+func (sp *slave) isPaused() bool {
+	return false
+}
+
+// history reads the history persisted by the master,
+// or asks the master for it
+func (sp *slave) history() (History, error) {
+	if sp.Config.HistoryFile != "" {
+		return readHistory(sp.Config.HistoryFile)
+	}
+	resp, err := sp.request(listenerRequest{Op: "history"})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Error != "" {
+		return nil, errors.New(resp.Error)
+	}
+	return append(History{}, resp.History...), nil
+}
+
+// isPrimary reports whether this is the program started by the
+// master first, rather than one of the replicas, see Config.Replicas
+func (sp *slave) isPrimary() bool {
+	return sp.state.Replica == 0 && sp.state.Program == ""
+}
+
+func (sp *slave) tracef(f string, args ...interface{}) {
+	if sp.Config.logs("slave", LogTrace) {
+		sp.Config.logf(LogTrace, "slave#"+sp.id, f, args...)
+	}
 }
 
 func (sp *slave) debugf(f string, args ...interface{}) {
-	if sp.Config.Debug {
-		log.Printf("[overseer slave#"+sp.id+"] "+f, args...)
+	if sp.Config.logs("slave", LogDebug) {
+		sp.Config.logf(LogDebug, "slave#"+sp.id, f, args...)
 	}
 }
 
 func (sp *slave) warnf(f string, args ...interface{}) {
-	if sp.Config.Debug || !sp.Config.NoWarn {
-		log.Printf("[overseer slave#"+sp.id+"] "+f, args...)
+	if sp.Config.logs("slave", LogWarn) {
+		sp.Config.logf(LogWarn, "slave#"+sp.id, f, args...)
 	}
 }
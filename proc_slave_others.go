@@ -3,6 +3,7 @@
 package overseer
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"syscall"
@@ -13,14 +14,15 @@ func (sp *slave) watchParent() error {
 	sp.masterPid = os.Getppid()
 	proc, err := os.FindProcess(sp.masterPid)
 	if err != nil {
-		return fmt.Errorf("master process: %s", err)
+		return fmt.Errorf("master process: %w", err)
 	}
 	sp.masterProc = proc
 	go func() {
 		//send signal 0 to master process forever
 		for {
-			//should not error as long as the process is alive
-			if err := sp.masterProc.Signal(syscall.Signal(0)); err != nil {
+			//should not error as long as the process is alive,
+			//the master may not be signaled when running as Config.User
+			if err := sp.masterProc.Signal(syscall.Signal(0)); err != nil && !errors.Is(err, syscall.EPERM) {
 				os.Exit(1)
 			}
 			time.Sleep(2 * time.Second)
@@ -0,0 +1,56 @@
+package overseer
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestDrainExpired holds a connection open past the drain deadline,
+// which must close it by force before the program would be killed
+func TestDrainExpired(t *testing.T) {
+	c := &Config{Program: program, TerminateTimeout: 500 * time.Millisecond}
+	expired := make(chan Drain, 1)
+	c.DrainExpired = func(d Drain) { expired <- d }
+	if err := validate(c); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ol := newOverseerListener(l, nil)
+	ol.addr = "127.0.0.1:0"
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := ol.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sp := &slave{Config: c, listeners: []*overseerListener{ol}}
+	sp.state.Generation = 2
+	ol.release()
+	started := time.Now()
+	sp.drain()
+	if elapsed := time.Since(started); elapsed < c.DrainTimeout || elapsed >= c.killAfter() {
+		t.Errorf("drained after %s, expected between %s and %s", elapsed, c.DrainTimeout, c.killAfter())
+	}
+	select {
+	case d := <-expired:
+		if d.Generation != 2 || d.Open != 1 || d.Addresses["127.0.0.1:0"] != 1 {
+			t.Errorf("got %+v", d)
+		}
+	default:
+		t.Fatal("DrainExpired not called")
+	}
+	//the connection was closed by force
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := ioutil.ReadAll(client); err != nil {
+		t.Errorf("connection not closed (%s)", err)
+	}
+}
@@ -0,0 +1,89 @@
+package overseer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+)
+
+const envProgram = "OVERSEER_PROGRAM"
+
+// Program is another program of the binary
+// supervised by the master, see Config.Programs
+type Program struct {
+	//Name identifies the program, see State.Program
+	Name string
+	//Program is the program's main function
+	Program func(state State)
+	//Addresses are the program's own listening addresses, passed
+	//to it in State.Listeners as Config.Addresses are to Program.
+	//TLS and ListenOptions apply to them too.
+	Addresses []string
+}
+
+func (c *Config) validatePrograms() error {
+	if len(c.Programs) == 0 {
+		return nil
+	}
+	if runtime.GOOS == "windows" || c.SelfUpgrade || c.ConnectionHandoff || c.Terminal {
+		return errors.New("overseer.Config.Programs is not supported on windows, or with SelfUpgrade, ConnectionHandoff or Terminal")
+	}
+	names := map[string]bool{}
+	addresses := append([]string{}, c.Addresses...)
+	for _, p := range c.Programs {
+		if p.Name == "" || names[p.Name] {
+			return fmt.Errorf("overseer.Config.Programs %q: a unique name is required", p.Name)
+		}
+		names[p.Name] = true
+		if p.Program == nil {
+			return fmt.Errorf("overseer.Config.Programs %s: Program required", p.Name)
+		}
+		for _, addr := range p.Addresses {
+			if containsAddress(addresses, addr) {
+				return fmt.Errorf("overseer.Config.Programs %s: %s is already used", p.Name, addr)
+			}
+			addresses = append(addresses, addr)
+		}
+	}
+	return nil
+}
+
+// bindPrograms opens the sockets of the other programs
+func (mp *master) bindPrograms(inherited, activated map[string]*os.File) error {
+	mp.programFiles = make([][]*os.File, len(mp.Config.Programs))
+	for i, p := range mp.Config.Programs {
+		for _, addr := range p.Addresses {
+			f, err := mp.socketFile(addr, inherited, activated)
+			if err != nil {
+				return err
+			}
+			mp.programFiles[i] = append(mp.programFiles[i], f)
+		}
+	}
+	return nil
+}
+
+// selectProgram runs the program of Config.Programs
+// the slave was started as, if any
+func (sp *slave) selectProgram() error {
+	name := os.Getenv(envProgram)
+	if name == "" {
+		return nil
+	}
+	for _, p := range sp.Config.Programs {
+		if p.Name != name {
+			continue
+		}
+		sp.state.Program = name
+		sp.Config.Program = p.Program
+		sp.Config.Addresses = p.Addresses
+		sp.Config.Address = ""
+		if len(p.Addresses) > 0 {
+			sp.Config.Address = p.Addresses[0]
+		}
+		sp.Config.NamedAddresses = nil
+		return nil
+	}
+	return fmt.Errorf("program %s not found", name)
+}
@@ -0,0 +1,199 @@
+package overseer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyHeaderTimeout is how long a client has to send its PROXY header
+const proxyHeaderTimeout = 10 * time.Second
+
+// proxySignature begins PROXY protocol v2 headers
+var proxySignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+var errProxyHeader = errors.New("invalid PROXY protocol header")
+
+// proxyListener expects connections to begin with a PROXY protocol header
+type proxyListener struct {
+	net.Listener
+}
+
+func (l *proxyListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &proxyConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// proxyConn reads the PROXY header on first use,
+// so that slow clients do not block Accept
+type proxyConn struct {
+	net.Conn
+	r             *bufio.Reader
+	once          sync.Once
+	err           error
+	remote, local net.Addr
+	deadlineMux   sync.Mutex
+	userRead      time.Time
+}
+
+func (c *proxyConn) init() {
+	c.once.Do(func() {
+		c.Conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+		c.remote, c.local, c.err = readProxyHeader(c.r)
+		//restore the deadline set by the program
+		c.deadlineMux.Lock()
+		c.Conn.SetReadDeadline(c.userRead)
+		c.deadlineMux.Unlock()
+	})
+}
+
+func (c *proxyConn) Read(p []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.r.Read(p)
+}
+
+func (c *proxyConn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *proxyConn) LocalAddr() net.Addr {
+	c.init()
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *proxyConn) SetDeadline(t time.Time) error {
+	c.deadlineMux.Lock()
+	c.userRead = t
+	c.deadlineMux.Unlock()
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *proxyConn) SetReadDeadline(t time.Time) error {
+	c.deadlineMux.Lock()
+	c.userRead = t
+	c.deadlineMux.Unlock()
+	return c.Conn.SetReadDeadline(t)
+}
+
+// readProxyHeader reads a v1 or v2 PROXY protocol header, returning
+// the source and destination addresses, which are nil when the
+// proxy does not know them (UNKNOWN and LOCAL)
+func readProxyHeader(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	b, err := r.Peek(len(proxySignature))
+	if err != nil {
+		return nil, nil, errProxyHeader
+	}
+	if bytes.Equal(b, proxySignature) {
+		return readProxyHeaderV2(r)
+	}
+	if bytes.HasPrefix(b, []byte("PROXY ")) {
+		return readProxyHeaderV1(r)
+	}
+	return nil, nil, errProxyHeader
+}
+
+func readProxyHeaderV1(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	//v1 headers are at most 107 bytes
+	line := make([]byte, 0, 107)
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, nil, errProxyHeader
+		}
+		line = append(line, c)
+		if c == '\n' {
+			break
+		}
+		if len(line) == cap(line) {
+			return nil, nil, errProxyHeader
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, errProxyHeader
+	}
+	f := strings.Split(string(line[:len(line)-2]), " ")
+	if len(f) >= 2 && f[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(f) != 6 || (f[1] != "TCP4" && f[1] != "TCP6") {
+		return nil, nil, errProxyHeader
+	}
+	src, err1 := proxyTCPAddr(f[2], f[4])
+	dst, err2 := proxyTCPAddr(f[3], f[5])
+	if err1 != nil || err2 != nil {
+		return nil, nil, errProxyHeader
+	}
+	return src, dst, nil
+}
+
+func proxyTCPAddr(host, port string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	p, err := strconv.ParseUint(port, 10, 16)
+	if ip == nil || err != nil {
+		return nil, errProxyHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+func readProxyHeaderV2(r *bufio.Reader) (net.Addr, net.Addr, error) {
+	h := make([]byte, 16)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return nil, nil, errProxyHeader
+	}
+	if h[12]>>4 != 2 {
+		return nil, nil, errProxyHeader
+	}
+	body := make([]byte, binary.BigEndian.Uint16(h[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, nil, errProxyHeader
+	}
+	switch h[12] & 0xf {
+	case 0: //LOCAL, e.g. health checks by the proxy
+		return nil, nil, nil
+	case 1: //PROXY
+	default:
+		return nil, nil, errProxyHeader
+	}
+	//any trailing TLVs are ignored
+	var ipLen int
+	switch h[13] >> 4 {
+	case 1:
+		ipLen = net.IPv4len
+	case 2:
+		ipLen = net.IPv6len
+	default:
+		//unspecified and unix families
+		return nil, nil, nil
+	}
+	if len(body) < 2*ipLen+4 {
+		return nil, nil, errProxyHeader
+	}
+	srcIP := net.IP(body[:ipLen])
+	dstIP := net.IP(body[ipLen : 2*ipLen])
+	srcPort := int(binary.BigEndian.Uint16(body[2*ipLen:]))
+	dstPort := int(binary.BigEndian.Uint16(body[2*ipLen+2:]))
+	if h[13]&0xf == 2 {
+		return &net.UDPAddr{IP: srcIP, Port: srcPort}, &net.UDPAddr{IP: dstIP, Port: dstPort}, nil
+	}
+	return &net.TCPAddr{IP: srcIP, Port: srcPort}, &net.TCPAddr{IP: dstIP, Port: dstPort}, nil
+}
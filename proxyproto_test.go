@@ -0,0 +1,112 @@
+package overseer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+)
+
+func proxyHeaderV2(command, family byte, addrs []byte) string {
+	h := append([]byte(nil), proxySignature...)
+	h = append(h, 0x20|command, family, 0, 0)
+	binary.BigEndian.PutUint16(h[14:], uint16(len(addrs)))
+	return string(append(h, addrs...))
+}
+
+func TestReadProxyHeader(t *testing.T) {
+	ipv4 := []byte{192, 0, 2, 1, 198, 51, 100, 2, 0x30, 0x39, 0x01, 0xbb}
+	ipv6 := append(append(net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")...), 0x30, 0x39, 0x01, 0xbb)
+	for _, test := range []struct {
+		name, header string
+		src, dst     string
+		err          bool
+	}{
+		{name: "v1 tcp4", header: "PROXY TCP4 192.0.2.1 198.51.100.2 12345 443\r\n", src: "192.0.2.1:12345", dst: "198.51.100.2:443"},
+		{name: "v1 tcp6", header: "PROXY TCP6 2001:db8::1 2001:db8::2 12345 443\r\n", src: "[2001:db8::1]:12345", dst: "[2001:db8::2]:443"},
+		{name: "v1 unknown", header: "PROXY UNKNOWN\r\n"},
+		{name: "v1 unknown addresses", header: "PROXY UNKNOWN 192.0.2.1 198.51.100.2 12345 443\r\n"},
+		{name: "v1 no crlf", header: "PROXY TCP4 192.0.2.1 198.51.100.2 12345 443\n", err: true},
+		{name: "v1 protocol", header: "PROXY UDP4 192.0.2.1 198.51.100.2 12345 443\r\n", err: true},
+		{name: "v1 fields", header: "PROXY TCP4 192.0.2.1 198.51.100.2 12345\r\n", err: true},
+		{name: "v1 address", header: "PROXY TCP4 192.0.2 198.51.100.2 12345 443\r\n", err: true},
+		{name: "v1 port", header: "PROXY TCP4 192.0.2.1 198.51.100.2 123456 443\r\n", err: true},
+		{name: "v1 too long", header: "PROXY UNKNOWN " + strings.Repeat("x", 100) + "\r\n", err: true},
+		{name: "v2 tcp4", header: proxyHeaderV2(1, 0x11, ipv4), src: "192.0.2.1:12345", dst: "198.51.100.2:443"},
+		{name: "v2 tcp6", header: proxyHeaderV2(1, 0x21, ipv6), src: "[2001:db8::1]:12345", dst: "[2001:db8::2]:443"},
+		{name: "v2 udp4", header: proxyHeaderV2(1, 0x12, ipv4), src: "192.0.2.1:12345", dst: "198.51.100.2:443"},
+		{name: "v2 tlvs", header: proxyHeaderV2(1, 0x11, append(ipv4, 0x04, 0x00, 0x01, 0x00)), src: "192.0.2.1:12345", dst: "198.51.100.2:443"},
+		{name: "v2 local", header: proxyHeaderV2(0, 0x00, nil)},
+		{name: "v2 unspecified", header: proxyHeaderV2(1, 0x00, nil)},
+		{name: "v2 command", header: proxyHeaderV2(2, 0x11, ipv4), err: true},
+		{name: "v2 short", header: proxyHeaderV2(1, 0x11, ipv4[:8]), err: true},
+		{name: "v2 truncated", header: proxyHeaderV2(1, 0x11, ipv4)[:20], err: true},
+		{name: "none", header: "GET / HTTP/1.1\r\n\r\n", err: true},
+		{name: "empty", err: true},
+	} {
+		r := bufio.NewReader(strings.NewReader(test.header + "data"))
+		src, dst, err := readProxyHeader(r)
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if got := addrString(src); got != test.src {
+			t.Errorf("%s: got source %q, expected %q", test.name, got, test.src)
+		}
+		if got := addrString(dst); got != test.dst {
+			t.Errorf("%s: got destination %q, expected %q", test.name, got, test.dst)
+		}
+		if rest, _ := ioutil.ReadAll(r); string(rest) != "data" {
+			t.Errorf("%s: got %q after the header", test.name, rest)
+		}
+	}
+}
+
+func addrString(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+func TestProxyListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		c.Write([]byte("PROXY TCP4 192.0.2.1 198.51.100.2 12345 443\r\nhello"))
+	}()
+	conn, err := (&proxyListener{Listener: l}).Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if got := conn.RemoteAddr().String(); got != "192.0.2.1:12345" {
+		t.Errorf("got remote address %s", got)
+	}
+	if got := conn.LocalAddr().String(); got != "198.51.100.2:443" {
+		t.Errorf("got local address %s", got)
+	}
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Errorf("got %q", b)
+	}
+}
@@ -0,0 +1,71 @@
+package overseer
+
+import (
+	"time"
+)
+
+// addPeer tracks the program at the other end of a control pipe
+func (mp *master) addPeer(peer *controlPeer) {
+	mp.peerMux.Lock()
+	defer mp.peerMux.Unlock()
+	if mp.peers == nil {
+		mp.peers = map[int]*controlPeer{}
+	}
+	mp.peers[peer.slaveID] = peer
+}
+
+// removePeer forgets the program once its control pipe is closed
+func (mp *master) removePeer(peer *controlPeer) {
+	mp.peerMux.Lock()
+	defer mp.peerMux.Unlock()
+	delete(mp.peers, peer.slaveID)
+	close(peer.closed)
+}
+
+func (mp *master) peer(slaveID int) *controlPeer {
+	mp.peerMux.Lock()
+	defer mp.peerMux.Unlock()
+	return mp.peers[slaveID]
+}
+
+// isReady reports whether the given slave is ready, programs which
+// predate the control protocol are ready once started
+func (mp *master) isReady(slaveID int) bool {
+	peer := mp.peer(slaveID)
+	if peer == nil {
+		return false
+	}
+	peer.mux.Lock()
+	defer peer.mux.Unlock()
+	return peer.ready || peer.version == 0
+}
+
+// readyTimeout is how long the master waits for a program to be ready
+func (mp *master) readyTimeout() time.Duration {
+	if mp.Config.StartupTimeout > 0 {
+		return mp.Config.StartupTimeout
+	}
+	return mp.TerminateTimeout
+}
+
+// awaitReady waits for the given slave to be ready, see Config.WaitReady,
+// reporting false if it exited or was not ready within readyTimeout
+func (mp *master) awaitReady(slaveID int) bool {
+	if !mp.Config.WaitReady {
+		return true
+	}
+	peer := mp.peer(slaveID)
+	if peer == nil {
+		return false
+	}
+	timeout := mp.readyTimeout()
+	select {
+	case <-peer.readied:
+		return true
+	case <-peer.closed:
+		return false
+	case <-time.After(timeout):
+		mp.warnf("program %d not ready within %s", peer.pid, timeout)
+		return false
+	}
+}
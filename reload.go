@@ -0,0 +1,107 @@
+package overseer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reloaded is the part of the Config which may be changed
+// while the master is running, see Config.Reload
+type Reloaded struct {
+	//MinFetchInterval replaces Config.MinFetchInterval, when set
+	MinFetchInterval time.Duration
+	//LogLevel, LogLevels, Debug and NoWarn replace those of
+	//the master's Config, the program's logs change once the
+	//program reloads its own Config
+	LogLevel  string
+	LogLevels map[string]string
+	Debug     bool
+	NoWarn    bool
+	//NoRestartAfterFetch replaces Config.NoRestartAfterFetch
+	NoRestartAfterFetch bool
+	//Addresses are listened on, as with AddListener,
+	//unless they already are
+	Addresses []string
+}
+
+// Reload asks the master to reload the parts of its Config returned
+// by Config.Reload. It may be called from the program.
+func Reload() error {
+	if currentProcess != nil {
+		return currentProcess.reload()
+	}
+	return ErrNotRunning
+}
+
+func (mp *master) reload() error {
+	if mp.Config.Reload == nil {
+		return errors.New("overseer.Config.Reload required")
+	}
+	r, err := mp.Config.Reload()
+	if err != nil {
+		return mp.warnErr("failed to reload config: %w", err)
+	}
+	if err := validateLogLevels(r.LogLevel, r.LogLevels); err != nil {
+		return mp.warnErr("failed to reload config: %w", err)
+	}
+	mp.reloadMux.Lock()
+	if r.MinFetchInterval > 0 {
+		mp.Config.MinFetchInterval = r.MinFetchInterval
+	}
+	mp.storeLogLevels(&Config{LogLevel: r.LogLevel, LogLevels: r.LogLevels, Debug: r.Debug, NoWarn: r.NoWarn})
+	mp.Config.NoRestartAfterFetch = r.NoRestartAfterFetch
+	mp.reloadMux.Unlock()
+	for _, addr := range r.Addresses {
+		if containsAddress(mp.addresses(), addr) {
+			continue
+		}
+		if err := mp.changeListener("add", addr); err != nil {
+			return mp.warnErr("failed to reload config: %w", err)
+		}
+	}
+	mp.debugf("reloaded config")
+	return nil
+}
+
+// minFetchInterval returns the current, possibly reloaded, interval
+func (mp *master) minFetchInterval() time.Duration {
+	mp.reloadMux.Lock()
+	defer mp.reloadMux.Unlock()
+	return mp.Config.MinFetchInterval
+}
+
+// logs reports whether the subsystem logs at the level,
+// with the current, possibly reloaded, levels. It is called
+// for every log, so the levels are not guarded by reloadMux.
+func (mp *master) logs(subsystem string, level LogLevel) bool {
+	if c, ok := mp.logLevels.Load().(*Config); ok {
+		return c.logs(subsystem, level)
+	}
+	return mp.Config.logs(subsystem, level)
+}
+
+// storeLogLevels replaces the levels with those of c,
+// whose LogLevels are copied
+func (mp *master) storeLogLevels(c *Config) {
+	levels := map[string]string{}
+	for subsystem, l := range c.LogLevels {
+		levels[subsystem] = l
+	}
+	mp.logLevels.Store(&Config{LogLevel: c.LogLevel, LogLevels: levels, Debug: c.Debug, NoWarn: c.NoWarn})
+}
+
+// noRestartAfterFetch returns the current, possibly
+// reloaded, Config.NoRestartAfterFetch
+func (mp *master) noRestartAfterFetch() bool {
+	mp.reloadMux.Lock()
+	defer mp.reloadMux.Unlock()
+	return mp.Config.NoRestartAfterFetch
+}
+
+func (sp *slave) reload() error {
+	if err := sp.control("reload", ""); err != nil {
+		return fmt.Errorf("failed to reload config (%w)", err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package overseer
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const envReplica = "OVERSEER_REPLICA"
+
+// replica is a copy of the program started alongside it, see
+// Config.Replicas, or one of Config.Programs which follow them
+type replica struct {
+	index   int
+	name    string
+	slaveID int
+	proc    *os.Process
+	exited  chan bool
+}
+
+// replicaCount is the number of processes started alongside the
+// program, the copies of Config.Replicas then Config.Programs
+func (mp *master) replicaCount() int {
+	n := len(mp.Config.Programs)
+	if mp.Config.Replicas > 1 {
+		n += mp.Config.Replicas - 1
+	}
+	return n
+}
+
+// replicaProgram returns the index in Config.Programs
+// of the replica, or -1 when it is a copy of the program
+func (mp *master) replicaProgram(index int) int {
+	copies := 1
+	if mp.Config.Replicas > 1 {
+		copies = mp.Config.Replicas
+	}
+	if index < copies {
+		return -1
+	}
+	return index - copies
+}
+
+// startReplicas starts the copies of the program, and
+// the other programs, once the first program is running
+func (mp *master) startReplicas() {
+	for i := 1; i <= mp.replicaCount(); i++ {
+		if err := mp.startReplica(i); err != nil {
+			mp.warnf("failed to start %s (%s)", mp.replicaName(i), err)
+		}
+	}
+}
+
+func (mp *master) replicaName(index int) string {
+	if p := mp.replicaProgram(index); p >= 0 {
+		return "program " + mp.Config.Programs[p].Name
+	}
+	return fmt.Sprintf("replica %d", index)
+}
+
+// startReplica starts the replica with the given
+// index, replacing the previous one, if any
+func (mp *master) startReplica(index int) error {
+	cmd, slaveID, _, err := mp.start(index)
+	if err != nil {
+		return err
+	}
+	r := &replica{index: index, name: mp.replicaName(index), slaveID: slaveID, proc: cmd.Process, exited: make(chan bool)}
+	mp.replicaMux.Lock()
+	if mp.replicas == nil {
+		mp.replicas = make([]*replica, mp.replicaCount())
+	}
+	mp.replicas[index-1] = r
+	mp.replicaMux.Unlock()
+	go mp.superviseReplica(r, cmd.Wait)
+	return nil
+}
+
+// superviseReplica waits for the replica to exit, starting
+// another in its place when it exits unexpectedly
+func (mp *master) superviseReplica(r *replica, wait func() error) {
+	exit := mp.exitStatus(r.slaveID, r.proc.Pid, wait())
+	if p := mp.replicaProgram(r.index); p >= 0 {
+		exit.Program = mp.Config.Programs[p].Name
+	} else {
+		exit.Replica = r.index
+	}
+	close(r.exited)
+	mp.replicaMux.Lock()
+	replaced := mp.replicas[r.index-1] != r
+	mp.replicaMux.Unlock()
+	exit.Replaced = replaced
+	mp.programExited(exit)
+	if replaced || mp.isStopping() || mp.NoRestart {
+		return
+	}
+	mp.warnf("%s exited with %d, restarting", r.name, exit.Code)
+	time.Sleep(restartSettle)
+	starting := mp.replicaStarting(r.index)
+	starting.Lock()
+	defer starting.Unlock()
+	if mp.replica(r.index) != r || mp.isStopping() {
+		//replaced by rollReplicas meanwhile
+		return
+	}
+	if err := mp.startReplica(r.index); err != nil {
+		mp.warnf("failed to restart %s (%s)", r.name, err)
+	}
+}
+
+// replicaStarting serializes the starts of the replica with the given
+// index, by rollReplicas and superviseReplica, so that each replaces
+// the other's replica rather than leaving one of them untracked
+func (mp *master) replicaStarting(index int) *sync.Mutex {
+	mp.replicaMux.Lock()
+	defer mp.replicaMux.Unlock()
+	if mp.replicaStarts == nil {
+		mp.replicaStarts = map[int]*sync.Mutex{}
+	}
+	m, ok := mp.replicaStarts[index]
+	if !ok {
+		m = &sync.Mutex{}
+		mp.replicaStarts[index] = m
+	}
+	return m
+}
+
+// rollReplicas restarts the replicas one at a time, each
+// replaced by a copy of the next program before it is asked
+// to terminate, so that the others keep serving meanwhile
+func (mp *master) rollReplicas() {
+	for i := 1; i <= mp.replicaCount(); i++ {
+		starting := mp.replicaStarting(i)
+		starting.Lock()
+		prev := mp.replica(i)
+		err := mp.startReplica(i)
+		starting.Unlock()
+		if err != nil {
+			mp.warnf("failed to restart %s (%s)", mp.replicaName(i), err)
+			continue
+		}
+		if prev == nil {
+			continue
+		}
+		//give the next replica time to start accepting
+		if mp.WaitReady {
+			mp.awaitReady(mp.replica(i).slaveID)
+		} else {
+			time.Sleep(restartSettle)
+		}
+		mp.debugf("%s restarting", prev.name)
+		prev.proc.Signal(mp.Config.RestartSignal)
+		mp.escalate(prev.proc.Signal, prev.exited)
+	}
+}
+
+func (mp *master) replica(index int) *replica {
+	mp.replicaMux.Lock()
+	defer mp.replicaMux.Unlock()
+	if index-1 < len(mp.replicas) {
+		return mp.replicas[index-1]
+	}
+	return nil
+}
+
+// signalReplicas proxies the signal to the replicas
+func (mp *master) signalReplicas(s os.Signal) {
+	mp.replicaMux.Lock()
+	defer mp.replicaMux.Unlock()
+	for _, r := range mp.replicas {
+		if r != nil {
+			r.proc.Signal(s)
+		}
+	}
+}
+
+// stopReplicas waits for the replicas to exit before
+// the master exits, asking them to if not stopping
+func (mp *master) stopReplicas() {
+	mp.replicaMux.Lock()
+	replicas := append([]*replica{}, mp.replicas...)
+	mp.replicaMux.Unlock()
+	if len(replicas) == 0 {
+		return
+	}
+	if mp.setStopping() {
+		mp.signalReplicas(SIGTERM)
+	}
+	wg := sync.WaitGroup{}
+	for _, r := range replicas {
+		if r == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(r *replica) {
+			defer wg.Done()
+			mp.escalate(r.proc.Signal, r.exited)
+		}(r)
+	}
+	wg.Wait()
+}
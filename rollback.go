@@ -0,0 +1,200 @@
+package overseer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// previousBinary is kept while an upgrade may be rolled back,
+// see Config.StartupTimeout and Config.AdminSocket
+type previousBinary struct {
+	//path of the copy on disk
+	path string
+	//memBin is the previous in-memory binary,
+	//nil when it was the binary on disk
+	memBin  *os.File
+	hash    []byte
+	sha256  []byte
+	version string
+}
+
+// runs reports whether the previous binary is the in-memory binary
+func (p *previousBinary) runs(memBin *os.File) bool {
+	return p != nil && p.memBin == memBin
+}
+
+// previousPath is where the previous binary is copied to
+func (mp *master) previousPath() string {
+	return filepath.Join(filepath.Dir(mp.tmpBinPath), tmpBinPrefix+mp.binName+"-previous"+extension())
+}
+
+// backupBinary keeps the current binary before it is
+// replaced, to restore it should the upgrade be rolled back
+func (mp *master) backupBinary() error {
+	if mp.Config.StartupTimeout <= 0 && mp.Config.AdminSocket == "" {
+		return nil
+	}
+	mp.binMux.Lock()
+	prev := &previousBinary{hash: mp.binHash, sha256: mp.binSHA256, version: mp.binVersion, memBin: mp.memBin}
+	old := mp.prevBin
+	mp.binMux.Unlock()
+	if !mp.Config.InMemory {
+		prev.path = mp.previousPath()
+		if err := copyFile(prev.path, mp.binPath); err != nil {
+			return err
+		}
+	}
+	mp.binMux.Lock()
+	mp.prevBin = prev
+	mp.binMux.Unlock()
+	//the binary before the previous one is no longer needed
+	if old != nil && old.memBin != nil && old.memBin != prev.memBin {
+		old.memBin.Close()
+	}
+	return nil
+}
+
+// restoreBinary replaces the current binary with the previous one
+func (mp *master) restoreBinary(prev *previousBinary) error {
+	if mp.Config.InMemory {
+		mp.binMux.Lock()
+		if mp.memBin != nil {
+			mp.memBin.Close()
+		}
+		mp.memBin = prev.memBin
+		mp.binMux.Unlock()
+	} else {
+		if err := copyFile(mp.tmpBinPath, prev.path); err != nil {
+			return err
+		}
+		if err := overwrite(mp.binPath, mp.tmpBinPath); err != nil {
+			return err
+		}
+		os.Remove(prev.path)
+	}
+	mp.binMux.Lock()
+	mp.binHash = prev.hash
+	mp.binSHA256 = prev.sha256
+	mp.binVersion = prev.version
+	mp.binMux.Unlock()
+	return nil
+}
+
+// isRejected reports whether the binary was rolled back, or its
+// rollout halted, in which case it is not upgraded to again
+func (mp *master) isRejected(hash []byte) bool {
+	mp.binMux.Lock()
+	defer mp.binMux.Unlock()
+	return mp.rejectedHash != nil && bytes.Equal(mp.rejectedHash, hash)
+}
+
+// reject prevents upgrading to the binary again, see isRejected
+func (mp *master) reject(hash []byte) {
+	mp.binMux.Lock()
+	mp.rejectedHash = hash
+	mp.binMux.Unlock()
+}
+
+// takeRestartKill reports whether the program is killed instead
+// of asked to terminate by the restart, the caller holds restartMux
+func (mp *master) takeRestartKill() bool {
+	kill := mp.restartKill
+	mp.restartKill = false
+	return kill
+}
+
+// startupDeadline waits for the started program to become ready,
+// rolling back its upgrade if it is not ready within StartupTimeout
+func (mp *master) startupDeadline(peer *controlPeer) {
+	if mp.Config.StartupTimeout <= 0 {
+		return
+	}
+	time.Sleep(mp.Config.StartupTimeout)
+	peer.mux.Lock()
+	ready := peer.ready
+	peer.mux.Unlock()
+	if ready || mp.activeID() != peer.slaveID {
+		//ready, or replaced meanwhile
+		return
+	}
+	mp.rollback(peer.slaveID)
+}
+
+// rollback kills the program which failed to become ready, restoring
+// and restarting the previous binary when it was upgraded to
+func (mp *master) rollback(slaveID int) {
+	result := fmt.Errorf("program not ready within %s", mp.Config.StartupTimeout)
+	mp.probationMux.Lock()
+	upgraded := mp.probationHash != nil && mp.probationSlaveID == slaveID
+	mp.probationMux.Unlock()
+	mp.endProbation(slaveID, result)
+	mp.binMux.Lock()
+	prev := mp.prevBin
+	if upgraded {
+		mp.prevBin = nil
+	}
+	mp.binMux.Unlock()
+	if !upgraded || prev == nil {
+		//handled as a crash
+		mp.warnf("%s, killing it", result)
+		mp.sendSignal(os.Kill)
+		return
+	}
+	mp.warnf("%s, rolling back", result)
+	if err := mp.rollBackTo(prev, Event{SlaveID: slaveID, Error: result.Error()}); err != nil {
+		mp.warnf("%s, failed to restore previous binary (%s), killing it", result, err)
+		mp.sendSignal(os.Kill)
+		return
+	}
+	mp.restartMux.Lock()
+	mp.restartKill = true
+	if mp.restartReason == "" {
+		mp.restartReason = "rollback"
+	}
+	mp.restartMux.Unlock()
+	mp.triggerRestart()
+}
+
+// rollBackTo restores the previous binary, recording the rollback,
+// the restored binary is started by the next restart
+func (mp *master) rollBackTo(prev *previousBinary, event Event) error {
+	started := time.Now()
+	rejected := mp.currentHash()
+	if err := mp.restoreBinary(prev); err != nil {
+		return err
+	}
+	mp.reject(rejected)
+	mp.warnf("rolled back binary (%x -> %x)", rejected[:12], prev.hash[:12])
+	event.Type = EventRollback
+	event.Hash, event.Version = mp.binary()
+	event.Duration = time.Since(started)
+	mp.emit(event)
+	return nil
+}
+
+// requestRollback rolls back the last upgrade, gracefully
+// restarting the program, see Config.AdminSocket
+func (mp *master) requestRollback() error {
+	mp.binMux.Lock()
+	prev := mp.prevBin
+	mp.prevBin = nil
+	mp.binMux.Unlock()
+	if prev == nil {
+		return errors.New("no upgrade to roll back")
+	}
+	mp.endProbation(mp.activeID(), errors.New("upgrade rolled back"))
+	if err := mp.rollBackTo(prev, Event{SlaveID: mp.activeID(), Reason: "requested"}); err != nil {
+		return mp.warnErr("failed to restore previous binary: %w", err)
+	}
+	mp.restartMux.Lock()
+	if mp.restartReason == "" {
+		mp.restartReason = "rollback"
+	}
+	mp.restartMux.Unlock()
+	mp.triggerRestart()
+	return nil
+}
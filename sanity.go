@@ -0,0 +1,109 @@
+package overseer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// sanityCheckTimeout is how long the fetched binary may take to
+// print the token of the sanity check
+const sanityCheckTimeout = 5 * time.Second
+
+// sanityOutputSize is how much of each output of the sanity check is kept
+const sanityOutputSize = 4096
+
+// SanityCheckError is returned when the fetched binary fails the sanity
+// check, in which it is run to print a token to stdout, as overseer.Run
+// does, before it may replace the current binary. See FetchError.
+type SanityCheckError struct {
+	Path string
+	//Code is the exit code of the binary, or 128 plus the number
+	//of the signal which killed it, as with ProgramExit
+	Code   int
+	Signal os.Signal
+	//TimedOut is set when the binary was killed as it
+	//did not exit within 5 seconds
+	TimedOut bool
+	//Stdout and Stderr are the output of the binary, up to 4KB each
+	Stdout []byte
+	Stderr []byte
+	//Err is the error running the binary, if any
+	Err error
+}
+
+func (e *SanityCheckError) Error() string {
+	var s string
+	switch {
+	case e.TimedOut:
+		s = "sanity check timed out"
+	case e.Err != nil && e.Signal == nil && e.Code == 0:
+		s = fmt.Sprintf("sanity check failed to run binary (%s)", e.Err)
+	case e.Signal != nil:
+		s = fmt.Sprintf("sanity check failed, binary killed by %s", e.Signal)
+	case e.Code != 0:
+		s = fmt.Sprintf("sanity check failed, binary exited with %d", e.Code)
+	default:
+		s = fmt.Sprintf("sanity check failed, unexpected output %q", e.Stdout)
+	}
+	if line := lastLine(e.Stderr); line != "" {
+		s += ": " + line
+	}
+	return s
+}
+
+// Is reports the error as ErrSanityCheckFailed
+func (e *SanityCheckError) Is(target error) bool {
+	return target == ErrSanityCheckFailed
+}
+
+func (e *SanityCheckError) Unwrap() error {
+	return e.Err
+}
+
+// lastLine returns the line of stderr which most likely explains
+// the failure, a panic or fatal error, or otherwise the last line
+func lastLine(stderr []byte) string {
+	if line := panicLine(stderr); line != "" {
+		return line
+	}
+	stderr = bytes.TrimSpace(stderr)
+	if i := bytes.LastIndexByte(stderr, '\n'); i >= 0 {
+		stderr = stderr[i+1:]
+	}
+	return string(bytes.TrimSpace(stderr))
+}
+
+// runSanityCheck runs the binary at path, which must print the token
+func (mp *master) runSanityCheck(path string) error {
+	tokenIn := token()
+	cmd := exec.Command(path)
+	cmd.Env = append(mp.environ(), []string{envBinCheck + "=" + tokenIn}...)
+	cmd.Args = mp.args()
+	//the token is all we expect, dont buffer unbounded output
+	stdout := &cappedBuffer{max: sanityOutputSize}
+	stderr := &cappedBuffer{max: sanityOutputSize}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	timedOut := false
+	err := cmd.Start()
+	if err == nil {
+		timer := time.AfterFunc(sanityCheckTimeout, func() {
+			mp.warnf("sanity check against fetched executable timed-out, check overseer is running")
+			cmd.Process.Kill()
+		})
+		err = cmd.Wait()
+		timedOut = !timer.Stop()
+	}
+	if err == nil && tokenIn == stdout.String() {
+		return nil
+	}
+	exit := mp.exitStatus(0, 0, err)
+	e := &SanityCheckError{Path: path, Signal: exit.Signal, TimedOut: timedOut, Stdout: stdout.Bytes(), Stderr: stderr.Bytes(), Err: err}
+	if _, ok := err.(*exec.ExitError); ok {
+		e.Code = exit.Code
+	}
+	return e
+}
@@ -0,0 +1,65 @@
+package overseer
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// setupNotify takes the systemd notification socket (Type=notify)
+// from the environment, so that only the master notifies systemd,
+// and starts the watchdog heartbeats if requested
+func (mp *master) setupNotify() {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	usec, _ := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	pid := os.Getenv("WATCHDOG_PID")
+	//not passed on to the program
+	os.Unsetenv("NOTIFY_SOCKET")
+	os.Unsetenv("WATCHDOG_USEC")
+	os.Unsetenv("WATCHDOG_PID")
+	if socket == "" {
+		return
+	}
+	mp.notifySocket = socket
+	if usec > 0 && (pid == "" || pid == strconv.Itoa(os.Getpid())) {
+		mp.watchdogUSEC = usec
+		go mp.watchdog(time.Duration(usec) * time.Microsecond / 2)
+	}
+}
+
+// notifyEnv restores the systemd environment for a re-executed master
+func (mp *master) notifyEnv() []string {
+	if mp.notifySocket == "" {
+		return nil
+	}
+	e := []string{"NOTIFY_SOCKET=" + mp.notifySocket}
+	if mp.watchdogUSEC > 0 {
+		e = append(e, "WATCHDOG_USEC="+strconv.FormatInt(mp.watchdogUSEC, 10))
+	}
+	return e
+}
+
+// notify sends the state to systemd, see sd_notify(3)
+func (mp *master) notify(state string) {
+	if mp.notifySocket == "" {
+		return
+	}
+	c, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: mp.notifySocket, Net: "unixgram"})
+	if err != nil {
+		mp.debugf("failed to notify systemd (%s)", err)
+		return
+	}
+	defer c.Close()
+	if _, err := c.Write([]byte(state)); err != nil {
+		mp.debugf("failed to notify systemd (%s)", err)
+	}
+}
+
+// watchdog sends heartbeats for the lifetime of the master
+func (mp *master) watchdog(interval time.Duration) {
+	for {
+		mp.notify("WATCHDOG=1")
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,113 @@
+package overseer
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// GRPCServer is implemented by *grpc.Server, see ServeGRPC
+type GRPCServer interface {
+	Serve(l net.Listener) error
+	GracefulStop()
+	Stop()
+}
+
+// FastHTTPServer is implemented by *fasthttp.Server, see ServeFastHTTP
+type FastHTTPServer interface {
+	Serve(l net.Listener) error
+	Shutdown() error
+}
+
+// ServeHTTP serves s on state.Listener until the program is asked to
+// shut down for a restart, when Shutdown lets running requests finish.
+// Connections still open at the drain deadline (Config.DrainTimeout,
+// shortly before the program is killed) are closed with Close. It returns
+// once the server has stopped, or with the error of Serve.
+func ServeHTTP(state State, s *http.Server) error {
+	if err := serveUntilShutdown(state, s.Serve); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), state.drainTimeout)
+	defer cancel()
+	if err := s.Shutdown(ctx); err != nil {
+		s.Close()
+	}
+	return nil
+}
+
+// ServeGRPC serves s on state.Listener until the program is asked to
+// shut down for a restart, when GracefulStop lets running RPCs finish.
+// RPCs still running at the drain deadline (Config.DrainTimeout, shortly
+// before the program is killed) are cancelled with Stop. It returns
+// once the server has stopped, or with the error of Serve.
+func ServeGRPC(state State, s GRPCServer) error {
+	if err := serveUntilShutdown(state, s.Serve); err != nil {
+		return err
+	}
+	stopped := make(chan bool)
+	go func() {
+		s.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(state.drainTimeout):
+		s.Stop()
+		<-stopped
+	}
+	return nil
+}
+
+// ServeFastHTTP serves s on state.Listener until the program is asked
+// to shut down for a restart, when Shutdown lets running requests
+// finish. Connections still open at the drain deadline are closed by
+// overseer. It returns once the server has stopped, or with the error
+// of Serve.
+func ServeFastHTTP(state State, s FastHTTPServer) error {
+	if err := serveUntilShutdown(state, s.Serve); err != nil {
+		return err
+	}
+	s.Shutdown()
+	return nil
+}
+
+// serveListener is passed to the servers, overseer closes the
+// listener itself, so closing it does not wait for connections
+type serveListener struct {
+	net.Listener
+}
+
+func (l serveListener) Close() error {
+	return nil
+}
+
+// serveUntilShutdown serves state.Listener until GracefulShutdown
+// is closed, returning the error of serve if it fails before
+func serveUntilShutdown(state State, serve func(l net.Listener) error) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(serveListener{state.Listener})
+	}()
+	select {
+	case err := <-served:
+		if !shuttingDown(state) {
+			return err
+		}
+		//serve fails once overseer releases the listener,
+		//accepted connections are still served until stopped
+	case <-state.GracefulShutdown:
+	}
+	return nil
+}
+
+// shuttingDown reports whether GracefulShutdown is closed
+func shuttingDown(state State) bool {
+	select {
+	case <-state.GracefulShutdown:
+		return true
+	default:
+		return false
+	}
+}
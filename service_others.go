@@ -0,0 +1,8 @@
+//go:build !windows
+// +build !windows
+
+package overseer
+
+func (mp *master) setupService() {}
+
+func (mp *master) serviceStopped(code int) {}
@@ -0,0 +1,164 @@
+//go:build windows
+// +build windows
+
+package overseer
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	advapi32                     = syscall.NewLazyDLL("advapi32.dll")
+	startServiceCtrlDispatcher   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	registerServiceCtrlHandlerEx = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	setServiceStatus             = advapi32.NewProc("SetServiceStatus")
+)
+
+// errorFailedServiceControllerConnect is returned by the dispatcher
+// when the process was not started by the service control manager
+const errorFailedServiceControllerConnect = syscall.Errno(1063)
+
+const (
+	serviceWin32OwnProcess = 0x10
+
+	serviceStopped      = 1
+	serviceStartPending = 2
+	serviceStopPending  = 3
+	serviceRunning      = 4
+
+	serviceAcceptStop        = 0x1
+	serviceAcceptShutdown    = 0x4
+	serviceAcceptParamChange = 0x8
+
+	serviceControlStop        = 1
+	serviceControlInterrogate = 4
+	serviceControlShutdown    = 5
+	serviceControlParamChange = 6
+	//user-defined control codes, sent with "sc control <name> <code>"
+	serviceControlRestart = 128
+	serviceControlFetch   = 129
+
+	errorServiceSpecificError = 1066
+	errorCallNotImplemented   = 120
+)
+
+type serviceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+type serviceStatus struct {
+	serviceType             uint32
+	currentState            uint32
+	controlsAccepted        uint32
+	win32ExitCode           uint32
+	serviceSpecificExitCode uint32
+	checkPoint              uint32
+	waitHint                uint32
+}
+
+// windowsService reports the master's status to the service control manager
+type windowsService struct {
+	mux    sync.Mutex
+	handle uintptr
+	status serviceStatus
+}
+
+// service is set once the master runs as a windows service
+var service *windowsService
+
+// setupService connects to the service control manager when the
+// master was started as a windows service, see Config.WindowsService
+func (mp *master) setupService() {
+	if !mp.Config.WindowsService {
+		return
+	}
+	s := &windowsService{}
+	s.status.serviceType = serviceWin32OwnProcess
+	s.status.currentState = serviceStartPending
+	handler := syscall.NewCallback(func(control, eventType uint32, eventData, context uintptr) uintptr {
+		switch control {
+		case serviceControlStop, serviceControlShutdown:
+			mp.debugf("service stop requested")
+			s.set(serviceStopPending, 0)
+			go mp.handleSignal(os.Interrupt)
+		case serviceControlParamChange, serviceControlRestart:
+			go mp.triggerRestart()
+		case serviceControlFetch:
+			go mp.triggerFetch()
+		case serviceControlInterrogate:
+			s.set(0, 0)
+		default:
+			return errorCallNotImplemented
+		}
+		return 0
+	})
+	started := make(chan bool, 1)
+	serviceMain := syscall.NewCallback(func(argc uint32, argv **uint16) uintptr {
+		h, _, err := registerServiceCtrlHandlerEx.Call(uintptr(unsafe.Pointer(*argv)), handler, 0)
+		if h == 0 {
+			mp.warnf("failed to register service handler (%s)", err)
+			return 0
+		}
+		s.mux.Lock()
+		s.handle = h
+		s.mux.Unlock()
+		s.set(serviceRunning, 0)
+		service = s
+		started <- true
+		return 0
+	})
+	name, _ := syscall.UTF16PtrFromString("")
+	table := []serviceTableEntry{{name: name, proc: serviceMain}, {}}
+	failed := make(chan error, 1)
+	go func() {
+		//the dispatcher calls the handler on this thread,
+		//until the service reports it has stopped
+		runtime.LockOSThread()
+		if r, _, err := startServiceCtrlDispatcher.Call(uintptr(unsafe.Pointer(&table[0]))); r == 0 {
+			failed <- err
+		}
+	}()
+	select {
+	case <-started:
+		mp.debugf("running as a windows service")
+	case err := <-failed:
+		if err != errorFailedServiceControllerConnect {
+			mp.warnf("failed to start windows service (%s)", err)
+		}
+		//not started by the service control manager
+	case <-time.After(30 * time.Second):
+		mp.warnf("windows service did not start")
+	}
+}
+
+// set reports the state, or the current state when zero
+func (s *windowsService) set(state uint32, code int) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if state != 0 {
+		s.status.currentState = state
+	}
+	s.status.controlsAccepted = 0
+	if s.status.currentState == serviceRunning {
+		s.status.controlsAccepted = serviceAcceptStop | serviceAcceptShutdown | serviceAcceptParamChange
+	}
+	if code != 0 {
+		s.status.win32ExitCode = errorServiceSpecificError
+		s.status.serviceSpecificExitCode = uint32(code)
+	}
+	setServiceStatus.Call(s.handle, uintptr(unsafe.Pointer(&s.status)))
+}
+
+// serviceStopped reports the exit of the master to
+// the service control manager, before it exits
+func (mp *master) serviceStopped(code int) {
+	if service != nil {
+		service.set(serviceStopped, code)
+	}
+}
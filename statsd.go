@@ -0,0 +1,60 @@
+package overseer
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// statsd emits the upgrade lifecycle to a StatsD agent, see Config.StatsdAddress
+type statsd struct {
+	conn   net.Conn
+	prefix string
+	//tags are appended to each metric, in the DogStatsD format
+	tags string
+}
+
+// setupStatsd connects to the StatsD agent on StatsdAddress
+func (mp *master) setupStatsd() error {
+	if mp.Config.StatsdAddress == "" {
+		return nil
+	}
+	conn, err := net.Dial("udp", mp.Config.StatsdAddress)
+	if err != nil {
+		return fmt.Errorf("failed to connect to statsd (%w)", err)
+	}
+	s := &statsd{conn: conn, prefix: mp.Config.StatsdPrefix}
+	if s.prefix == "" {
+		s.prefix = "overseer."
+	}
+	if len(mp.Config.StatsdTags) > 0 {
+		s.tags = "|#" + strings.Join(mp.Config.StatsdTags, ",")
+	}
+	mp.statsd = s
+	mp.debugf("emitting metrics to statsd on %s", mp.Config.StatsdAddress)
+	return nil
+}
+
+// event counts each step of the upgrade lifecycle, e.g.
+// "overseer.restart" or "overseer.fetch.failed", and times it
+func (s *statsd) event(e Event) {
+	if s == nil {
+		return
+	}
+	name := string(e.Type)
+	if e.Error != "" {
+		name += ".failed"
+	}
+	s.send(name, "1|c")
+	if e.Duration > 0 {
+		s.send(name+".duration", fmt.Sprintf("%d|ms", e.Duration.Milliseconds()))
+	}
+	if e.Handover > 0 {
+		s.send(name+".handover", fmt.Sprintf("%d|ms", e.Handover.Milliseconds()))
+	}
+}
+
+// send writes the metric, its errors are ignored as with any StatsD client
+func (s *statsd) send(name, value string) {
+	s.conn.Write([]byte(s.prefix + name + ":" + value + s.tags))
+}
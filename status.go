@@ -0,0 +1,103 @@
+package overseer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Status describes the master and its program, see StatusHandler
+type Status struct {
+	MasterPid int `json:"master_pid"`
+	SlavePid  int `json:"slave_pid,omitempty"`
+	//SlaveID is the generation of the program, counting
+	//the programs started by the master
+	SlaveID int    `json:"slave_id"`
+	Version string `json:"version,omitempty"`
+	Hash    string `json:"hash"`
+	//Uptime and ProgramUptime are in seconds,
+	//since the master and the program started
+	Uptime        float64 `json:"uptime"`
+	ProgramUptime float64 `json:"program_uptime"`
+	//LastFetch is the time of the last check for updates,
+	//which failed with LastFetchError unless empty
+	LastFetch      time.Time `json:"last_fetch"`
+	LastFetchError string    `json:"last_fetch_error,omitempty"`
+	//LastUpgrade is the time the last upgrade was installed
+	LastUpgrade time.Time `json:"last_upgrade"`
+	//LastError is the error of the last step of the
+	//upgrade lifecycle which failed, see Event
+	LastError string `json:"last_error,omitempty"`
+	//Fetching is the progress of the binary being downloaded, if any
+	Fetching *FetchProgress `json:"fetching,omitempty"`
+	//Restarting is set while a restart is in progress, RestartQueued
+	//when another follows it, and RestartDeferred when an upgrade
+	//waits to be restarted once upgrades are resumed
+	Restarting      bool `json:"restarting"`
+	RestartQueued   bool `json:"restart_queued"`
+	RestartDeferred bool `json:"restart_deferred"`
+	Paused          bool `json:"paused"`
+}
+
+func (mp *master) status() (Status, error) {
+	s := Status{MasterPid: os.Getpid(), SlaveID: mp.activeID(), Uptime: time.Since(mp.startedAt).Seconds()}
+	s.Hash, s.Version = mp.binary()
+	if p := mp.activeProc(); p != nil {
+		s.SlavePid = p.Pid
+	}
+	m := &mp.metricCounts
+	m.mux.Lock()
+	if !m.programStarted.IsZero() {
+		s.ProgramUptime = time.Since(m.programStarted).Seconds()
+	}
+	s.LastFetch, s.LastFetchError = m.lastFetch, m.lastFetchError
+	s.LastUpgrade, s.LastError = m.lastUpgrade, m.lastError
+	if m.progress != nil {
+		p := *m.progress
+		s.Fetching = &p
+	}
+	m.mux.Unlock()
+	mp.restartMux.Lock()
+	s.Restarting, s.RestartQueued = mp.restartActive, mp.restartQueued
+	mp.restartMux.Unlock()
+	mp.pauseMux.Lock()
+	s.Paused, s.RestartDeferred = mp.paused, mp.pausedRestart
+	mp.pauseMux.Unlock()
+	return s, nil
+}
+
+func (sp *slave) status() (Status, error) {
+	resp, err := sp.request(listenerRequest{Op: "status"})
+	if err != nil {
+		return Status{}, err
+	}
+	if resp.Error != "" {
+		return Status{}, errors.New(resp.Error)
+	}
+	if resp.Report == nil {
+		return Status{}, errors.New("the master does not report its status")
+	}
+	return *resp.Report, nil
+}
+
+// StatusHandler serves the Status of the master and its program as
+// JSON, such as their pids, the version being run, the result of the
+// last fetch and any pending restarts. It may be mounted by the program
+// on its own server, the status is then asked of the master.
+func StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if currentProcess == nil {
+			http.Error(w, ErrNotRunning.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		s, err := currentProcess.status()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+	})
+}
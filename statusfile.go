@@ -0,0 +1,71 @@
+package overseer
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// statusFileInterval is how often the StatusFile is
+// rewritten without changes, to keep its uptimes current
+const statusFileInterval = 10 * time.Second
+
+// statusFile is the content of Config.StatusFile
+type statusFile struct {
+	Status
+	//Updated is when the file was written, a stale
+	//file was left behind by a master which was killed
+	Updated time.Time `json:"updated"`
+}
+
+// writeStatusFiles keeps the StatusFile current until the master exits
+func (mp *master) writeStatusFiles() {
+	if mp.Config.StatusFile == "" {
+		return
+	}
+	mp.statusChanges = make(chan bool, 1)
+	go func() {
+		t := time.NewTicker(statusFileInterval)
+		defer t.Stop()
+		for {
+			mp.writeStatusFile()
+			select {
+			case <-mp.statusChanges:
+			case <-t.C:
+			}
+		}
+	}()
+}
+
+// statusChanged rewrites the StatusFile, without waiting
+func (mp *master) statusChanged() {
+	select {
+	case mp.statusChanges <- true:
+	default:
+		//a write is already pending, or there is no StatusFile
+	}
+}
+
+func (mp *master) writeStatusFile() {
+	s, _ := mp.status()
+	b, _ := json.MarshalIndent(statusFile{Status: s, Updated: time.Now()}, "", "  ")
+	mp.statusFileMux.Lock()
+	defer mp.statusFileMux.Unlock()
+	if mp.statusFileRemoved {
+		return
+	}
+	if err := replaceFile(mp.Config.StatusFile, append(b, '\n')); err != nil {
+		mp.warnf("failed to write status file (%s)", err)
+	}
+}
+
+// removeStatusFile removes the StatusFile as the master exits
+func (mp *master) removeStatusFile() {
+	if mp.Config.StatusFile == "" {
+		return
+	}
+	mp.statusFileMux.Lock()
+	mp.statusFileRemoved = true
+	mp.statusFileMux.Unlock()
+	os.Remove(mp.Config.StatusFile)
+}
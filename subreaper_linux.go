@@ -0,0 +1,120 @@
+package overseer
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const prSetChildSubreaper = 36
+
+// setupSubreaper makes the master the parent of the orphaned
+// processes of the program, which it reaps, see Config.Subreaper
+func (mp *master) setupSubreaper() error {
+	if !mp.Config.Subreaper {
+		return nil
+	}
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prSetChildSubreaper, 1, 0); errno != 0 {
+		return fmt.Errorf("failed to become a subreaper (%s)", errno)
+	}
+	mp.reapNudge = make(chan bool, 1)
+	go mp.reapLoop()
+	return nil
+}
+
+// reapLoop reaps orphaned zombies as children exit. The processes
+// the master started itself are reaped by their Wait, so zombies are
+// only reaped once seen twice, a second apart, other than the programs.
+func (mp *master) reapLoop() {
+	seen := map[int]bool{}
+	for {
+		if len(seen) > 0 {
+			select {
+			case <-mp.reapNudge:
+			case <-time.After(time.Second):
+			}
+		} else {
+			<-mp.reapNudge
+		}
+		zombies := map[int]bool{}
+		for _, pid := range zombieChildren() {
+			if mp.isProgram(pid) {
+				continue
+			}
+			if !seen[pid] {
+				zombies[pid] = true
+				continue
+			}
+			var status syscall.WaitStatus
+			if p, err := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); err == nil && p == pid {
+				mp.debugf("reaped orphaned process %d", pid)
+			}
+		}
+		seen = zombies
+	}
+}
+
+// childExited prompts the reaper, if any
+func (mp *master) childExited() {
+	if mp.reapNudge == nil {
+		return
+	}
+	select {
+	case mp.reapNudge <- true:
+	default:
+	}
+}
+
+// isProgram reports whether the pid is one of the programs
+func (mp *master) isProgram(pid int) bool {
+	if p := mp.activeProc(); p != nil && p.Pid == pid {
+		return true
+	}
+	mp.replicaMux.Lock()
+	defer mp.replicaMux.Unlock()
+	for _, r := range mp.replicas {
+		if r != nil && r.proc.Pid == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// zombieChildren lists the exited children of the master
+func zombieChildren() []int {
+	d, err := os.Open("/proc")
+	if err != nil {
+		return nil
+	}
+	names, _ := d.Readdirnames(-1)
+	d.Close()
+	self := os.Getpid()
+	var pids []int
+	for _, name := range names {
+		pid, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		b, err := ioutil.ReadFile("/proc/" + name + "/stat")
+		if err != nil {
+			continue
+		}
+		//the fields following the command, which may contain spaces
+		i := strings.LastIndexByte(string(b), ')')
+		if i < 0 {
+			continue
+		}
+		fields := strings.Fields(string(b[i+1:]))
+		if len(fields) < 2 || fields[0] != "Z" {
+			continue
+		}
+		if ppid, _ := strconv.Atoi(fields[1]); ppid == self {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
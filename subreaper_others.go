@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package overseer
+
+func (mp *master) setupSubreaper() error {
+	return nil
+}
+
+func (mp *master) childExited() {}
@@ -0,0 +1,53 @@
+//go:build linux
+// +build linux
+
+package overseer
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// memfd_create is missing from the syscall package
+var memfdCreateTrap = map[string]uintptr{
+	"386":      356,
+	"amd64":    319,
+	"arm":      385,
+	"arm64":    279,
+	"loong64":  279,
+	"mips":     4354,
+	"mipsle":   4354,
+	"mips64":   5314,
+	"mips64le": 5314,
+	"ppc64":    360,
+	"ppc64le":  360,
+	"riscv64":  279,
+	"s390x":    350,
+}
+
+const mfdCloexec = 0x1
+
+// memfd creates an anonymous file which lives in memory
+func memfd(name string) (*os.File, error) {
+	trap, ok := memfdCreateTrap[runtime.GOARCH]
+	if !ok {
+		return nil, fmt.Errorf("memfd_create not supported on %s", runtime.GOARCH)
+	}
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	fd, _, errno := syscall.Syscall(trap, uintptr(unsafe.Pointer(p)), mfdCloexec, 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("memfd_create: %s", errno)
+	}
+	return os.NewFile(fd, name), nil
+}
+
+// memfdPath is the path at which this process's file may be executed
+func memfdPath(f *os.File) string {
+	return fmt.Sprintf("/proc/%d/fd/%d", os.Getpid(), f.Fd())
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+// +build !linux
+
+package overseer
+
+import (
+	"errors"
+	"os"
+)
+
+func memfd(name string) (*os.File, error) {
+	return nil, errors.New("in-memory binaries are only supported on linux")
+}
+
+func memfdPath(f *os.File) string {
+	return ""
+}
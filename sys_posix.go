@@ -7,8 +7,8 @@ package overseer
 //in some other way on other OSs... TODO!
 
 import (
+	"errors"
 	"os"
-	"os/exec"
 	"syscall"
 )
 
@@ -22,22 +22,15 @@ var (
 )
 
 func move(dst, src string) error {
-	if err := os.Rename(src, dst); err == nil {
-		return nil
-	}
-	//HACK: we're shelling out to mv because linux
-	//throws errors when crossing device boundaries.
-	//TODO see sys_posix_mv.go
-	if err := exec.Command("mv", src, dst).Run(); err != nil {
-		return err
-	}
+	return replace(dst, src)
+}
 
-	// Run sync to 'commit' the mv by clearing caches
-	return syncCmd().Run()
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
 }
 
-func syncCmd() *exec.Cmd {
-	return exec.Command("sync")
+func isBusy(err error) bool {
+	return errors.Is(err, syscall.ETXTBSY) || errors.Is(err, syscall.EBUSY)
 }
 
 func chmod(f *os.File, perms os.FileMode) error {
@@ -46,3 +39,70 @@ func chmod(f *os.File, perms os.FileMode) error {
 func chown(f *os.File, uid, gid int) error {
 	return f.Chown(uid, gid)
 }
+
+func freeSpace(dir string) (uint64, error) {
+	st := syscall.Statfs_t{}
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
+
+// inheritable clears the close-on-exec flag of f
+func inheritable(f *os.File) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), syscall.F_SETFD, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// dupFile duplicates f, close-on-exec as f is
+func dupFile(f *os.File) (*os.File, error) {
+	syscall.ForkLock.RLock()
+	defer syscall.ForkLock.RUnlock()
+	fd, err := syscall.Dup(int(f.Fd()))
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(fd)
+	return os.NewFile(uintptr(fd), f.Name()), nil
+}
+
+// closeOnExec sets the close-on-exec flag of f
+func closeOnExec(f *os.File) {
+	syscall.CloseOnExec(int(f.Fd()))
+}
+
+func execSelf(path string, args, env []string) error {
+	return syscall.Exec(path, args, env)
+}
+
+// setReuseAddr sets or clears SO_REUSEADDR of the socket
+func setReuseAddr(fd uintptr, on bool) error {
+	v := 0
+	if on {
+		v = 1
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, v)
+}
+
+// setV6Only sets or clears IPV6_V6ONLY of the socket
+func setV6Only(fd uintptr, on bool) error {
+	v := 0
+	if on {
+		v = 1
+	}
+	return syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, v)
+}
+
+// setBacklog listens again with the given backlog,
+// which updates the backlog of a listening socket
+func setBacklog(fd uintptr, n int) error {
+	return syscall.Listen(int(fd), n)
+}
+
+// setReusePort sets SO_REUSEPORT, allowing
+// several sockets to bind the same address
+func setReusePort(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+}
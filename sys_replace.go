@@ -0,0 +1,89 @@
+package overseer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// replaceAttempts and replaceBackoff control retries
+// while the destination binary is busy
+const (
+	replaceAttempts = 10
+	replaceBackoff  = 100 * time.Millisecond
+)
+
+// replace moves src to dst, retrying while dst is busy
+func replace(dst, src string) error {
+	var err error
+	for attempt := 1; attempt <= replaceAttempts; attempt++ {
+		if err = replaceOnce(dst, src); err == nil || !isBusy(err) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * replaceBackoff)
+	}
+	return err
+}
+
+// replaceOnce atomically renames src to dst. When they are on
+// different filesystems, src is first copied beside dst and then
+// renamed. dst is never written in place, a short write would leave
+// a truncated binary, so the replace fails when dst's directory
+// isn't writable.
+func replaceOnce(dst, src string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	} else if !isCrossDevice(err) {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(dst), "."+filepath.Base(dst)+"."+token())
+	if err := copyFile(tmp, src); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to copy binary beside %s (%w)", dst, err)
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(dst))
+	return os.Remove(src)
+}
+
+func copyFile(dst, src string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	info, err := s.Stat()
+	if err != nil {
+		return err
+	}
+	d, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(d, s); err != nil {
+		d.Close()
+		return err
+	}
+	if err := d.Sync(); err != nil {
+		d.Close()
+		return err
+	}
+	if err := d.Chmod(info.Mode()); err != nil {
+		d.Close()
+		return err
+	}
+	return d.Close()
+}
+
+// syncDir commits renames within dir to disk
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
@@ -0,0 +1,8 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le && !sparc64
+// +build linux,!mips,!mipsle,!mips64,!mips64le,!sparc64
+
+package overseer
+
+// soReusePort is SO_REUSEPORT, which package syscall
+// does not define on linux
+const soReusePort = 0xf
@@ -0,0 +1,8 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le || sparc64)
+// +build linux
+// +build mips mipsle mips64 mips64le sparc64
+
+package overseer
+
+// soReusePort is SO_REUSEPORT on mips and sparc, see sys_reuseport_linux.go
+const soReusePort = 0x200
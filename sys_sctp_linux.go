@@ -0,0 +1,77 @@
+//go:build linux
+// +build linux
+
+package overseer
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// listenSCTP binds a one-to-one style (SOCK_STREAM) SCTP socket,
+// which the standard library does not support
+func listenSCTP(network, address string, backlog int) (*os.File, error) {
+	host, p, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(p, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %s", p)
+	}
+	var ip net.IP
+	if host != "" {
+		a, err := net.ResolveIPAddr(map[string]string{"sctp": "ip", "sctp4": "ip4", "sctp6": "ip6"}[network], host)
+		if err != nil {
+			return nil, err
+		}
+		ip = a.IP
+	}
+	family := syscall.AF_INET6
+	if network == "sctp4" || (network == "sctp" && ip.To4() != nil) {
+		family = syscall.AF_INET
+	}
+	fd, err := syscall.Socket(family, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, syscall.IPPROTO_SCTP)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	var sa syscall.Sockaddr
+	if family == syscall.AF_INET {
+		sa4 := &syscall.SockaddrInet4{Port: int(port)}
+		if ip != nil {
+			copy(sa4.Addr[:], ip.To4())
+		}
+		sa = sa4
+	} else {
+		//dual-stack, unless restricted to sctp6
+		v6only := 0
+		if network == "sctp6" {
+			v6only = 1
+		}
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, v6only)
+		sa6 := &syscall.SockaddrInet6{Port: int(port)}
+		if ip != nil {
+			copy(sa6.Addr[:], ip.To16())
+		}
+		sa = sa6
+	}
+	if err := syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setsockopt", err)
+	}
+	if err := syscall.Bind(fd, sa); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	if backlog <= 0 {
+		backlog = syscall.SOMAXCONN
+	}
+	if err := syscall.Listen(fd, backlog); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("listen", err)
+	}
+	return os.NewFile(uintptr(fd), network+":"+address), nil
+}
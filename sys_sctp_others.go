@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package overseer
+
+import (
+	"errors"
+	"os"
+)
+
+func listenSCTP(network, address string, backlog int) (*os.File, error) {
+	return nil, errors.New("sctp is only supported on linux")
+}
@@ -0,0 +1,15 @@
+//go:build darwin || freebsd
+// +build darwin freebsd
+
+package overseer
+
+import (
+	"errors"
+	"syscall"
+)
+
+const soReusePort = syscall.SO_REUSEPORT
+
+func bindToDevice(fd uintptr, device string) error {
+	return errors.New("binding to a device is only supported on linux")
+}
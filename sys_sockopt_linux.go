@@ -0,0 +1,11 @@
+//go:build linux
+// +build linux
+
+package overseer
+
+import "syscall"
+
+// bindToDevice restricts the socket to the network interface
+func bindToDevice(fd uintptr, device string) error {
+	return syscall.SetsockoptString(int(fd), syscall.SOL_SOCKET, syscall.SO_BINDTODEVICE, device)
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package overseer
+
+import "errors"
+
+func bindToDevice(fd uintptr, device string) error {
+	return errors.New("binding to a device is only supported on linux")
+}
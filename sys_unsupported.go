@@ -20,6 +20,14 @@ func move(dst, src string) error {
 	return errors.New("Not supported")
 }
 
+func isCrossDevice(err error) bool {
+	return false
+}
+
+func isBusy(err error) bool {
+	return false
+}
+
 func chmod(f *os.File, perms os.FileMode) error {
 	return errors.New("Not supported")
 }
@@ -27,3 +35,37 @@ func chmod(f *os.File, perms os.FileMode) error {
 func chown(f *os.File, uid, gid int) error {
 	return errors.New("Not supported")
 }
+
+func freeSpace(dir string) (uint64, error) {
+	return 0, errors.New("Not supported")
+}
+
+func inheritable(f *os.File) error {
+	return errors.New("Not supported")
+}
+
+func dupFile(f *os.File) (*os.File, error) {
+	return nil, errors.New("Not supported")
+}
+
+func closeOnExec(f *os.File) {}
+
+func execSelf(path string, args, env []string) error {
+	return errors.New("Not supported")
+}
+
+func setReuseAddr(fd uintptr, on bool) error {
+	return errors.New("Not supported")
+}
+
+func setV6Only(fd uintptr, on bool) error {
+	return errors.New("Not supported")
+}
+
+func setBacklog(fd uintptr, n int) error {
+	return errors.New("Not supported")
+}
+
+func setReusePort(fd uintptr) error {
+	return errors.New("Not supported")
+}
@@ -3,40 +3,62 @@
 package overseer
 
 import (
-	"bytes"
-	"fmt"
+	"errors"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"syscall"
+	"unsafe"
 )
 
 var (
 	supported = true
 	uid       = syscall.Getuid()
 	gid       = syscall.Getgid()
-	SIGUSR1   = syscall.SIGTERM
-	SIGUSR2   = syscall.SIGTERM
-	SIGTERM   = syscall.SIGTERM
+	//never delivered on windows, though distinct from SIGTERM which
+	//is sent by console close, logoff and shutdown events, so they
+	//terminate the program rather than restarting it. Restarts are
+	//signaled over pipes, see signalSlave.
+	SIGUSR1 = syscall.Signal(0xa)
+	SIGUSR2 = syscall.Signal(0xc)
+	SIGTERM = syscall.SIGTERM
 )
 
 func move(dst, src string) error {
 	os.MkdirAll(filepath.Dir(dst), 0755)
-	if err := os.Rename(src, dst); err == nil {
-		return nil
+	return replace(dst, src)
+}
+
+const (
+	errorNotSameDevice    = syscall.Errno(17)
+	errorSharingViolation = syscall.Errno(32)
+	errorLockViolation    = syscall.Errno(33)
+)
+
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
+
+// isBusy is true when dst is in use by another process,
+// which may be the anti-virus scanning our new binary.
+// Access denied is a permission error, it is not retried.
+func isBusy(err error) bool {
+	return errors.Is(err, errorSharingViolation) ||
+		errors.Is(err, errorLockViolation)
+}
+
+var getDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+func freeSpace(dir string) (uint64, error) {
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, err
 	}
-	//HACK: we're shelling out to move because windows
-	//throws errors when crossing device boundaries.
-	// https://www.microsoft.com/resources/documentation/windows/xp/all/proddocs/en-us/move.mspx?mfr=true
-
-	// https://blogs.msdn.microsoft.com/twistylittlepassagesallalike/2011/04/23/everyone-quotes-command-line-arguments-the-wrong-way/
-	R := func(s string) string { return replShellMeta.Replace(syscall.EscapeArg(s)) }
-	cmd := exec.Command("cmd", "/c", `move /y `+R(src)+` `+R(dst))
-	if b, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("%v: %q: %v", cmd.Args, bytes.TrimSpace(b), err)
+	var available uint64
+	if r, _, err := getDiskFreeSpaceEx.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&available)), 0, 0); r == 0 {
+		return 0, err
 	}
-	return nil
+	return available, nil
 }
 
 func chmod(f *os.File, perms os.FileMode) error {
@@ -53,16 +75,41 @@ func chown(f *os.File, uid, gid int) error {
 	return nil
 }
 
-// https://blogs.msdn.microsoft.com/twistylittlepassagesallalike/2011/04/23/everyone-quotes-command-line-arguments-the-wrong-way/
-var replShellMeta = strings.NewReplacer(
-	`(`, `^(`,
-	`)`, `^)`,
-	`%`, `^%`,
-	`!`, `^!`,
-	`^`, `^^`,
-	`"`, `^"`,
-	`<`, `^<`,
-	`>`, `^>`,
-	`&`, `^&`,
-	`|`, `^|`,
-)
+func inheritable(f *os.File) error {
+	return errors.New("Not supported")
+}
+
+// dupFile duplicates the handle of f, which is not inheritable
+func dupFile(f *os.File) (*os.File, error) {
+	p, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return nil, err
+	}
+	var h syscall.Handle
+	if err := syscall.DuplicateHandle(p, syscall.Handle(f.Fd()), p, &h, 0, false, syscall.DUPLICATE_SAME_ACCESS); err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), f.Name()), nil
+}
+
+func closeOnExec(f *os.File) {}
+
+func execSelf(path string, args, env []string) error {
+	return errors.New("Not supported")
+}
+
+func setReuseAddr(fd uintptr, on bool) error {
+	return errors.New("Not supported")
+}
+
+func setV6Only(fd uintptr, on bool) error {
+	return errors.New("Not supported")
+}
+
+func setBacklog(fd uintptr, n int) error {
+	return errors.New("Not supported")
+}
+
+func setReusePort(fd uintptr) error {
+	return errors.New("Not supported")
+}
@@ -0,0 +1,118 @@
+package overseer
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd
+const listenFDsStart = 3
+
+// activatedFiles returns the sockets passed by systemd socket
+// activation (LISTEN_FDS), keyed by the configured address they
+// match. Sockets are matched by their FileDescriptorName, when it
+// is the configured address, otherwise by their bound address.
+func (mp *master) activatedFiles() map[string]*os.File {
+	files := map[string]*os.File{}
+	pid, _ := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	n, _ := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	//not passed on to the program
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if pid != os.Getpid() || n <= 0 {
+		return files
+	}
+	for i := 0; i < n; i++ {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		mp.adoptActivated(files, uintptr(listenFDsStart+i), name)
+	}
+	return files
+}
+
+// adoptActivated adds the activated socket to files, keyed by the
+// configured address it matches, by name or by its bound address
+func (mp *master) adoptActivated(files map[string]*os.File, fd uintptr, name string) {
+	f, a, err := activatedSocket(fd, name)
+	if err != nil {
+		mp.warnf("ignoring activated socket %d (%s)", fd, err)
+		return
+	}
+	addr := ""
+	for _, c := range mp.Config.Addresses {
+		if _, ok := files[c]; ok {
+			continue
+		}
+		if c == name || (addr == "" && matchAddress(c, a)) {
+			addr = c
+		}
+	}
+	if addr == "" {
+		mp.warnf("ignoring activated socket %s, not in Addresses", a)
+		f.Close()
+		return
+	}
+	mp.debugf("adopted activated socket %s for %s", a, addr)
+	files[addr] = f
+}
+
+// activatedSocket takes the socket file descriptor,
+// returning a duplicate and its bound address
+func activatedSocket(fd uintptr, name string) (*os.File, net.Addr, error) {
+	f := os.NewFile(fd, name)
+	defer f.Close()
+	if l, err := net.FileListener(f); err == nil {
+		defer l.Close()
+		a := l.Addr()
+		d, err := l.(interface {
+			File() (*os.File, error)
+		}).File()
+		return d, a, err
+	}
+	c, err := net.FilePacketConn(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.Close()
+	a := c.LocalAddr()
+	d, err := c.(interface {
+		File() (*os.File, error)
+	}).File()
+	return d, a, err
+}
+
+// matchAddress reports whether the configured address is bound
+// by a, unspecified hosts only match unspecified hosts
+func matchAddress(addr string, a net.Addr) bool {
+	network, address := parseAddress(addr)
+	switch la := a.(type) {
+	case *net.TCPAddr:
+		if isPacketNetwork(network) || network == "unix" {
+			return false
+		}
+		ca, err := net.ResolveTCPAddr(network, address)
+		return err == nil && ca.Port == la.Port && sameHost(ca.IP, la.IP)
+	case *net.UDPAddr:
+		if !isPacketNetwork(network) {
+			return false
+		}
+		ca, err := net.ResolveUDPAddr(network, address)
+		return err == nil && ca.Port == la.Port && sameHost(ca.IP, la.IP)
+	case *net.UnixAddr:
+		return network == "unix" && address == la.Name
+	}
+	return false
+}
+
+func sameHost(a, b net.IP) bool {
+	if len(a) == 0 || a.IsUnspecified() {
+		return len(b) == 0 || b.IsUnspecified()
+	}
+	return a.Equal(b)
+}
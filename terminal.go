@@ -0,0 +1,126 @@
+package overseer
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// terminal proxies the master's terminal to
+// the pseudo-terminal of the running program
+type terminal struct {
+	mux     sync.Mutex
+	pty     *os.File
+	copied  chan bool
+	restore func()
+}
+
+// setupTerminal puts the master's terminal in raw mode, so that
+// input is passed as typed to the program, see Config.Terminal
+func (mp *master) setupTerminal() {
+	if !mp.Config.Terminal {
+		return
+	}
+	t := &terminal{}
+	if restore, err := makeRaw(os.Stdin); err == nil {
+		t.restore = restore
+	} else {
+		mp.debugf("stdin is not a terminal (%s)", err)
+	}
+	mp.terminal = t
+	go t.copyInput()
+}
+
+// copyInput passes the master's input to the current program
+func (t *terminal) copyInput() {
+	b := make([]byte, 4096)
+	for {
+		n, err := os.Stdin.Read(b)
+		if n > 0 {
+			t.mux.Lock()
+			if t.pty != nil {
+				t.pty.Write(b[:n])
+			}
+			t.mux.Unlock()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// attachTerminal runs the program in a new session, on a new
+// pseudo-terminal sized as the master's. The returned func is
+// called once the program has started, or failed to start.
+func (mp *master) attachTerminal(cmd *exec.Cmd) (func(error), error) {
+	t := mp.terminal
+	if t == nil {
+		return func(error) {}, nil
+	}
+	pty, tty, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	copyWinsize(pty, os.Stdin)
+	cmd.Stdin = tty
+	cmd.Stdout = tty
+	cmd.Stderr = tty
+	setControllingTerminal(cmd)
+	return func(err error) {
+		tty.Close()
+		if err != nil {
+			pty.Close()
+			return
+		}
+		copied := make(chan bool)
+		t.mux.Lock()
+		t.pty = pty
+		t.copied = copied
+		t.mux.Unlock()
+		//the previous program's output is still copied
+		//until it exits, though it no longer has input
+		go func() {
+			io.Copy(os.Stdout, pty)
+			close(copied)
+			t.mux.Lock()
+			if t.pty == pty {
+				t.pty = nil
+			}
+			t.mux.Unlock()
+			pty.Close()
+		}()
+	}, nil
+}
+
+// resizeTerminal passes the master's window size to the program
+func (mp *master) resizeTerminal() {
+	t := mp.terminal
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.pty != nil {
+		copyWinsize(t.pty, os.Stdin)
+	}
+}
+
+// restoreTerminal waits briefly for the last of the program's
+// output, then returns the master's terminal to its previous mode
+func (mp *master) restoreTerminal() {
+	t := mp.terminal
+	if t == nil {
+		return
+	}
+	t.mux.Lock()
+	copied := t.copied
+	t.mux.Unlock()
+	if copied != nil {
+		select {
+		case <-copied:
+		case <-time.After(time.Second):
+		}
+	}
+	if t.restore != nil {
+		t.restore()
+	}
+}
@@ -0,0 +1,44 @@
+package overseer
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
+
+// openPTY opens a new pseudo-terminal, returning its master and slave ends
+func openPTY() (*os.File, *os.File, error) {
+	pty, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open pty (%w)", err)
+	}
+	name := make([]byte, 128)
+	if err := ioctl(pty, syscall.TIOCPTYGRANT, nil); err != nil {
+		pty.Close()
+		return nil, nil, fmt.Errorf("failed to grant pty (%w)", err)
+	}
+	if err := ioctl(pty, syscall.TIOCPTYUNLK, nil); err != nil {
+		pty.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pty (%w)", err)
+	}
+	if err := ioctl(pty, syscall.TIOCPTYGNAME, unsafe.Pointer(&name[0])); err != nil {
+		pty.Close()
+		return nil, nil, fmt.Errorf("failed to open pty (%w)", err)
+	}
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	tty, err := os.OpenFile(string(name), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		pty.Close()
+		return nil, nil, fmt.Errorf("failed to open tty (%w)", err)
+	}
+	return pty, tty, nil
+}
@@ -0,0 +1,37 @@
+package overseer
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
+
+// openPTY opens a new pseudo-terminal, returning its master and slave ends
+func openPTY() (*os.File, *os.File, error) {
+	pty, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open pty (%w)", err)
+	}
+	unlock := int32(0)
+	n := uint32(0)
+	if err := ioctl(pty, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		pty.Close()
+		return nil, nil, fmt.Errorf("failed to unlock pty (%w)", err)
+	}
+	if err := ioctl(pty, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		pty.Close()
+		return nil, nil, fmt.Errorf("failed to open pty (%w)", err)
+	}
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		pty.Close()
+		return nil, nil, fmt.Errorf("failed to open tty (%w)", err)
+	}
+	return pty, tty, nil
+}
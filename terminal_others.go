@@ -0,0 +1,26 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package overseer
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errors.New("not supported")
+}
+
+func copyWinsize(pty, from *os.File) {}
+
+func setControllingTerminal(cmd *exec.Cmd) {}
+
+func isResize(s os.Signal) bool {
+	return false
+}
+
+func openPTY() (*os.File, *os.File, error) {
+	return nil, nil, errors.New("overseer.Config.Terminal not supported")
+}
@@ -0,0 +1,71 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package overseer
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+func ioctl(f *os.File, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// makeRaw disables line editing, echo and signal characters of
+// the terminal, as cfmakeraw, returning a func to restore it
+func makeRaw(f *os.File) (func(), error) {
+	var prev syscall.Termios
+	if err := ioctl(f, ioctlGetTermios, unsafe.Pointer(&prev)); err != nil {
+		return nil, err
+	}
+	raw := prev
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP |
+		syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(f, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return func() {
+		ioctl(f, ioctlSetTermios, unsafe.Pointer(&prev))
+	}, nil
+}
+
+type winsize struct {
+	rows, cols, x, y uint16
+}
+
+// copyWinsize sets the window size of the pty to the terminal's
+func copyWinsize(pty, from *os.File) {
+	ws := winsize{}
+	if err := ioctl(from, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return
+	}
+	ioctl(pty, syscall.TIOCSWINSZ, unsafe.Pointer(&ws))
+}
+
+// setControllingTerminal makes the program's stdin its
+// controlling terminal, in a new session
+func setControllingTerminal(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+}
+
+// isResize reports whether the terminal's window was resized
+func isResize(s os.Signal) bool {
+	return s == syscall.SIGWINCH
+}
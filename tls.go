@@ -0,0 +1,146 @@
+package overseer
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often certificate files are checked for changes
+const certCheckInterval = 5 * time.Second
+
+// TLS configures the TLS listener of an address, see Config.TLS
+type TLS struct {
+	//CertFile and KeyFile are the PEM encoded certificate and key.
+	//They are reloaded when changed, without restarting the program.
+	CertFile, KeyFile string
+	//GetCertificate is used instead of CertFile and KeyFile
+	GetCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	//Config is optionally used as the base of the tls.Config
+	Config *tls.Config
+}
+
+// validate checks the TLS configuration of the address
+func (t *TLS) validate(addr string, addresses []string) error {
+	if !containsAddress(addresses, addr) {
+		return fmt.Errorf("overseer.Config.TLS %s is not in Addresses", addr)
+	}
+	if t == nil {
+		return fmt.Errorf("overseer.Config.TLS %s is nil", addr)
+	}
+	if network, _ := parseAddress(addr); isPacketNetwork(network) {
+		return fmt.Errorf("overseer.Config.TLS %s must be a stream address", addr)
+	}
+	if t.GetCertificate != nil {
+		return nil
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		return fmt.Errorf("overseer.Config.TLS %s requires CertFile and KeyFile, or GetCertificate", addr)
+	}
+	if _, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+		return fmt.Errorf("overseer.Config.TLS %s: %w", addr, err)
+	}
+	return nil
+}
+
+// tlsConfig creates the tls.Config of the listener,
+// certificate files are reloaded as they change
+func (t *TLS) tlsConfig(warnf func(string, ...interface{})) (*tls.Config, error) {
+	c := &tls.Config{}
+	if t.Config != nil {
+		c = t.Config.Clone()
+	}
+	if t.GetCertificate != nil {
+		c.GetCertificate = t.GetCertificate
+		return c, nil
+	}
+	r := &certReloader{certFile: t.CertFile, keyFile: t.KeyFile, warnf: warnf}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	c.Certificates = nil
+	c.GetCertificate = r.getCertificate
+	return c, nil
+}
+
+// certReloader serves a certificate, reloading
+// it when its files are modified
+type certReloader struct {
+	certFile, keyFile string
+	warnf             func(string, ...interface{})
+	mut               sync.Mutex
+	cert              *tls.Certificate
+	modTime           time.Time
+	checked           time.Time
+}
+
+// modified returns the latest modification time of the files
+func (r *certReloader) modified() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (r *certReloader) load() error {
+	modTime, err := r.modified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if time.Since(r.checked) >= certCheckInterval {
+		r.checked = time.Now()
+		if modTime, err := r.modified(); err == nil && !modTime.Equal(r.modTime) {
+			//keep serving the previous certificate on failure,
+			//files may be mid-way through being replaced
+			if err := r.load(); err != nil {
+				r.warnf("failed to reload certificate %s (%s)", r.certFile, err)
+			}
+		}
+	}
+	if r.cert == nil {
+		return nil, errors.New("no certificate")
+	}
+	return r.cert, nil
+}
+
+// wrapTLS wraps the listener of each address configured with TLS
+func (sp *slave) wrapTLS() error {
+	for i, addr := range sp.Config.Addresses {
+		t, ok := sp.Config.TLS[addr]
+		if !ok || i >= len(sp.state.Listeners) || sp.state.Listeners[i] == nil {
+			continue
+		}
+		c, err := t.tlsConfig(sp.warnf)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate for: %s (%w)", addr, err)
+		}
+		l := tls.NewListener(sp.state.Listeners[i], c)
+		if sp.state.Listener == sp.state.Listeners[i] {
+			sp.state.Listener = l
+		}
+		sp.state.Listeners[i] = l
+	}
+	return nil
+}
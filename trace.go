@@ -0,0 +1,99 @@
+package overseer
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Tracer traces the stages of the upgrade pipeline, for example
+// with OpenTelemetry, see Config.Tracer. An upgrade is traced as an
+// "overseer.upgrade" span, with the "overseer.fetch", "overseer.verify",
+// "overseer.sanity_check", "overseer.install", "overseer.restart" and
+// "overseer.drain" spans as its children. Restarts which do not follow
+// an upgrade are traced as their own "overseer.restart" and
+// "overseer.drain" spans.
+type Tracer interface {
+	//Start starts a span, as a child of parent unless nil
+	Start(parent Span, name string) Span
+}
+
+// Span is a span started by a Tracer
+type Span interface {
+	//SetAttribute sets an attribute of the span, such as the version
+	SetAttribute(key string, value interface{})
+	//End ends the span, as failed with err unless nil
+	End(err error)
+}
+
+type noSpan struct{}
+
+func (noSpan) SetAttribute(key string, value interface{}) {}
+
+func (noSpan) End(err error) {}
+
+func (mp *master) startSpan(parent Span, name string) Span {
+	if mp.Config.Tracer == nil {
+		return noSpan{}
+	}
+	if _, ok := parent.(noSpan); ok {
+		parent = nil
+	}
+	return mp.Config.Tracer.Start(parent, name)
+}
+
+// drain is the program being replaced, see startDrain
+type drain struct {
+	span    Span
+	started time.Time
+}
+
+// startDrain traces the drain of the program being replaced,
+// ended once it has exited, see endDrain
+func (mp *master) startDrain(parent Span, slaveID int) {
+	span := mp.startSpan(parent, "overseer.drain")
+	span.SetAttribute("overseer.slave_id", slaveID)
+	mp.restartMux.Lock()
+	if mp.drainSpans == nil {
+		mp.drainSpans = map[int]drain{}
+	}
+	mp.drainSpans[slaveID] = drain{span: span, started: time.Now()}
+	mp.restartMux.Unlock()
+}
+
+// endDrain ends the span of the drained program, and emits
+// the EventDrain recording how long it took to exit
+func (mp *master) endDrain(e ProgramExit) {
+	mp.restartMux.Lock()
+	d, ok := mp.drainSpans[e.SlaveID]
+	delete(mp.drainSpans, e.SlaveID)
+	mp.restartMux.Unlock()
+	if !ok {
+		return
+	}
+	event := Event{Type: EventDrain, SlaveID: e.SlaveID, Duration: time.Since(d.started)}
+	d.span.SetAttribute("overseer.exit_code", e.Code)
+	if e.Code != 0 {
+		event.Error = e.describe()
+		d.span.End(fmt.Errorf("program exited with %d", e.Code))
+	} else {
+		d.span.End(nil)
+	}
+	mp.emit(event)
+}
+
+// endRestartSpan ends the span of the restart described by the event
+func (mp *master) endRestartSpan(span Span, e Event) {
+	span.SetAttribute("overseer.slave_id", e.SlaveID)
+	if e.Version != "" {
+		span.SetAttribute("overseer.version", e.Version)
+	}
+	if e.Reason != "" {
+		span.SetAttribute("overseer.reason", e.Reason)
+	}
+	if e.Error != "" {
+		span.End(errors.New(e.Error))
+		return
+	}
+	span.End(nil)
+}
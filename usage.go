@@ -0,0 +1,96 @@
+package overseer
+
+import (
+	"errors"
+	"time"
+)
+
+// Usage is a sample of the resources used by
+// a program, see Config.UsageSampled
+type Usage struct {
+	SlaveID int `json:"slave_id"`
+	Pid     int `json:"pid"`
+	//RSS is the resident memory of the program, in bytes
+	RSS int64 `json:"rss"`
+	//CPUTime is the user and system time the program has used
+	CPUTime time.Duration `json:"cpu_time"`
+	//OpenFiles is the number of file descriptors the program has open
+	OpenFiles int       `json:"open_files"`
+	SampledAt time.Time `json:"sampled_at"`
+}
+
+// usageLoop samples the programs every UsageInterval
+func (mp *master) usageLoop() {
+	for {
+		time.Sleep(mp.Config.UsageInterval)
+		samples := []Usage{}
+		if p := mp.activeProc(); p != nil {
+			samples = mp.sampled(samples, mp.activeID(), p.Pid)
+		}
+		mp.replicaMux.Lock()
+		replicas := append([]*replica{}, mp.replicas...)
+		mp.replicaMux.Unlock()
+		for _, r := range replicas {
+			if r != nil {
+				samples = mp.sampled(samples, r.slaveID, r.proc.Pid)
+			}
+		}
+		mp.metricCounts.sampled(samples)
+	}
+}
+
+func (mp *master) sampled(samples []Usage, slaveID, pid int) []Usage {
+	u, err := sampleUsage(pid)
+	if err != nil {
+		//exited since
+		return samples
+	}
+	u.SlaveID = slaveID
+	if mp.Config.UsageSampled != nil {
+		mp.Config.UsageSampled(u)
+	}
+	return append(samples, u)
+}
+
+// usage samples the program at the other end of the control pipe
+func (mp *master) usage(peer *controlPeer) (*Usage, error) {
+	peer.mux.Lock()
+	pid := peer.pid
+	peer.mux.Unlock()
+	if pid == 0 {
+		return nil, errors.New("program did not introduce itself")
+	}
+	u, err := sampleUsage(pid)
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Usage asks the master for a sample of the resources used by
+// this program, as seen from outside of it. Only supported on linux.
+func (s State) Usage() (Usage, error) {
+	if sp, ok := currentProcess.(*slave); ok && s.Enabled {
+		return sp.usage()
+	}
+	return Usage{}, ErrNotRunning
+}
+
+func (sp *slave) usage() (Usage, error) {
+	if sp.controlVersion < 1 {
+		return Usage{}, errors.New("the master does not speak the control protocol")
+	}
+	resp, err := sp.request(listenerRequest{Op: "usage"})
+	if err != nil {
+		return Usage{}, err
+	}
+	if resp.Error != "" {
+		return Usage{}, errors.New(resp.Error)
+	}
+	if resp.Usage == nil {
+		return Usage{}, errors.New("no usage reported")
+	}
+	u := *resp.Usage
+	u.SlaveID = sp.state.Generation
+	return u, nil
+}
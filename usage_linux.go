@@ -0,0 +1,44 @@
+package overseer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clockTicks is USER_HZ, in which /proc reports times
+const clockTicks = 100
+
+// sampleUsage reads the usage of the process from /proc
+func sampleUsage(pid int) (Usage, error) {
+	u := Usage{Pid: pid, SampledAt: time.Now()}
+	dir := fmt.Sprintf("/proc/%d", pid)
+	stat, err := ioutil.ReadFile(dir + "/stat")
+	if err != nil {
+		return u, err
+	}
+	//the fields following the command, which may contain spaces
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return u, fmt.Errorf("invalid %s/stat", dir)
+	}
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 22 {
+		return u, fmt.Errorf("invalid %s/stat", dir)
+	}
+	utime, _ := strconv.ParseInt(fields[11], 10, 64)
+	stime, _ := strconv.ParseInt(fields[12], 10, 64)
+	u.CPUTime = time.Duration(utime+stime) * time.Second / clockTicks
+	pages, _ := strconv.ParseInt(fields[21], 10, 64)
+	u.RSS = pages * int64(os.Getpagesize())
+	if f, err := os.Open(dir + "/fd"); err == nil {
+		names, _ := f.Readdirnames(-1)
+		f.Close()
+		u.OpenFiles = len(names)
+	}
+	return u, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package overseer
+
+import (
+	"errors"
+)
+
+func sampleUsage(pid int) (Usage, error) {
+	return Usage{}, errors.New("usage is only sampled on linux")
+}
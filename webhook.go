@@ -0,0 +1,221 @@
+package overseer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// SlackTemplate formats the notifications of a Webhook as a Slack
+// (or Mattermost) incoming webhook message, with the release notes
+const SlackTemplate = `{"text": {{if .Notes}}{{json (printf "%s\n%s" .Summary .Notes)}}{{else}}{{json .Summary}}{{end}}}`
+
+// Webhook is notified of the upgrade lifecycle, see Config.Webhooks
+type Webhook struct {
+	//URL the notifications are POSTed to
+	URL string
+	//Events to notify of. Defaults to EventUpgrade, EventFailure
+	//and EventRollback.
+	Events []EventType
+	//Template optionally formats the body with text/template, which
+	//is given the Notification and a json function, for example
+	//SlackTemplate. Defaults to the Notification as JSON.
+	Template string
+	//Header is added to each request, for example an Authorization
+	Header http.Header
+}
+
+// Notification describes an event to a Webhook or the Email notifier
+type Notification struct {
+	Event
+	//Host the master runs on
+	Host string `json:"host"`
+	//Binary is the name of the binary being upgraded
+	Binary string `json:"binary"`
+}
+
+// Summary describes the event in a sentence
+func (p Notification) Summary() string {
+	what := p.Version
+	if what == "" && len(p.Hash) > 12 {
+		what = p.Hash[:12]
+	}
+	var s string
+	switch p.Type {
+	case EventUpgrade:
+		s = fmt.Sprintf("%s upgraded to %s on %s", p.Binary, what, p.Host)
+	case EventFailure:
+		s = fmt.Sprintf("%s upgrade to %s failed on %s", p.Binary, what, p.Host)
+	case EventRollback:
+		s = fmt.Sprintf("%s rolled back to %s on %s", p.Binary, what, p.Host)
+	case EventCrash:
+		s = fmt.Sprintf("%s crashed on %s", p.Binary, p.Host)
+	case EventRestart:
+		s = fmt.Sprintf("%s restarted on %s", p.Binary, p.Host)
+	case EventDrain:
+		s = fmt.Sprintf("%s drained in %s on %s", p.Binary, p.Duration.Round(time.Millisecond), p.Host)
+	default:
+		s = fmt.Sprintf("%s %s on %s", p.Binary, p.Type, p.Host)
+	}
+	if p.Reason != "" {
+		s += " (" + p.Reason + ")"
+	}
+	if p.Error != "" {
+		s += ": " + p.Error
+	}
+	return s
+}
+
+// webhookRetries is how many times a notification is sent before it is dropped
+const webhookRetries = 3
+
+// webhookQueue is how many notifications may wait to be sent
+const webhookQueue = 64
+
+var webhookFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// notifier sends the notifications of Config.Webhooks in order
+type notifier struct {
+	hooks     []Webhook
+	templates []*template.Template
+	host      string
+	client    *http.Client
+	queue     chan Notification
+}
+
+func validateWebhooks(hooks []Webhook) error {
+	for _, h := range hooks {
+		if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+			return fmt.Errorf("overseer.Config.Webhooks %q: an http(s) URL is required", h.URL)
+		}
+		if _, err := template.New("webhook").Funcs(webhookFuncs).Parse(h.Template); err != nil {
+			return fmt.Errorf("overseer.Config.Webhooks %s: invalid Template (%w)", h.URL, err)
+		}
+	}
+	return nil
+}
+
+// setupWebhooks starts sending the notifications of Config.Webhooks
+func (mp *master) setupWebhooks() {
+	if len(mp.Config.Webhooks) == 0 {
+		return
+	}
+	n := &notifier{
+		hooks:  mp.Config.Webhooks,
+		client: &http.Client{Timeout: 10 * time.Second},
+		queue:  make(chan Notification, webhookQueue),
+	}
+	n.host, _ = os.Hostname()
+	for _, h := range n.hooks {
+		var t *template.Template
+		if h.Template != "" {
+			//validated
+			t = template.Must(template.New("webhook").Funcs(webhookFuncs).Parse(h.Template))
+		}
+		n.templates = append(n.templates, t)
+	}
+	mp.notifier = n
+	go mp.sendNotifications(n)
+}
+
+// notifyWebhooks queues the event for the webhooks which want it
+func (mp *master) notifyWebhooks(e Event) {
+	n := mp.notifier
+	if n == nil || !n.wants(e.Type) {
+		return
+	}
+	select {
+	case n.queue <- Notification{Event: e, Host: n.host, Binary: mp.binName}:
+	default:
+		mp.warnf("webhook queue full, dropped %s notification", e.Type)
+	}
+}
+
+func (n *notifier) wants(t EventType) bool {
+	for i := range n.hooks {
+		if n.hooks[i].wants(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Webhook) wants(t EventType) bool {
+	if len(h.Events) == 0 {
+		return t == EventUpgrade || t == EventFailure || t == EventRollback
+	}
+	for _, e := range h.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+func (mp *master) sendNotifications(n *notifier) {
+	for p := range n.queue {
+		for i := range n.hooks {
+			if !n.hooks[i].wants(p.Type) {
+				continue
+			}
+			if err := n.send(i, p); err != nil {
+				mp.warnf("failed to notify webhook %s (%s)", n.hooks[i].URL, err)
+			}
+		}
+	}
+}
+
+// send POSTs the notification, retrying with a backoff
+func (n *notifier) send(i int, p Notification) error {
+	h := &n.hooks[i]
+	body := &bytes.Buffer{}
+	if t := n.templates[i]; t != nil {
+		if err := t.Execute(body, p); err != nil {
+			return err
+		}
+	} else if err := json.NewEncoder(body).Encode(p); err != nil {
+		return err
+	}
+	var err error
+	for attempt := 0; attempt < webhookRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if err = n.post(h, body.Bytes()); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (n *notifier) post(h *Webhook, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range h.Header {
+		req.Header[k] = v
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}